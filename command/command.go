@@ -2,19 +2,31 @@
 package command
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"github.com/giantswarm/microerror"
-	"github.com/giantswarm/micrologger"
 
+	"github.com/giantswarm/k8s-endpoint-updater/command/controller"
+	"github.com/giantswarm/k8s-endpoint-updater/command/flag"
+	"github.com/giantswarm/k8s-endpoint-updater/command/gc"
 	"github.com/giantswarm/k8s-endpoint-updater/command/update"
 	"github.com/giantswarm/k8s-endpoint-updater/command/version"
+	"github.com/giantswarm/k8s-endpoint-updater/service/logging"
+)
+
+var (
+	f = &flag.Flag{}
 )
 
 // Config represents the configuration used to create a new root command.
 type Config struct {
 	// Dependencies.
-	Logger micrologger.Logger
+
+	// Logger is used by all commands. Its format and level are configured
+	// according to the --log.* flags before any command runs.
+	Logger *logging.Logger
 
 	// Settings.
 	Description string
@@ -40,8 +52,33 @@ func DefaultConfig() Config {
 
 // New creates a new root command.
 func New(config Config) (*Command, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+
 	var err error
 
+	var controllerCommand *controller.Command
+	{
+		controllerConfig := controller.DefaultConfig()
+		controllerConfig.Logger = config.Logger
+		controllerCommand, err = controller.New(controllerConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	var gcCommand *gc.Command
+	{
+		gcConfig := gc.DefaultConfig()
+		gcConfig.Logger = config.Logger
+		gcCommand, err = gc.New(gcConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
 	var updateCommand *update.Command
 	{
 		updateConfig := update.DefaultConfig()
@@ -68,10 +105,15 @@ func New(config Config) (*Command, error) {
 	}
 
 	newCommand := &Command{
+		// Dependencies.
+		logger: config.Logger,
+
 		// Internals.
-		cobraCommand:   nil,
-		updateCommand:  updateCommand,
-		versionCommand: versionCommand,
+		cobraCommand:      nil,
+		controllerCommand: controllerCommand,
+		gcCommand:         gcCommand,
+		updateCommand:     updateCommand,
+		versionCommand:    versionCommand,
 	}
 
 	newCommand.cobraCommand = &cobra.Command{
@@ -79,8 +121,15 @@ func New(config Config) (*Command, error) {
 		Short: config.Description,
 		Long:  config.Description,
 		Run:   newCommand.Execute,
+
+		PersistentPreRunE: newCommand.configureLogger,
 	}
 
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Log.Format, "log.format", logging.FormatJSON, "Format of log messages. One of "+strings.Join(logging.Formats(), ", ")+".")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Log.Level, "log.level", logging.LevelInfo, "Minimum level of logged messages. One of "+strings.Join(logging.Levels(), ", ")+".")
+
+	newCommand.cobraCommand.AddCommand(newCommand.controllerCommand.CobraCommand())
+	newCommand.cobraCommand.AddCommand(newCommand.gcCommand.CobraCommand())
 	newCommand.cobraCommand.AddCommand(newCommand.updateCommand.CobraCommand())
 	newCommand.cobraCommand.AddCommand(newCommand.versionCommand.CobraCommand())
 
@@ -88,10 +137,15 @@ func New(config Config) (*Command, error) {
 }
 
 type Command struct {
+	// Dependencies.
+	logger *logging.Logger
+
 	// Internals.
-	cobraCommand   *cobra.Command
-	updateCommand  *update.Command
-	versionCommand *version.Command
+	cobraCommand      *cobra.Command
+	controllerCommand *controller.Command
+	gcCommand         *gc.Command
+	updateCommand     *update.Command
+	versionCommand    *version.Command
 }
 
 func (c *Command) CobraCommand() *cobra.Command {
@@ -102,6 +156,34 @@ func (c *Command) Execute(cmd *cobra.Command, args []string) {
 	cmd.HelpFunc()(cmd, nil)
 }
 
+// configureLogger applies the --log.* flags to the logger shared by all
+// commands.
+func (c *Command) configureLogger(cmd *cobra.Command, args []string) error {
+	err := f.Validate()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = c.logger.SetFormat(f.Log.Format)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	err = c.logger.SetLevel(f.Log.Level)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (c *Command) ControllerCommand() *controller.Command {
+	return c.controllerCommand
+}
+
+func (c *Command) GCCommand() *gc.Command {
+	return c.gcCommand
+}
+
 func (c *Command) UpdateCommand() *update.Command {
 	return c.updateCommand
 }
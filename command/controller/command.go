@@ -0,0 +1,415 @@
+// Package controller implements the controller command for the command line
+// tool.
+package controller
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/giantswarm/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/giantswarm/k8s-endpoint-updater/command/controller/flag"
+	"github.com/giantswarm/k8s-endpoint-updater/service/controller"
+	"github.com/giantswarm/k8s-endpoint-updater/service/health"
+	"github.com/giantswarm/k8s-endpoint-updater/service/leader"
+	"github.com/giantswarm/k8s-endpoint-updater/service/provider/annotation"
+	"github.com/giantswarm/k8s-endpoint-updater/service/restconfig"
+	"github.com/giantswarm/k8s-endpoint-updater/service/server"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
+)
+
+const (
+	podNameEnv      = "POD_NAME"
+	podNamespaceEnv = "POD_NAMESPACE"
+)
+
+const (
+	// eventFlushWait is the time given to the event broadcaster to deliver
+	// the events recorded last before recording stops.
+	eventFlushWait = 2 * time.Second
+	// serverShutdownTimeout bounds draining the requests in flight of the
+	// HTTP server on termination.
+	serverShutdownTimeout = 5 * time.Second
+)
+
+var (
+	f = &flag.Flag{}
+)
+
+// Config represents the configuration used to create a new controller
+// command.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+}
+
+// DefaultConfig provides a default configuration to create a new controller
+// command by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger: nil,
+	}
+}
+
+// New creates a new configured controller command.
+func New(config Config) (*Command, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+
+	newCommand := &Command{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Internals.
+		cobraCommand: nil,
+	}
+
+	newCommand.cobraCommand = &cobra.Command{
+		Use:   "controller",
+		Short: "Reconcile endpoints of guest cluster services for all labelled KVM pods.",
+		Long:  "Reconcile endpoints of guest cluster services for all labelled KVM pods. KVM pods have to be labelled with '" + controller.LabelService + "' naming the guest cluster service in the pod's namespace.",
+		Run:   newCommand.Execute,
+	}
+
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Address, "service.kubernetes.address", "", "Address used to connect to Kubernetes. Overrides the server of the kubeconfig when used together.")
+	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.AllowInsecure, "service.kubernetes.allowInsecure", false, "Whether to allow connecting to Kubernetes via plain HTTP.")
+	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.InCluster, "service.kubernetes.inCluster", false, "Whether to use the in-cluster config to authenticate with Kubernetes.")
+	_ = newCommand.CobraCommand().PersistentFlags().MarkDeprecated("service.kubernetes.inCluster", "use --service.kubernetes.mode=inCluster instead")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.CaFile, "service.kubernetes.tls.caFile", "", "Certificate authority file path to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.CrtFile, "service.kubernetes.tls.crtFile", "", "Certificate file path to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.KeyFile, "service.kubernetes.tls.keyFile", "", "Key file path to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().DurationVar(&f.Kubernetes.TLS.ReloadInterval, "service.kubernetes.tls.reloadInterval", restconfig.DefaultReloadInterval, "Interval in which the certificate authority, certificate and key files are checked for changes and reloaded. Zero disables reloading.")
+	newCommand.CobraCommand().PersistentFlags().IntVar(&f.Kubernetes.Burst, "service.kubernetes.burst", restconfig.DefaultBurst, "Maximum burst of requests to Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Context, "service.kubernetes.context", "", "Kubeconfig context to use to connect to Kubernetes. Defaults to the current context.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Kubeconfig, "service.kubernetes.kubeconfig", "", "Kubeconfig file path to use to connect to Kubernetes. Defaults to the value of KUBECONFIG environment variable.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Mode, "service.kubernetes.mode", restconfig.ModeAuto, "How to connect to Kubernetes. One of "+strings.Join(restconfig.Modes(), ", ")+". In auto mode a given kubeconfig or context is used, then the in-cluster config when running in a pod, then the given address and finally the kubeconfig at KUBECONFIG or its default location.")
+	newCommand.CobraCommand().PersistentFlags().Float32Var(&f.Kubernetes.QPS, "service.kubernetes.qps", restconfig.DefaultQPS, "Maximum rate of requests per second to Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().DurationVar(&f.Kubernetes.Timeout, "service.kubernetes.timeout", restconfig.DefaultTimeout, "Timeout of a single request to Kubernetes. Zero disables the timeout.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Token, "service.kubernetes.token", "", "Bearer token to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TokenFile, "service.kubernetes.tokenFile", "", "Bearer token file path to use to authenticate with Kubernetes. The file is read again when the token expires.")
+
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Controller.Namespace, "controller.namespace", "", "Namespace of the watched KVM pods. When empty all namespaces are watched.")
+	newCommand.cobraCommand.PersistentFlags().StringSliceVar(&f.Controller.PortOverrides, "controller.portOverrides", nil, "Overrides of VM ports as comma separated <service port>=<VM port> pairs, where the service port is given by name or number. By default VM ports are derived from the target ports of the services.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.Controller.ResyncPeriod, "controller.resyncPeriod", 5*time.Minute, "Interval in which the endpoints of all guest cluster services are reconciled again.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Controller.Selector, "controller.selector", "", "Additional label selector the watched KVM pods have to match.")
+	newCommand.cobraCommand.PersistentFlags().IntVar(&f.Controller.Workers, "controller.workers", 1, "Number of guest cluster services reconciled concurrently.")
+
+	newCommand.cobraCommand.PersistentFlags().BoolVar(&f.LeaderElection.Enabled, "leaderElection.enabled", true, "Whether to elect a leader among controller replicas. Only the leader reconciles endpoints.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.LeaderElection.Identity, "leaderElection.identity", os.Getenv(podNameEnv), "Identity of this replica in the leader election. Defaults to the value of POD_NAME environment variable.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.LeaderElection.LeaseDuration, "leaderElection.leaseDuration", 15*time.Second, "Duration non-leaders wait before trying to acquire the leadership.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.LeaderElection.Name, "leaderElection.name", "k8s-endpoint-updater-controller", "Name of the Lease used for the leader election.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.LeaderElection.Namespace, "leaderElection.namespace", os.Getenv(podNamespaceEnv), "Namespace of the Lease used for the leader election. Defaults to the value of POD_NAMESPACE environment variable.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.LeaderElection.RenewDeadline, "leaderElection.renewDeadline", 10*time.Second, "Duration the leader retries renewing the leadership before giving it up.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.LeaderElection.RetryPeriod, "leaderElection.retryPeriod", 2*time.Second, "Duration between leader election attempts.")
+
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Annotation.Name, "provider.annotation.name", "endpoint.kvm.giantswarm.io/ip", "Annotation of the KVM pods holding the VM IP.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kind, "provider.kind", "annotation", "Provider used to lookup VM IPs of KVM pods.")
+
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Server.Address, "server.address", "", "Address the HTTP server serving /metrics, /healthz and /readyz listens on, e.g. ':8000'. When empty no server is started.")
+
+	return newCommand, nil
+}
+
+type Command struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Internals.
+	cobraCommand *cobra.Command
+}
+
+func (c *Command) CobraCommand() *cobra.Command {
+	return c.cobraCommand
+}
+
+func (c *Command) Execute(cmd *cobra.Command, args []string) {
+	_ = c.logger.Log("level", "info", "message", "start reconciling endpoints of guest cluster services")
+
+	if f.Kubernetes.InCluster {
+		f.Kubernetes.Mode = restconfig.ModeInCluster
+	}
+
+	err := f.Validate()
+	if err != nil {
+		_ = c.logger.Log("level", "error", "message", "invalid flags", "error", err)
+		os.Exit(1)
+	}
+
+	err = c.execute()
+	if err != nil {
+		_ = c.logger.Log("level", "error", "message", "failed reconciling endpoints of guest cluster services", "error", err)
+		os.Exit(1)
+	}
+
+	_ = c.logger.Log("level", "info", "message", "finished reconciling endpoints of guest cluster services")
+}
+
+func (c *Command) execute() error {
+	var err error
+
+	// TLS files are reloaded as long as the command executes.
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+
+	// Termination signals cancel ctx, which aborts the Kubernetes API calls in
+	// flight as well.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		signalChan := make(chan os.Signal, 1)
+		signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+
+		s := <-signalChan
+		_ = c.logger.Log("level", "debug", "message", "received signal", "signal", s.String())
+		cancel()
+	}()
+
+	var k8sClients *k8sclient.Clients
+	var k8sWatchClient kubernetes.Interface
+	{
+		var restConfig *rest.Config
+		{
+			restConfigConfig := restconfig.DefaultConfig()
+
+			restConfigConfig.Logger = c.logger
+
+			restConfigConfig.Address = f.Kubernetes.Address
+			restConfigConfig.AllowInsecure = f.Kubernetes.AllowInsecure
+			restConfigConfig.Burst = f.Kubernetes.Burst
+			restConfigConfig.Context = f.Kubernetes.Context
+			restConfigConfig.Kubeconfig = f.Kubernetes.Kubeconfig
+			restConfigConfig.Mode = f.Kubernetes.Mode
+			restConfigConfig.QPS = f.Kubernetes.QPS
+			restConfigConfig.Timeout = f.Kubernetes.Timeout
+			restConfigConfig.TLS = restconfig.ConfigTLS{
+				CAFile:  f.Kubernetes.TLS.CaFile,
+				CrtFile: f.Kubernetes.TLS.CrtFile,
+				KeyFile: f.Kubernetes.TLS.KeyFile,
+
+				ReloadInterval: f.Kubernetes.TLS.ReloadInterval,
+			}
+			restConfigConfig.Token = f.Kubernetes.Token
+			restConfigConfig.TokenFile = f.Kubernetes.TokenFile
+
+			restConfig, err = restconfig.New(reloadCtx, restConfigConfig)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			restConfig = restconfig.AbortOnDone(ctx, restConfig)
+		}
+
+		k8sConfig := k8sclient.ClientsConfig{
+			Logger: c.logger,
+
+			RestConfig: restConfig,
+		}
+
+		k8sClients, err = k8sclient.NewClients(k8sConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		k8sWatchClient, err = kubernetes.NewForConfig(restconfig.ForWatches(restConfig))
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var newProvider *annotation.Provider
+	{
+		annotationConfig := annotation.DefaultConfig()
+
+		annotationConfig.Logger = c.logger
+
+		annotationConfig.Annotation = f.Provider.Annotation.Name
+
+		newProvider, err = annotation.New(annotationConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var eventRecorder record.EventRecorder
+	var eventWatcher watch.Interface
+	{
+		eventBroadcaster := record.NewBroadcaster()
+		eventWatcher = eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: k8sClients.K8sClient().CoreV1().Events("")})
+
+		eventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: c.cobraCommand.Root().Name()})
+	}
+	defer stopEvents(eventWatcher)
+
+	var newUpdater *updater.Updater
+	{
+		portOverrides, err := updater.ParsePortOverrides(f.Controller.PortOverrides)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		updaterConfig := updater.DefaultConfig()
+
+		updaterConfig.DynamicClient = k8sClients.DynClient()
+		updaterConfig.EventRecorder = eventRecorder
+		updaterConfig.K8sClient = k8sClients.K8sClient()
+		updaterConfig.Logger = c.logger
+
+		updaterConfig.PortOverrides = portOverrides
+
+		newUpdater, err = updater.New(updaterConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var newController *controller.Controller
+	{
+		controllerConfig := controller.DefaultConfig()
+
+		controllerConfig.K8sClient = k8sWatchClient
+		controllerConfig.Logger = c.logger
+		controllerConfig.Provider = newProvider
+		controllerConfig.Updater = newUpdater
+
+		controllerConfig.Namespace = f.Controller.Namespace
+		controllerConfig.ResyncPeriod = f.Controller.ResyncPeriod
+		controllerConfig.Selector = f.Controller.Selector
+		controllerConfig.Workers = f.Controller.Workers
+
+		newController, err = controller.New(controllerConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// The controller is ready once its caches are synced, no matter whether it
+	// leads or stands by. Otherwise standby replicas would never become ready
+	// and rollouts would stall.
+	var newHealth *health.Health
+	{
+		healthConfig := health.DefaultConfig()
+
+		newHealth, err = health.New(healthConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if f.Server.Address != "" {
+		serverConfig := server.DefaultConfig()
+
+		serverConfig.Health = newHealth
+		serverConfig.Logger = c.logger
+
+		serverConfig.Address = f.Server.Address
+
+		newServer, err := server.New(serverConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		newServer.Boot()
+		defer c.shutdownServer(newServer)
+	}
+
+	var bootErr error
+	boot := func(ctx context.Context) {
+		bootErr = newController.Boot(ctx, func() { newHealth.Reconciled(time.Now()) })
+	}
+
+	if !f.LeaderElection.Enabled {
+		boot(ctx)
+		if bootErr != nil {
+			return microerror.Mask(bootErr)
+		}
+
+		return nil
+	}
+
+	var newLeader *leader.Leader
+	{
+		leaderConfig := leader.DefaultConfig()
+
+		leaderConfig.K8sClient = k8sClients.K8sClient()
+		leaderConfig.Logger = c.logger
+
+		leaderConfig.Identity = f.LeaderElection.Identity
+		leaderConfig.LeaseDuration = f.LeaderElection.LeaseDuration
+		leaderConfig.Name = f.LeaderElection.Name
+		leaderConfig.Namespace = f.LeaderElection.Namespace
+		leaderConfig.RenewDeadline = f.LeaderElection.RenewDeadline
+		leaderConfig.RetryPeriod = f.LeaderElection.RetryPeriod
+
+		newLeader, err = leader.New(leaderConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// Standby replicas sync their caches as well, so that they report
+	// readiness and take over quickly.
+	go func() {
+		err := newController.Sync(ctx)
+		if ctx.Err() != nil {
+			return
+		} else if err != nil {
+			_ = c.logger.Log("level", "error", "message", "failed syncing caches", "error", err)
+			return
+		}
+
+		newHealth.Reconciled(time.Now())
+	}()
+
+	// Losing the leadership makes us exit with an error so that the controller
+	// gets restarted and takes part in the next election with a clean state.
+	err = newLeader.Run(ctx, boot)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if bootErr != nil {
+		return microerror.Mask(bootErr)
+	}
+
+	return nil
+}
+
+// shutdownServer gracefully stops the given server, giving it
+// serverShutdownTimeout to drain the requests in flight.
+func (c *Command) shutdownServer(s *server.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	err := s.Shutdown(ctx)
+	if err != nil {
+		_ = c.logger.Log("level", "warning", "message", "failed shutting down HTTP server", "error", err)
+	}
+}
+
+// stopEvents stops recording events to the API. Events are delivered
+// asynchronously, so the broadcaster is given eventFlushWait to deliver the
+// events recorded last before. The broadcaster itself is not shut down, as
+// event recorders send to it from goroutines of their own which would race
+// with closing it.
+func stopEvents(w watch.Interface) {
+	time.Sleep(eventFlushWait)
+
+	w.Stop()
+}
@@ -0,0 +1,11 @@
+package controller
+
+import "time"
+
+type Controller struct {
+	Namespace     string
+	PortOverrides []string
+	ResyncPeriod  time.Duration
+	Selector      string
+	Workers       int
+}
@@ -0,0 +1,74 @@
+package flag
+
+import (
+	"strings"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-endpoint-updater/command/controller/flag/controller"
+	"github.com/giantswarm/k8s-endpoint-updater/command/controller/flag/kubernetes"
+	"github.com/giantswarm/k8s-endpoint-updater/command/controller/flag/leaderelection"
+	"github.com/giantswarm/k8s-endpoint-updater/command/controller/flag/provider"
+	"github.com/giantswarm/k8s-endpoint-updater/command/controller/flag/server"
+	"github.com/giantswarm/k8s-endpoint-updater/service/restconfig"
+)
+
+type Flag struct {
+	Controller     controller.Controller
+	Kubernetes     kubernetes.Kubernetes
+	LeaderElection leaderelection.LeaderElection
+	Provider       provider.Provider
+	Server         server.Server
+}
+
+func (f *Flag) Validate() error {
+	if f.Kubernetes.Burst < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes burst must not be negative")
+	}
+	if f.Kubernetes.QPS < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes qps must not be negative")
+	}
+	if f.Kubernetes.Timeout < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes timeout must not be negative")
+	}
+	if f.Kubernetes.TLS.ReloadInterval < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes tls reload interval must not be negative")
+	}
+	if f.Kubernetes.Token != "" && f.Kubernetes.TokenFile != "" {
+		return microerror.Maskf(invalidFlagsError, "kubernetes token and token file must not be used together")
+	}
+	if !restconfig.IsMode(f.Kubernetes.Mode) {
+		return microerror.Maskf(invalidFlagsError, "kubernetes mode must be one of %s", strings.Join(restconfig.Modes(), ", "))
+	}
+
+	if f.Controller.ResyncPeriod < 0 {
+		return microerror.Maskf(invalidFlagsError, "controller resync period must not be negative")
+	}
+	if f.Controller.Workers <= 0 {
+		return microerror.Maskf(invalidFlagsError, "controller workers must be greater than zero")
+	}
+
+	if f.LeaderElection.Enabled {
+		if f.LeaderElection.Identity == "" {
+			return microerror.Maskf(invalidFlagsError, "leader election identity must not be empty")
+		}
+		if f.LeaderElection.Name == "" {
+			return microerror.Maskf(invalidFlagsError, "leader election name must not be empty")
+		}
+		if f.LeaderElection.Namespace == "" {
+			return microerror.Maskf(invalidFlagsError, "leader election namespace must not be empty")
+		}
+		if f.LeaderElection.RenewDeadline >= f.LeaderElection.LeaseDuration {
+			return microerror.Maskf(invalidFlagsError, "leader election renew deadline must be less than lease duration")
+		}
+	}
+
+	if f.Provider.Kind != "annotation" {
+		return microerror.Maskf(invalidFlagsError, "provider kind must be 'annotation'")
+	}
+	if f.Provider.Kind == "annotation" && f.Provider.Annotation.Name == "" {
+		return microerror.Maskf(invalidFlagsError, "annotation name must not be empty")
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package leaderelection
+
+import "time"
+
+type LeaderElection struct {
+	Enabled       bool
+	Identity      string
+	LeaseDuration time.Duration
+	Name          string
+	Namespace     string
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
@@ -0,0 +1,5 @@
+package annotation
+
+type Annotation struct {
+	Name string
+}
@@ -0,0 +1,10 @@
+package provider
+
+import (
+	"github.com/giantswarm/k8s-endpoint-updater/command/controller/flag/provider/annotation"
+)
+
+type Provider struct {
+	Annotation annotation.Annotation
+	Kind       string
+}
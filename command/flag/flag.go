@@ -0,0 +1,35 @@
+package flag
+
+import (
+	"strings"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-endpoint-updater/command/flag/log"
+	"github.com/giantswarm/k8s-endpoint-updater/service/logging"
+)
+
+type Flag struct {
+	Log log.Log
+}
+
+func (f *Flag) Validate() error {
+	if !contains(logging.Formats(), f.Log.Format) {
+		return microerror.Maskf(invalidFlagsError, "log format must be one of %s", strings.Join(logging.Formats(), ", "))
+	}
+	if !contains(logging.Levels(), f.Log.Level) {
+		return microerror.Maskf(invalidFlagsError, "log level must be one of %s", strings.Join(logging.Levels(), ", "))
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,6 @@
+package log
+
+type Log struct {
+	Format string
+	Level  string
+}
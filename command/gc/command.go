@@ -0,0 +1,212 @@
+// Package gc implements the gc command for the command line tool.
+package gc
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/giantswarm/k8sclient"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/rest"
+
+	"github.com/giantswarm/k8s-endpoint-updater/command/gc/flag"
+	"github.com/giantswarm/k8s-endpoint-updater/service/gc"
+	"github.com/giantswarm/k8s-endpoint-updater/service/restconfig"
+)
+
+var (
+	f = &flag.Flag{}
+)
+
+// Config represents the configuration used to create a new gc command.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+}
+
+// DefaultConfig provides a default configuration to create a new gc command by
+// best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger: nil,
+	}
+}
+
+// New creates a new configured gc command.
+func New(config Config) (*Command, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "logger must not be empty")
+	}
+
+	newCommand := &Command{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Internals.
+		cobraCommand: nil,
+	}
+
+	newCommand.cobraCommand = &cobra.Command{
+		Use:   "gc",
+		Short: "Delete objects created by the updater whose owners do not exist anymore.",
+		Long:  "Delete objects created by the updater whose owners do not exist anymore. Objects created by older versions without owner references are checked against the KVM pod they were published for.",
+		Run:   newCommand.Execute,
+	}
+
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Address, "service.kubernetes.address", "", "Address used to connect to Kubernetes. Overrides the server of the kubeconfig when used together.")
+	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.AllowInsecure, "service.kubernetes.allowInsecure", false, "Whether to allow connecting to Kubernetes via plain HTTP.")
+	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.InCluster, "service.kubernetes.inCluster", false, "Whether to use the in-cluster config to authenticate with Kubernetes.")
+	_ = newCommand.CobraCommand().PersistentFlags().MarkDeprecated("service.kubernetes.inCluster", "use --service.kubernetes.mode=inCluster instead")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.CaFile, "service.kubernetes.tls.caFile", "", "Certificate authority file path to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.CrtFile, "service.kubernetes.tls.crtFile", "", "Certificate file path to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.KeyFile, "service.kubernetes.tls.keyFile", "", "Key file path to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().DurationVar(&f.Kubernetes.TLS.ReloadInterval, "service.kubernetes.tls.reloadInterval", restconfig.DefaultReloadInterval, "Interval in which the certificate authority, certificate and key files are checked for changes and reloaded. Zero disables reloading.")
+	newCommand.CobraCommand().PersistentFlags().IntVar(&f.Kubernetes.Burst, "service.kubernetes.burst", restconfig.DefaultBurst, "Maximum burst of requests to Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Context, "service.kubernetes.context", "", "Kubeconfig context to use to connect to Kubernetes. Defaults to the current context.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Kubeconfig, "service.kubernetes.kubeconfig", "", "Kubeconfig file path to use to connect to Kubernetes. Defaults to the value of KUBECONFIG environment variable.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Mode, "service.kubernetes.mode", restconfig.ModeAuto, "How to connect to Kubernetes. One of "+strings.Join(restconfig.Modes(), ", ")+". In auto mode a given kubeconfig or context is used, then the in-cluster config when running in a pod, then the given address and finally the kubeconfig at KUBECONFIG or its default location.")
+	newCommand.CobraCommand().PersistentFlags().Float32Var(&f.Kubernetes.QPS, "service.kubernetes.qps", restconfig.DefaultQPS, "Maximum rate of requests per second to Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().DurationVar(&f.Kubernetes.Timeout, "service.kubernetes.timeout", restconfig.DefaultTimeout, "Timeout of a single request to Kubernetes. Zero disables the timeout.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Token, "service.kubernetes.token", "", "Bearer token to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TokenFile, "service.kubernetes.tokenFile", "", "Bearer token file path to use to authenticate with Kubernetes. The file is read again when the token expires.")
+
+	newCommand.cobraCommand.PersistentFlags().BoolVar(&f.GC.DryRun, "gc.dryRun", false, "Only log orphaned objects instead of deleting them.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.GC.Namespace, "gc.namespace", "", "Namespace to collect orphaned objects in. When empty all namespaces are considered.")
+
+	return newCommand, nil
+}
+
+type Command struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Internals.
+	cobraCommand *cobra.Command
+}
+
+func (c *Command) CobraCommand() *cobra.Command {
+	return c.cobraCommand
+}
+
+func (c *Command) Execute(cmd *cobra.Command, args []string) {
+	_ = c.logger.Log("level", "info", "message", "start collecting orphaned objects")
+
+	if f.Kubernetes.InCluster {
+		f.Kubernetes.Mode = restconfig.ModeInCluster
+	}
+
+	err := f.Validate()
+	if err != nil {
+		_ = c.logger.Log("level", "error", "message", "invalid flags", "error", err)
+		os.Exit(1)
+	}
+
+	err = c.execute()
+	if err != nil {
+		_ = c.logger.Log("level", "error", "message", "failed collecting orphaned objects", "error", err)
+		os.Exit(1)
+	}
+
+	_ = c.logger.Log("level", "info", "message", "finished collecting orphaned objects")
+}
+
+func (c *Command) execute() error {
+	var err error
+
+	// TLS files are reloaded as long as the command executes.
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+
+	// Termination signals cancel ctx, which aborts the Kubernetes API calls in
+	// flight as well.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		signalChan := make(chan os.Signal, 1)
+		signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+
+		s := <-signalChan
+		_ = c.logger.Log("level", "debug", "message", "received signal", "signal", s.String())
+		cancel()
+	}()
+
+	var k8sClients *k8sclient.Clients
+	{
+		var restConfig *rest.Config
+		{
+			restConfigConfig := restconfig.DefaultConfig()
+
+			restConfigConfig.Logger = c.logger
+
+			restConfigConfig.Address = f.Kubernetes.Address
+			restConfigConfig.AllowInsecure = f.Kubernetes.AllowInsecure
+			restConfigConfig.Burst = f.Kubernetes.Burst
+			restConfigConfig.Context = f.Kubernetes.Context
+			restConfigConfig.Kubeconfig = f.Kubernetes.Kubeconfig
+			restConfigConfig.Mode = f.Kubernetes.Mode
+			restConfigConfig.QPS = f.Kubernetes.QPS
+			restConfigConfig.Timeout = f.Kubernetes.Timeout
+			restConfigConfig.TLS = restconfig.ConfigTLS{
+				CAFile:  f.Kubernetes.TLS.CaFile,
+				CrtFile: f.Kubernetes.TLS.CrtFile,
+				KeyFile: f.Kubernetes.TLS.KeyFile,
+
+				ReloadInterval: f.Kubernetes.TLS.ReloadInterval,
+			}
+			restConfigConfig.Token = f.Kubernetes.Token
+			restConfigConfig.TokenFile = f.Kubernetes.TokenFile
+
+			restConfig, err = restconfig.New(reloadCtx, restConfigConfig)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			restConfig = restconfig.AbortOnDone(ctx, restConfig)
+		}
+
+		k8sConfig := k8sclient.ClientsConfig{
+			Logger: c.logger,
+
+			RestConfig: restConfig,
+		}
+
+		k8sClients, err = k8sclient.NewClients(k8sConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	var newGC *gc.GC
+	{
+		gcConfig := gc.DefaultConfig()
+
+		gcConfig.DynamicClient = k8sClients.DynClient()
+		gcConfig.K8sClient = k8sClients.K8sClient()
+		gcConfig.Logger = c.logger
+
+		gcConfig.DryRun = f.GC.DryRun
+		gcConfig.Namespace = f.GC.Namespace
+
+		newGC, err = gc.New(gcConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	orphans, err := newGC.Collect(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_ = c.logger.Log("level", "info", "message", "collected orphaned objects", "orphans", orphans, "dryRun", f.GC.DryRun)
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package flag
+
+import (
+	"strings"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-endpoint-updater/command/gc/flag/gc"
+	"github.com/giantswarm/k8s-endpoint-updater/command/gc/flag/kubernetes"
+	"github.com/giantswarm/k8s-endpoint-updater/service/restconfig"
+)
+
+type Flag struct {
+	GC         gc.GC
+	Kubernetes kubernetes.Kubernetes
+}
+
+func (f *Flag) Validate() error {
+	if f.Kubernetes.Burst < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes burst must not be negative")
+	}
+	if f.Kubernetes.QPS < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes qps must not be negative")
+	}
+	if f.Kubernetes.Timeout < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes timeout must not be negative")
+	}
+	if f.Kubernetes.TLS.ReloadInterval < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes tls reload interval must not be negative")
+	}
+	if f.Kubernetes.Token != "" && f.Kubernetes.TokenFile != "" {
+		return microerror.Maskf(invalidFlagsError, "kubernetes token and token file must not be used together")
+	}
+	if !restconfig.IsMode(f.Kubernetes.Mode) {
+		return microerror.Maskf(invalidFlagsError, "kubernetes mode must be one of %s", strings.Join(restconfig.Modes(), ", "))
+	}
+	return nil
+}
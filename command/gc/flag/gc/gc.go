@@ -0,0 +1,6 @@
+package gc
+
+type GC struct {
+	DryRun    bool
+	Namespace string
+}
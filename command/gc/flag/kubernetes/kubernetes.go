@@ -0,0 +1,22 @@
+package kubernetes
+
+import (
+	"time"
+
+	"github.com/giantswarm/k8s-endpoint-updater/command/gc/flag/kubernetes/tls"
+)
+
+type Kubernetes struct {
+	Address       string
+	AllowInsecure bool
+	Burst         int
+	Context       string
+	InCluster     bool
+	Kubeconfig    string
+	Mode          string
+	QPS           float32
+	Timeout       time.Duration
+	TLS           tls.TLS
+	Token         string
+	TokenFile     string
+}
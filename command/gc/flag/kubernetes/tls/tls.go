@@ -0,0 +1,10 @@
+package tls
+
+import "time"
+
+type TLS struct {
+	CaFile         string
+	CrtFile        string
+	KeyFile        string
+	ReloadInterval time.Duration
+}
@@ -0,0 +1,142 @@
+package update
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// apiServer is a local Kubernetes API server backed by a fake clientset. It
+// serves discovery and the pod and event endpoints used by the update
+// command, which is sufficient to run the command end to end without a real
+// cluster.
+type apiServer struct {
+	*httptest.Server
+
+	clientset *fake.Clientset
+}
+
+func newAPIServer(objects ...runtime.Object) *apiServer {
+	s := &apiServer{
+		clientset: fake.NewSimpleClientset(objects...),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+func (s *apiServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api":
+		s.write(w, http.StatusOK, &metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{"v1"},
+			ServerAddressByClientCIDRs: []metav1.ServerAddressByClientCIDR{
+				{ClientCIDR: "0.0.0.0/0", ServerAddress: r.Host},
+			},
+		})
+		return
+	case "/apis":
+		s.write(w, http.StatusOK, &metav1.APIGroupList{
+			TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+		})
+		return
+	case "/api/v1":
+		s.write(w, http.StatusOK, &metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "events", Namespaced: true, Kind: "Event", Verbs: metav1.Verbs{"create", "patch"}},
+				{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get", "patch"}},
+				{Name: "pods/status", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get", "patch"}},
+			},
+		})
+		return
+	}
+
+	// Namespaced resources have the form
+	// /api/v1/namespaces/<namespace>/<resource>[/<name>[/<subresource>]].
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/"), "/")
+	if !strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/") || len(parts) < 2 {
+		s.writeError(w, errors.NewNotFound(corev1.Resource("unknown"), r.URL.Path))
+		return
+	}
+	namespace, resource := parts[0], parts[1]
+
+	switch {
+	case resource == "events":
+		// Events are only recorded for informational purposes, so they are
+		// accepted without being stored.
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			s.writeError(w, errors.NewBadRequest(err.Error()))
+			return
+		}
+		event := &corev1.Event{}
+		_, _, err = scheme.Codecs.UniversalDecoder().Decode(body, nil, event)
+		if err != nil {
+			s.writeError(w, errors.NewBadRequest(err.Error()))
+			return
+		}
+		event.TypeMeta = metav1.TypeMeta{Kind: "Event", APIVersion: "v1"}
+		s.write(w, http.StatusCreated, event)
+	case resource == "pods" && len(parts) >= 3 && r.Method == http.MethodGet:
+		pod, err := s.clientset.CoreV1().Pods(namespace).Get(parts[2], metav1.GetOptions{})
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+		pod.TypeMeta = metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"}
+		s.write(w, http.StatusOK, pod)
+	case resource == "pods" && len(parts) >= 3 && r.Method == http.MethodPatch:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			s.writeError(w, errors.NewBadRequest(err.Error()))
+			return
+		}
+		pod, err := s.clientset.CoreV1().Pods(namespace).Patch(parts[2], types.PatchType(r.Header.Get("Content-Type")), body, parts[3:]...)
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+		pod.TypeMeta = metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"}
+		s.write(w, http.StatusOK, pod)
+	default:
+		s.writeError(w, errors.NewMethodNotSupported(corev1.Resource(resource), r.Method))
+	}
+}
+
+// write responds with the given object encoded as JSON. The type meta of
+// objects read from the fake clientset is not set and has to be set by the
+// caller.
+func (s *apiServer) write(w http.ResponseWriter, code int, obj interface{}) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+func (s *apiServer) writeError(w http.ResponseWriter, err error) {
+	status := errors.NewInternalError(err).ErrStatus
+	if statusErr, ok := err.(errors.APIStatus); ok {
+		status = statusErr.Status()
+	}
+	status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+
+	s.write(w, int(status.Code), &status)
+}
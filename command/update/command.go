@@ -2,21 +2,54 @@
 package update
 
 import (
-	"fmt"
+	"context"
+	"io/ioutil"
 	"net"
 	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
 
+	cenkaltibackoff "github.com/cenkalti/backoff"
 	"github.com/giantswarm/backoff"
 	"github.com/giantswarm/k8sclient"
-	"github.com/giantswarm/k8sclient/k8srestconfig"
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag"
+	"github.com/giantswarm/k8s-endpoint-updater/service/dnsupdater"
+	"github.com/giantswarm/k8s-endpoint-updater/service/health"
+	"github.com/giantswarm/k8s-endpoint-updater/service/leader"
+	"github.com/giantswarm/k8s-endpoint-updater/service/netif"
+	"github.com/giantswarm/k8s-endpoint-updater/service/prober"
 	"github.com/giantswarm/k8s-endpoint-updater/service/provider"
 	"github.com/giantswarm/k8s-endpoint-updater/service/provider/bridge"
+	kubernetesprovider "github.com/giantswarm/k8s-endpoint-updater/service/provider/kubernetes"
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+	annotationpublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/annotation"
+	dnspublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/dns"
+	endpointspublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/endpoints"
+	endpointslicepublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/endpointslice"
+	filepublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/file"
+	objectpublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/object"
+	webhookpublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/webhook"
+	resourceparser "github.com/giantswarm/k8s-endpoint-updater/service/resource"
+	"github.com/giantswarm/k8s-endpoint-updater/service/restconfig"
+	"github.com/giantswarm/k8s-endpoint-updater/service/server"
 	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
 )
 
@@ -24,6 +57,18 @@ const (
 	podNameEnv = "POD_NAME"
 )
 
+const (
+	// cleanupTimeout bounds the cleanup on termination, which cannot use the
+	// already cancelled root context.
+	cleanupTimeout = 30 * time.Second
+	// eventFlushWait is the time given to the event broadcaster to deliver
+	// the events recorded last, e.g. on cleanup, before recording stops.
+	eventFlushWait = 2 * time.Second
+	// serverShutdownTimeout bounds draining the requests in flight of the
+	// HTTP server on termination.
+	serverShutdownTimeout = 5 * time.Second
+)
+
 var (
 	f = &flag.Flag{}
 )
@@ -65,21 +110,93 @@ func New(config Config) (*Command, error) {
 		Run:   newCommand.Execute,
 	}
 
-	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Address, "service.kubernetes.address", "http://127.0.0.1:6443", "Address used to connect to Kubernetes. When empty in-cluster config is created.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.DNS.Name, "dns.name", "", "Name of the A or AAAA record the VM IP is published as via RFC 2136 dynamic DNS updates. All A and AAAA records of the name are replaced. Empty disables DNS updates.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.DNS.Net, "dns.net", dnsupdater.NetUDP, "Transport protocol of DNS updates. One of "+dnsupdater.NetUDP+", "+dnsupdater.NetTCP+".")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.DNS.Server, "dns.server", "", "Address of the authoritative DNS server as host:port.")
+	newCommand.CobraCommand().PersistentFlags().DurationVar(&f.DNS.Timeout, "dns.timeout", dnsupdater.DefaultTimeout, "Timeout of a single DNS update.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.DNS.TSIG.Algorithm, "dns.tsig.algorithm", "hmac-sha256", "TSIG algorithm used to sign DNS updates. One of "+strings.Join(dnsupdater.Algorithms(), ", ")+".")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.DNS.TSIG.Key, "dns.tsig.key", "", "Name of the TSIG key used to sign DNS updates. Updates are not signed when empty.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.DNS.TSIG.Secret, "dns.tsig.secret", "", "Base64 encoded secret of the TSIG key.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.DNS.TSIG.SecretFile, "dns.tsig.secretFile", "", "File path of the base64 encoded secret of the TSIG key.")
+	newCommand.CobraCommand().PersistentFlags().Uint32Var(&f.DNS.TTL, "dns.ttl", dnsupdater.DefaultTTL, "TTL of the published DNS record in seconds.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.DNS.Zone, "dns.zone", "", "Zone of the published DNS record. Defaults to the parent of --dns.name.")
+
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Address, "service.kubernetes.address", "", "Address used to connect to Kubernetes. Overrides the server of the kubeconfig when used together.")
+	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.AllowInsecure, "service.kubernetes.allowInsecure", false, "Whether to allow connecting to Kubernetes via plain HTTP.")
 	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Cluster.Namespace, "service.kubernetes.cluster.namespace", "default", "Namespace of the guest cluster which endpoints should be updated.")
+	newCommand.CobraCommand().PersistentFlags().StringSliceVar(&f.Kubernetes.Cluster.PortOverrides, "service.kubernetes.cluster.portOverrides", nil, "Overrides of VM ports as comma separated <service port>=<VM port> pairs, where the service port is given by name or number. By default VM ports are derived from the target ports of the service.")
 	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Cluster.Service, "service.kubernetes.cluster.service", "", "Name of the service which endpoints should be updated.")
+	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.EndpointSlice, "service.kubernetes.endpointSlice", false, "Whether to manage a dedicated discovery.k8s.io/v1 EndpointSlice of the guest cluster service containing the VM IP.")
 	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.InCluster, "service.kubernetes.inCluster", false, "Whether to use the in-cluster config to authenticate with Kubernetes.")
+	_ = newCommand.CobraCommand().PersistentFlags().MarkDeprecated("service.kubernetes.inCluster", "use --service.kubernetes.mode=inCluster instead")
 	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.CaFile, "service.kubernetes.tls.caFile", "", "Certificate authority file path to use to authenticate with Kubernetes.")
 	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.CrtFile, "service.kubernetes.tls.crtFile", "", "Certificate file path to use to authenticate with Kubernetes.")
 	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TLS.KeyFile, "service.kubernetes.tls.keyFile", "", "Key file path to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().DurationVar(&f.Kubernetes.TLS.ReloadInterval, "service.kubernetes.tls.reloadInterval", restconfig.DefaultReloadInterval, "Interval in which the certificate authority, certificate and key files are checked for changes and reloaded. Zero disables reloading.")
+	newCommand.CobraCommand().PersistentFlags().IntVar(&f.Kubernetes.Burst, "service.kubernetes.burst", restconfig.DefaultBurst, "Maximum burst of requests to Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Context, "service.kubernetes.context", "", "Kubeconfig context to use to connect to Kubernetes. Defaults to the current context.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Kubeconfig, "service.kubernetes.kubeconfig", "", "Kubeconfig file path to use to connect to Kubernetes. Defaults to the value of KUBECONFIG environment variable.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Mode, "service.kubernetes.mode", restconfig.ModeAuto, "How to connect to Kubernetes. One of "+strings.Join(restconfig.Modes(), ", ")+". In auto mode a given kubeconfig or context is used, then the in-cluster config when running in a pod, then the given address and finally the kubeconfig at KUBECONFIG or its default location.")
+	newCommand.CobraCommand().PersistentFlags().Float32Var(&f.Kubernetes.QPS, "service.kubernetes.qps", restconfig.DefaultQPS, "Maximum rate of requests per second to Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().DurationVar(&f.Kubernetes.Timeout, "service.kubernetes.timeout", restconfig.DefaultTimeout, "Timeout of a single request to Kubernetes. Zero disables the timeout.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Token, "service.kubernetes.token", "", "Bearer token to use to authenticate with Kubernetes.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.TokenFile, "service.kubernetes.tokenFile", "", "Bearer token file path to use to authenticate with Kubernetes. The file is read again when the token expires.")
+	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.Pod.Cleanup, "service.kubernetes.pod.cleanup", false, "Whether to remove the published annotations from the guest cluster kvm Kubernetes pod on termination.")
 	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Pod.Name, "service.kubernetes.pod.name", os.Getenv(podNameEnv), "Name of the guest cluster kvm Kubernetes pod. Defaults to the value of POD_NAME environment variable.")
-
+	newCommand.CobraCommand().PersistentFlags().BoolVar(&f.Kubernetes.Pod.ReadinessGate, "service.kubernetes.pod.readinessGate", false, "Whether to maintain the '"+string(updater.ConditionPublished)+"' condition on the guest cluster kvm Kubernetes pod, to be used as readiness gate.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Target.Field, "service.kubernetes.target.field", "", "Field of the target object the VM IP is written to. One of metadata.annotations.<key>, metadata.labels.<key>, spec.<path> or status.<path>, where status fields are written via the status subresource.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Target.Name, "service.kubernetes.target.name", "", "Name of the target object the VM IP is written to. Defaults to --service.kubernetes.pod.name.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Target.Namespace, "service.kubernetes.target.namespace", "", "Namespace of the target object the VM IP is written to. Defaults to --service.kubernetes.cluster.namespace.")
+	newCommand.CobraCommand().PersistentFlags().StringVar(&f.Kubernetes.Target.Resource, "service.kubernetes.target.resource", "", "Resource of an existing object the VM IP is additionally written to as <resource>[.<version>[.<group>]], e.g. vms.v1alpha1.example.com. Empty disables the target.")
+
+	newCommand.cobraCommand.PersistentFlags().BoolVar(&f.LeaderElection.Enabled, "leaderElection.enabled", false, "Whether to elect a leader among redundant updaters of the same KVM pod. Only the leader publishes the VM IP.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.LeaderElection.Identity, "leaderElection.identity", "", "Identity of this updater in the leader election. Has to be unique among all updaters of the same KVM pod. Defaults to the hostname suffixed with a random string.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.LeaderElection.LeaseDuration, "leaderElection.leaseDuration", 15*time.Second, "Duration non-leaders wait before trying to acquire the leadership.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.LeaderElection.Name, "leaderElection.name", "", "Name of the Lease used for the leader election. Defaults to the KVM pod name suffixed with '-endpoint-updater'.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.LeaderElection.RenewDeadline, "leaderElection.renewDeadline", 10*time.Second, "Duration the leader retries renewing the leadership before giving it up.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.LeaderElection.RetryPeriod, "leaderElection.retryPeriod", 2*time.Second, "Duration between leader election attempts.")
+
+	newCommand.cobraCommand.PersistentFlags().IntVar(&f.Probe.FailureThreshold, "probe.failureThreshold", 3, "Number of consecutive failed probes after which a ready VM is published as not ready.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Probe.Kind, "probe.kind", "", "Kind of probe the VM has to pass before it is published as ready. One of 'https', 'tcp' or empty to publish the VM as ready right away.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Probe.Path, "probe.path", "/healthz", "Path requested by HTTPS probes.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.Probe.Period, "probe.period", 10*time.Second, "Interval in which the VM is probed.")
+	newCommand.cobraCommand.PersistentFlags().IntVar(&f.Probe.Port, "probe.port", 443, "Port of the VM being probed.")
+	newCommand.cobraCommand.PersistentFlags().IntVar(&f.Probe.SuccessThreshold, "probe.successThreshold", 1, "Number of consecutive successful probes after which a not ready VM is published as ready.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.Probe.Timeout, "probe.timeout", 5*time.Second, "Timeout of a single probe.")
+
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Bridge.AddressType, "provider.bridge.addressType", bridge.AddressTypePrimary, "Type of the bridge address the VM IP is derived from. One of "+strings.Join(bridge.AddressTypes(), ", ")+".")
+	newCommand.cobraCommand.PersistentFlags().StringSliceVar(&f.Provider.Bridge.CIDRs, "provider.bridge.cidrs", nil, "Comma separated CIDRs the bridge address the VM IP is derived from has to be part of. Any address matches when empty.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Bridge.Label, "provider.bridge.label", "", "Label of the bridge address the VM IP is derived from, e.g. br0:1. Any label matches when empty.")
 	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Bridge.Name, "provider.bridge.name", "", "Bridge name of the guest cluster VM on the host network.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Bridge.NetNS, "provider.bridge.netns", "", "Network namespace of the bridge, given by its name in "+netif.NetNSDir+" or by its path. Defaults to the network namespace of the process.")
+	newCommand.cobraCommand.PersistentFlags().IntVar(&f.Provider.Bridge.PID, "provider.bridge.pid", 0, "PID of a process running in the network namespace of the bridge. Mutually exclusive with --provider.bridge.netns.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.Provider.Bridge.WaitTimeout, "provider.bridge.waitTimeout", bridge.DefaultWaitTimeout, "Time to wait on start for the bridge to exist, be up and have a matching IPV4 address before failing. Zero disables waiting.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Bridge.Scope, "provider.bridge.scope", netif.ScopeName(netif.ScopeGlobal), "Scope of the bridge address the VM IP is derived from. One of "+strings.Join(bridge.Scopes(), ", ")+".")
 	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Env.Prefix, "provider.env.prefix", "K8S_ENDPOINT_UPDATER_POD_", "Prefix of environment variables providing pod names.")
 	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Etcd.Address, "provider.etcd.address", "", "Address used to connect to etcd.")
 	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Etcd.Kind, "provider.etcd.kind", "etcdv2", "Etcd storage client version to use.")
 	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Etcd.Prefix, "provider.etcd.prefix", "", "Prefix of etcd paths providing pod names.")
-	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kind, "provider.kind", "env", "Provider used to lookup pod IPs.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kind, "provider.kind", bridge.Kind, "Provider used to lookup the VM IP. One of "+bridge.Kind+", "+kubernetesprovider.Kind+".")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kubernetes.Annotation, "provider.kubernetes.annotation", "", "Annotation of the object holding the VM IP. Mutually exclusive with --provider.kubernetes.jsonPath.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kubernetes.Family, "provider.kubernetes.family", kubernetesprovider.FamilyAny, "IP family of the VM IP. One of "+strings.Join(kubernetesprovider.Families(), ", ")+".")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kubernetes.JSONPath, "provider.kubernetes.jsonPath", "", "JSONPath template selecting the IPs of the object, e.g. {.status.podIPs[*].ip}. The first IP found is used. Defaults to the one of --provider.kubernetes.source.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kubernetes.Name, "provider.kubernetes.name", "", "Name of the object holding the VM IP. Defaults to --service.kubernetes.pod.name.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kubernetes.Namespace, "provider.kubernetes.namespace", "", "Namespace of the object holding the VM IP. Defaults to --service.kubernetes.cluster.namespace.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kubernetes.Resource, "provider.kubernetes.resource", "", "Resource of the object holding the VM IP as <resource>[.<version>[.<group>]], e.g. virtualmachineinstances.v1.kubevirt.io. Defaults to the one of --provider.kubernetes.source.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Provider.Kubernetes.Source, "provider.kubernetes.source", kubernetesprovider.SourcePod, "Preset of the resource and JSONPath the VM IP is read from. One of "+strings.Join(kubernetesprovider.Sources(), ", ")+".")
+
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Publisher.File.Path, "publisher.file.path", "", "Path of the JSON file the VM IP is written to by the "+filepublisher.Kind+" publisher.")
+	newCommand.cobraCommand.PersistentFlags().StringSliceVar(&f.Publisher.Kinds, "publisher.kinds", []string{annotationpublisher.Kind}, "Comma separated publishers the VM IP is published to. Any of "+strings.Join(flag.PublisherKinds(), ", ")+". The "+endpointslicepublisher.Kind+", "+objectpublisher.Kind+" and "+dnspublisher.Kind+" publishers are also enabled by --service.kubernetes.endpointSlice, --service.kubernetes.target.resource and --dns.name.")
+	newCommand.cobraCommand.PersistentFlags().StringSliceVar(&f.Publisher.Optional, "publisher.optional", nil, "Comma separated publishers which failures are only logged instead of failing the reconciliation.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.Publisher.Webhook.Timeout, "publisher.webhook.timeout", webhookpublisher.DefaultTimeout, "Timeout of a single request of the "+webhookpublisher.Kind+" publisher.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Publisher.Webhook.Token, "publisher.webhook.token", "", "Bearer token sent by the "+webhookpublisher.Kind+" publisher.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Publisher.Webhook.TokenFile, "publisher.webhook.tokenFile", "", "Bearer token file path of the "+webhookpublisher.Kind+" publisher.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Publisher.Webhook.URL, "publisher.webhook.url", "", "URL the VM IP is POSTed to as JSON by the "+webhookpublisher.Kind+" publisher whenever it changes.")
+
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.Reconcile.Interval, "reconcile.interval", time.Minute, "Interval in which the VM IP is looked up and published again. Zero disables periodic reconciliation.")
+	newCommand.cobraCommand.PersistentFlags().DurationVar(&f.Reconcile.MaxAge, "reconcile.maxAge", 5*time.Minute, "Maximum age of the last successful reconciliation for /readyz to report ready. Zero disables the check. Ignored when periodic reconciliation is disabled.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Server.Address, "server.address", "", "Address the HTTP server serving /metrics, /healthz and /readyz listens on, e.g. ':8000'. When empty no server is started.")
+	newCommand.cobraCommand.PersistentFlags().StringVar(&f.Status.Kind, "status.kind", "", "Kind of object the structured status of the target is written to. One of 'configmap', 'endpointbinding' or empty to disable status reporting.")
 
 	return newCommand, nil
 }
@@ -97,46 +214,115 @@ func (c *Command) CobraCommand() *cobra.Command {
 }
 
 func (c *Command) Execute(cmd *cobra.Command, args []string) {
-	_ = c.logger.Log("info", "start adding annotations to KVM pod")
+	_ = c.logger.Log("level", "info", "message", "start adding annotations to KVM pod")
+
+	if f.LeaderElection.Enabled && f.LeaderElection.Identity == "" {
+		identity, err := leader.DefaultIdentity()
+		if err != nil {
+			_ = c.logger.Log("level", "error", "message", "failed defaulting leader election identity", "error", err)
+			os.Exit(1)
+		}
+		f.LeaderElection.Identity = identity
+	}
+	if f.LeaderElection.Name == "" && f.Kubernetes.Pod.Name != "" {
+		f.LeaderElection.Name = f.Kubernetes.Pod.Name + "-endpoint-updater"
+	}
+	if f.Kubernetes.InCluster {
+		f.Kubernetes.Mode = restconfig.ModeInCluster
+	}
+	if f.Kubernetes.Target.Name == "" {
+		f.Kubernetes.Target.Name = f.Kubernetes.Pod.Name
+	}
+	if f.Kubernetes.Target.Namespace == "" {
+		f.Kubernetes.Target.Namespace = f.Kubernetes.Cluster.Namespace
+	}
+	// Publishers enabled by their own flags before publishers were
+	// configurable are still enabled by them.
+	if f.Kubernetes.EndpointSlice && !contains(f.Publisher.Kinds, endpointslicepublisher.Kind) {
+		f.Publisher.Kinds = append(f.Publisher.Kinds, endpointslicepublisher.Kind)
+	}
+	if f.Kubernetes.Target.Resource != "" && !contains(f.Publisher.Kinds, objectpublisher.Kind) {
+		f.Publisher.Kinds = append(f.Publisher.Kinds, objectpublisher.Kind)
+	}
+	if f.DNS.Name != "" && !contains(f.Publisher.Kinds, dnspublisher.Kind) {
+		f.Publisher.Kinds = append(f.Publisher.Kinds, dnspublisher.Kind)
+	}
+	if f.Provider.Kubernetes.Name == "" {
+		f.Provider.Kubernetes.Name = f.Kubernetes.Pod.Name
+	}
+	if f.Provider.Kubernetes.Namespace == "" {
+		f.Provider.Kubernetes.Namespace = f.Kubernetes.Cluster.Namespace
+	}
 
 	err := f.Validate()
 	if err != nil {
-		_ = c.logger.Log("error", fmt.Sprintf("%#v", microerror.Mask(err)))
+		_ = c.logger.Log("level", "error", "message", "invalid flags", "error", err)
 		os.Exit(1)
 	}
 
 	err = c.execute()
 	if err != nil {
-		_ = c.logger.Log("error", fmt.Sprintf("%#v", microerror.Mask(err)))
+		_ = c.logger.Log("level", "error", "message", "failed adding annotations to KVM pod", "error", err)
 		os.Exit(1)
 	}
 
-	_ = c.logger.Log("info", "finished adding annotations to KVM pod")
+	_ = c.logger.Log("level", "info", "message", "finished adding annotations to KVM pod")
 }
 
 func (c *Command) execute() error {
 	var err error
 
+	// TLS files are reloaded as long as the command executes.
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+
+	// Termination signals cancel ctx, which aborts the Kubernetes API calls in
+	// flight as well.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		signalChan := make(chan os.Signal, 1)
+		signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+
+		s := <-signalChan
+		_ = c.logger.Log("level", "debug", "message", "received signal", "signal", s.String())
+		cancel()
+	}()
+
 	var k8sClients *k8sclient.Clients
+	var k8sWatchClient kubernetes.Interface
 	{
 		var restConfig *rest.Config
 		{
-			c := k8srestconfig.Config{
-				Logger: c.logger,
-
-				Address:   f.Kubernetes.Address,
-				InCluster: f.Kubernetes.InCluster,
-				TLS: k8srestconfig.ConfigTLS{
-					CAFile:  f.Kubernetes.TLS.CaFile,
-					CrtFile: f.Kubernetes.TLS.CrtFile,
-					KeyFile: f.Kubernetes.TLS.KeyFile,
-				},
+			restConfigConfig := restconfig.DefaultConfig()
+
+			restConfigConfig.Logger = c.logger
+
+			restConfigConfig.Address = f.Kubernetes.Address
+			restConfigConfig.AllowInsecure = f.Kubernetes.AllowInsecure
+			restConfigConfig.Burst = f.Kubernetes.Burst
+			restConfigConfig.Context = f.Kubernetes.Context
+			restConfigConfig.Kubeconfig = f.Kubernetes.Kubeconfig
+			restConfigConfig.Mode = f.Kubernetes.Mode
+			restConfigConfig.QPS = f.Kubernetes.QPS
+			restConfigConfig.Timeout = f.Kubernetes.Timeout
+			restConfigConfig.TLS = restconfig.ConfigTLS{
+				CAFile:  f.Kubernetes.TLS.CaFile,
+				CrtFile: f.Kubernetes.TLS.CrtFile,
+				KeyFile: f.Kubernetes.TLS.KeyFile,
+
+				ReloadInterval: f.Kubernetes.TLS.ReloadInterval,
 			}
+			restConfigConfig.Token = f.Kubernetes.Token
+			restConfigConfig.TokenFile = f.Kubernetes.TokenFile
 
-			restConfig, err = k8srestconfig.New(c)
+			restConfig, err = restconfig.New(reloadCtx, restConfigConfig)
 			if err != nil {
 				return microerror.Mask(err)
 			}
+
+			restConfig = restconfig.AbortOnDone(ctx, restConfig)
 		}
 
 		k8sConfig := k8sclient.ClientsConfig{
@@ -149,62 +335,412 @@ func (c *Command) execute() error {
 		if err != nil {
 			return microerror.Mask(err)
 		}
+
+		k8sWatchClient, err = kubernetes.NewForConfig(restconfig.ForWatches(restConfig))
+		if err != nil {
+			return microerror.Mask(err)
+		}
 	}
 
+	// The provider looks up the VM IP, either by deriving it from the bridge
+	// on the host network or by reading it from an object in Kubernetes.
 	var newProvider provider.Provider
-	{
-		bridgeConfig := bridge.DefaultConfig()
+	var bridgeProvider *bridge.Provider
+	switch f.Provider.Kind {
+	case bridge.Kind:
+		// The network interface source provides the bridge, optionally from
+		// another network namespace than the one we run in.
+		var netifSource netif.Source
+		{
+			netifConfig := netif.DefaultConfig()
 
-		bridgeConfig.Logger = c.logger
+			netifConfig.NetNS = f.Provider.Bridge.NetNS
+			netifConfig.PID = f.Provider.Bridge.PID
 
-		bridgeConfig.BridgeName = f.Provider.Bridge.Name
+			netifSource, err = netif.New(netifConfig)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		{
+			bridgeConfig := bridge.DefaultConfig()
+
+			bridgeConfig.Logger = c.logger
+			bridgeConfig.Source = netifSource
+
+			bridgeConfig.AddressType = f.Provider.Bridge.AddressType
+			bridgeConfig.BridgeName = f.Provider.Bridge.Name
+			bridgeConfig.Label = f.Provider.Bridge.Label
+			bridgeConfig.Scope = f.Provider.Bridge.Scope
+			bridgeConfig.WaitTimeout = f.Provider.Bridge.WaitTimeout
+
+			for _, cidr := range f.Provider.Bridge.CIDRs {
+				_, n, err := net.ParseCIDR(cidr)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+				bridgeConfig.Networks = append(bridgeConfig.Networks, n)
+			}
+
+			bridgeProvider, err = bridge.New(bridgeConfig)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+
+		newProvider = bridgeProvider
+	case kubernetesprovider.Kind:
+		resource, jsonPath, _ := kubernetesprovider.Source(f.Provider.Kubernetes.Source)
+		if f.Provider.Kubernetes.Resource != "" {
+			resource, err = resourceparser.Parse(f.Provider.Kubernetes.Resource)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+		}
+		if f.Provider.Kubernetes.JSONPath != "" {
+			jsonPath = f.Provider.Kubernetes.JSONPath
+		}
+		if f.Provider.Kubernetes.Annotation != "" {
+			jsonPath = ""
+		}
+
+		kubernetesConfig := kubernetesprovider.DefaultConfig()
+
+		kubernetesConfig.DynamicClient = k8sClients.DynClient()
+		kubernetesConfig.Logger = c.logger
 
-		newProvider, err = bridge.New(bridgeConfig)
+		kubernetesConfig.Annotation = f.Provider.Kubernetes.Annotation
+		kubernetesConfig.Family = f.Provider.Kubernetes.Family
+		kubernetesConfig.JSONPath = jsonPath
+		kubernetesConfig.Name = f.Provider.Kubernetes.Name
+		kubernetesConfig.Namespace = f.Provider.Kubernetes.Namespace
+		kubernetesConfig.Resource = resource
+
+		newProvider, err = kubernetesprovider.New(kubernetesConfig)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 	}
 
+	// The event recorder is used to record the endpoint history on the KVM pod.
+	var eventRecorder record.EventRecorder
+	var eventWatcher watch.Interface
+	{
+		eventBroadcaster := record.NewBroadcaster()
+		eventWatcher = eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: k8sClients.K8sClient().CoreV1().Events("")})
+
+		eventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: c.cobraCommand.Root().Name()})
+	}
+	defer stopEvents(eventWatcher)
+
 	// We need to create the updater which is able to update Kubernetes endpoints.
 	var newUpdater *updater.Updater
 	{
+		portOverrides, err := updater.ParsePortOverrides(f.Kubernetes.Cluster.PortOverrides)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
 		updaterConfig := updater.DefaultConfig()
 
+		updaterConfig.DynamicClient = k8sClients.DynClient()
+		updaterConfig.EventRecorder = eventRecorder
 		updaterConfig.K8sClient = k8sClients.K8sClient()
 		updaterConfig.Logger = c.logger
 
+		updaterConfig.PortOverrides = portOverrides
+		updaterConfig.StatusKind = f.Status.Kind
+
 		newUpdater, err = updater.New(updaterConfig)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 	}
 
-	// Here we lookup the VM IP we are interested in.
-	var podIP net.IP
+	// The health tracker backs the readiness probe. Without periodic
+	// reconciliation the last reconciliation can never be fresh, so a single
+	// successful publication is sufficient.
+	var newHealth *health.Health
 	{
-		action := func() error {
-			podIP, err = newProvider.Lookup()
+		healthConfig := health.DefaultConfig()
 
-			if err != nil {
-				return microerror.Mask(err)
-			}
+		if f.Reconcile.Interval != 0 {
+			healthConfig.MaxAge = f.Reconcile.MaxAge
+		}
 
-			return nil
+		newHealth, err = health.New(healthConfig)
+		if err != nil {
+			return microerror.Mask(err)
 		}
+	}
 
-		err := backoff.Retry(action, backoff.NewExponential(backoff.MediumMaxWait, backoff.LongMaxInterval))
+	// The server exposing metrics and probes is optional and only started when
+	// an address is configured.
+	if f.Server.Address != "" {
+		serverConfig := server.DefaultConfig()
+
+		serverConfig.Health = newHealth
+		serverConfig.Logger = c.logger
+
+		serverConfig.Address = f.Server.Address
+
+		newServer, err := server.New(serverConfig)
 		if err != nil {
 			return microerror.Mask(err)
 		}
 
-		_ = c.logger.Log("debug", fmt.Sprintf("found pod info for service '%s'", f.Kubernetes.Cluster.Service), "ip", podIP.String())
+		newServer.Boot()
+		defer c.shutdownServer(newServer)
+	}
+
+	// The prober is optional. Without it the VM is published as ready right
+	// away.
+	var newProber *prober.Prober
+	if f.Probe.Kind != "" {
+		proberConfig := prober.DefaultConfig()
+
+		proberConfig.Logger = c.logger
 
+		proberConfig.FailureThreshold = f.Probe.FailureThreshold
+		proberConfig.Kind = f.Probe.Kind
+		proberConfig.Path = f.Probe.Path
+		proberConfig.Period = f.Probe.Period
+		proberConfig.Port = f.Probe.Port
+		proberConfig.SuccessThreshold = f.Probe.SuccessThreshold
+		proberConfig.Timeout = f.Probe.Timeout
+
+		newProber, err = prober.New(proberConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// The sinks are the publishers the VM IP is fanned out to.
+	var sinks []sink
+	for _, kind := range f.Publisher.Kinds {
+		p, err := c.newPublisher(kind, newUpdater)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		sinks = append(sinks, sink{
+			kind:      kind,
+			optional:  contains(f.Publisher.Optional, kind),
+			publisher: p,
+		})
+	}
+
+	r := &reconciler{
+		health:         newHealth,
+		k8sWatchClient: k8sWatchClient,
+		logger:         c.logger.With("namespace", f.Kubernetes.Cluster.Namespace, "pod", f.Kubernetes.Pod.Name, "service", f.Kubernetes.Cluster.Service, "provider", f.Provider.Kind),
+		prober:         newProber,
+		provider:       newProvider,
+		kind:           f.Provider.Kind,
+		sinks:          sinks,
+		updater:        newUpdater,
+	}
+
+	// The bridge might not exist yet when we start together with the VM, so we
+	// wait for it before the initial reconciliation.
+	if bridgeProvider != nil {
+		err = bridgeProvider.Wait(ctx)
+		if ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return microerror.Mask(err)
+		}
 	}
 
-	// Use the updater to actually add annotations to the kvm pod.
+	if !f.LeaderElection.Enabled {
+		err = c.run(ctx, ctx, r, newUpdater)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	}
+
+	var newLeader *leader.Leader
 	{
+		leaderConfig := leader.DefaultConfig()
+
+		leaderConfig.K8sClient = k8sClients.K8sClient()
+		leaderConfig.Logger = c.logger
+
+		leaderConfig.Identity = f.LeaderElection.Identity
+		leaderConfig.LeaseDuration = f.LeaderElection.LeaseDuration
+		leaderConfig.Name = f.LeaderElection.Name
+		leaderConfig.Namespace = f.Kubernetes.Cluster.Namespace
+		leaderConfig.RenewDeadline = f.LeaderElection.RenewDeadline
+		leaderConfig.RetryPeriod = f.LeaderElection.RetryPeriod
+
+		newLeader, err = leader.New(leaderConfig)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	// Only the leader reconciles. Losing the leadership makes us exit with an
+	// error so that we get restarted and take part in the next election.
+	var runErr error
+	err = lead(ctx, newLeader, newHealth, func(leaderCtx context.Context) {
+		runErr = c.run(leaderCtx, ctx, r, newUpdater)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if runErr != nil {
+		return microerror.Mask(runErr)
+	}
+
+	return nil
+}
+
+// lead runs f once the leadership got acquired. Standby replicas report
+// readiness while they wait for the Lease, so that rollouts of redundant
+// replicas do not stall on them. The leader is ready once it reconciled.
+func lead(ctx context.Context, l *leader.Leader, h *health.Health, f func(ctx context.Context)) error {
+	h.Standby(true)
+
+	err := l.Run(ctx, func(ctx context.Context) {
+		h.Standby(false)
+		f(ctx)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// run reconciles until ctx is done. When rootCtx is done as well the process
+// is terminating and the annotations are cleaned up if configured.
+func (c *Command) run(ctx context.Context, rootCtx context.Context, r *reconciler, u *updater.Updater) error {
+	// The initial reconciliation has to succeed. Otherwise the KVM pod would never
+	// be annotated and we fail.
+	err := r.Reconcile(ctx, backoff.NewExponential(backoff.MediumMaxWait, backoff.LongMaxInterval))
+	if ctx.Err() != nil {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	// Readiness changes of the VM are published right away instead of waiting
+	// for the next interval.
+	var proberChan <-chan struct{}
+	if r.prober != nil {
+		go r.prober.Boot(ctx)
+
+		proberChan = r.prober.Changes()
+	}
+
+	// Endpoint ports are derived from the guest cluster service, so changed
+	// service ports are published right away as well, given any publisher
+	// publishes ports.
+	var serviceChan <-chan struct{}
+	if contains(f.Publisher.Kinds, endpointspublisher.Kind) || contains(f.Publisher.Kinds, endpointslicepublisher.Kind) {
+		serviceChan = watchServicePorts(ctx, r.k8sWatchClient, f.Kubernetes.Cluster.Namespace, f.Kubernetes.Cluster.Service)
+	}
+
+	// Further reconciliations keep the annotation in sync with the VM IP. Failures
+	// are only logged because the next interval will try again.
+	var tickerChan <-chan time.Time
+	if f.Reconcile.Interval == 0 {
+		_ = c.logger.Log("level", "debug", "message", "waiting forever")
+	} else {
+		ticker := time.NewTicker(f.Reconcile.Interval)
+		defer ticker.Stop()
+
+		tickerChan = ticker.C
+	}
+
+	for {
+		select {
+		case <-proberChan:
+			err := r.Reconcile(ctx, backoff.NewExponential(backoff.ShortMaxWait, backoff.ShortMaxInterval))
+			if ctx.Err() == nil && err != nil {
+				_ = r.logger.Log("level", "error", "message", "failed reconciling", "error", err)
+			}
+		case <-serviceChan:
+			err := r.Reconcile(ctx, backoff.NewExponential(backoff.ShortMaxWait, backoff.ShortMaxInterval))
+			if ctx.Err() == nil && err != nil {
+				_ = r.logger.Log("level", "error", "message", "failed reconciling", "error", err)
+			}
+		case <-tickerChan:
+			err := r.Reconcile(ctx, backoff.NewExponential(backoff.ShortMaxWait, backoff.ShortMaxInterval))
+			if ctx.Err() == nil && err != nil {
+				_ = r.logger.Log("level", "error", "message", "failed reconciling", "error", err)
+			}
+		case <-ctx.Done():
+			if rootCtx.Err() != nil && f.Kubernetes.Pod.Cleanup {
+				err := c.cleanup(u, r.sinks)
+				if err != nil {
+					return microerror.Mask(err)
+				}
+			}
+
+			return nil
+		}
+	}
+}
+
+// watchServicePorts watches the given guest cluster service until ctx is done.
+// The returned channel receives whenever the ports of the service change.
+func watchServicePorts(ctx context.Context, k8sClient kubernetes.Interface, namespace, service string) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(
+		k8sClient,
+		0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", service).String()
+		}),
+	)
+
+	informerFactory.Core().V1().Services().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldService, ok := oldObj.(*corev1.Service)
+			if !ok {
+				return
+			}
+			newService, ok := newObj.(*corev1.Service)
+			if !ok {
+				return
+			}
+
+			if reflect.DeepEqual(oldService.Spec.Ports, newService.Spec.Ports) {
+				return
+			}
+
+			// A pending change is sufficient, so changes are not queued up.
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		},
+	})
+
+	informerFactory.Start(ctx.Done())
+
+	return changes
+}
+
+// cleanup withdraws the VM IP from all sinks. It runs after the root context
+// is done and is therefore bounded by its own timeout. Every sink is retried
+// on its own and failures of optional sinks are only logged. A failure to
+// update the pod condition does not keep the sinks from being withdrawn from.
+func (c *Command) cleanup(u *updater.Updater, sinks []sink) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	var failed []string
+
+	if f.Kubernetes.Pod.ReadinessGate {
 		action := func() error {
-			err := newUpdater.AddAnnotations(f.Kubernetes.Cluster.Namespace, f.Kubernetes.Cluster.Service, f.Kubernetes.Pod.Name, podIP)
+			err := u.UpdatePodCondition(ctx, f.Kubernetes.Cluster.Namespace, f.Kubernetes.Pod.Name, corev1.ConditionFalse, updater.ReasonWithdrawn, "Endpoint got withdrawn on termination.")
 			if err != nil {
 				return microerror.Mask(err)
 			}
@@ -212,14 +748,228 @@ func (c *Command) execute() error {
 			return nil
 		}
 
-		err := backoff.Retry(action, backoff.NewExponential(backoff.MediumMaxWait, backoff.LongMaxInterval))
+		err := backoff.Retry(action, cenkaltibackoff.WithContext(backoff.NewMaxRetries(3, backoff.ShortMaxInterval), ctx))
 		if err != nil {
-			return microerror.Mask(err)
+			_ = c.logger.Log("level", "error", "message", "failed updating pod condition", "error", err)
+			failed = append(failed, "pod condition")
+		}
+	}
+
+	for _, s := range sinks {
+		s := s
+
+		action := func() error {
+			err := s.publisher.Withdraw(ctx)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return nil
+		}
+
+		err := backoff.Retry(action, cenkaltibackoff.WithContext(backoff.NewMaxRetries(3, backoff.ShortMaxInterval), ctx))
+		if s.optional && err != nil {
+			_ = c.logger.Log("level", "warning", "message", "failed withdrawing VM IP from optional publisher", "publisher", s.kind, "error", err)
+			continue
+		} else if err != nil {
+			_ = c.logger.Log("level", "error", "message", "failed withdrawing VM IP", "publisher", s.kind, "error", err)
+			failed = append(failed, s.kind)
+			continue
+		}
+
+		_ = c.logger.Log("level", "debug", "message", "withdrew VM IP", "publisher", s.kind, "namespace", f.Kubernetes.Cluster.Namespace, "pod", f.Kubernetes.Pod.Name)
+	}
+
+	if len(failed) != 0 {
+		return microerror.Maskf(executionFailedError, "withdrawing VM IP from %s failed", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// newPublisher creates the publisher of the given kind.
+func (c *Command) newPublisher(kind string, u *updater.Updater) (publisher.Publisher, error) {
+	target := publisher.Target{
+		Namespace: f.Kubernetes.Cluster.Namespace,
+		Pod:       f.Kubernetes.Pod.Name,
+		Service:   f.Kubernetes.Cluster.Service,
+	}
+
+	switch kind {
+	case annotationpublisher.Kind:
+		annotationConfig := annotationpublisher.DefaultConfig()
+
+		annotationConfig.Updater = u
+
+		annotationConfig.Target = target
+
+		p, err := annotationpublisher.New(annotationConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return p, nil
+	case dnspublisher.Kind:
+		secret := f.DNS.TSIG.Secret
+		if f.DNS.TSIG.SecretFile != "" {
+			b, err := ioutil.ReadFile(f.DNS.TSIG.SecretFile)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			secret = strings.TrimSpace(string(b))
+		}
+
+		dnsConfig := dnsupdater.DefaultConfig()
+
+		dnsConfig.Logger = c.logger
+
+		dnsConfig.Name = f.DNS.Name
+		dnsConfig.Net = f.DNS.Net
+		dnsConfig.Server = f.DNS.Server
+		dnsConfig.Timeout = f.DNS.Timeout
+		dnsConfig.TSIGAlgorithm = f.DNS.TSIG.Algorithm
+		dnsConfig.TSIGKey = f.DNS.TSIG.Key
+		dnsConfig.TSIGSecret = secret
+		dnsConfig.TTL = f.DNS.TTL
+		dnsConfig.Zone = f.DNS.Zone
+
+		dnsUpdater, err := dnsupdater.New(dnsConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		dnsPublisherConfig := dnspublisher.DefaultConfig()
+
+		dnsPublisherConfig.DNSUpdater = dnsUpdater
+
+		p, err := dnspublisher.New(dnsPublisherConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return p, nil
+	case endpointspublisher.Kind:
+		endpointsConfig := endpointspublisher.DefaultConfig()
+
+		endpointsConfig.Updater = u
+
+		endpointsConfig.Target = target
+
+		p, err := endpointspublisher.New(endpointsConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
 		}
 
-		_ = c.logger.Log("debug", fmt.Sprintf("added annotations to the KVM pod '%s'", f.Kubernetes.Pod.Name))
+		return p, nil
+	case endpointslicepublisher.Kind:
+		endpointSliceConfig := endpointslicepublisher.DefaultConfig()
+
+		endpointSliceConfig.Updater = u
+
+		endpointSliceConfig.Target = target
+
+		p, err := endpointslicepublisher.New(endpointSliceConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return p, nil
+	case filepublisher.Kind:
+		fileConfig := filepublisher.DefaultConfig()
+
+		fileConfig.Logger = c.logger
+
+		fileConfig.Path = f.Publisher.File.Path
+		fileConfig.Target = target
+
+		p, err := filepublisher.New(fileConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return p, nil
+	case objectpublisher.Kind:
+		resource, err := resourceparser.Parse(f.Kubernetes.Target.Resource)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		objectConfig := objectpublisher.DefaultConfig()
+
+		objectConfig.Updater = u
+
+		objectConfig.Object = updater.ObjectTarget{
+			Field:     f.Kubernetes.Target.Field,
+			Name:      f.Kubernetes.Target.Name,
+			Namespace: f.Kubernetes.Target.Namespace,
+			Resource:  resource,
+		}
+
+		p, err := objectpublisher.New(objectConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return p, nil
+	case webhookpublisher.Kind:
+		token := f.Publisher.Webhook.Token
+		if f.Publisher.Webhook.TokenFile != "" {
+			b, err := ioutil.ReadFile(f.Publisher.Webhook.TokenFile)
+			if err != nil {
+				return nil, microerror.Mask(err)
+			}
+			token = strings.TrimSpace(string(b))
+		}
+
+		webhookConfig := webhookpublisher.DefaultConfig()
+
+		webhookConfig.Logger = c.logger
+
+		webhookConfig.Target = target
+		webhookConfig.Timeout = f.Publisher.Webhook.Timeout
+		webhookConfig.Token = token
+		webhookConfig.URL = f.Publisher.Webhook.URL
+
+		p, err := webhookpublisher.New(webhookConfig)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return p, nil
+	}
+
+	return nil, microerror.Maskf(invalidConfigError, "publisher kind %q is unknown", kind)
+}
+
+// shutdownServer gracefully stops the given server, giving it
+// serverShutdownTimeout to drain the requests in flight.
+func (c *Command) shutdownServer(s *server.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	err := s.Shutdown(ctx)
+	if err != nil {
+		_ = c.logger.Log("level", "warning", "message", "failed shutting down HTTP server", "error", err)
 	}
-	_ = c.logger.Log("debug", "waiting forever")
-	// wait forever
-	select {}
+}
+
+// stopEvents stops recording events to the API. Events are delivered
+// asynchronously, so the broadcaster is given eventFlushWait to deliver the
+// events recorded last before. The broadcaster itself is not shut down, as
+// event recorders send to it from goroutines of their own which would race
+// with closing it.
+func stopEvents(w watch.Interface) {
+	time.Sleep(eventFlushWait)
+
+	w.Stop()
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
 }
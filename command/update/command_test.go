@@ -0,0 +1,319 @@
+package update
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/health"
+	"github.com/giantswarm/k8s-endpoint-updater/service/leader"
+)
+
+// Test_Command_Execute runs the update command end to end against a local API
+// server. The loopback interface serves as bridge, so the VM IP published is
+// the address following 127.0.0.1, which has host scope. The VM IP is
+// published to the pod annotations and a file. Terminating the command has to
+// withdraw it from both again.
+func Test_Command_Execute(t *testing.T) {
+	s := newAPIServer(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kvm",
+			Namespace: "default",
+		},
+	})
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "endpoint.json")
+
+	c := DefaultConfig()
+	c.Logger = microloggertest.New()
+
+	newCommand, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = newCommand.CobraCommand().ParseFlags([]string{
+		"--provider.bridge.name=lo",
+		"--provider.bridge.scope=host",
+		"--publisher.file.path=" + path,
+		"--publisher.kinds=annotation,file",
+		"--reconcile.interval=0",
+		"--service.kubernetes.address=" + s.URL,
+		"--service.kubernetes.allowInsecure",
+		"--service.kubernetes.cluster.namespace=default",
+		"--service.kubernetes.cluster.service=master",
+		"--service.kubernetes.mode=address",
+		"--service.kubernetes.pod.cleanup",
+		"--service.kubernetes.pod.name=kvm",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The command terminates on SIGTERM. The signal is caught here as well so
+	// that it never kills the test, even when sent before the command started
+	// listening for it.
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGTERM)
+	defer signal.Stop(signalChan)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- newCommand.execute()
+	}()
+
+	waitFor(t, errChan, func() bool {
+		pod := getPod(t, s)
+		return pod.Annotations["endpoint.kvm.giantswarm.io/ip"] == "127.0.0.2" && pod.Annotations["endpoint.kvm.giantswarm.io/ready"] == "true"
+	})
+	waitFor(t, errChan, func() bool {
+		b, err := ioutil.ReadFile(path)
+		return err == nil && strings.Contains(string(b), `"ip": "127.0.0.2"`)
+	})
+
+	for done := false; !done; {
+		err := syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case err := <-errChan:
+			if err != nil {
+				t.Fatal(err)
+			}
+			done = true
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	pod := getPod(t, s)
+	if _, ok := pod.Annotations["endpoint.kvm.giantswarm.io/ip"]; ok {
+		t.Fatalf("annotations == %v, want published annotations to be removed", pod.Annotations)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("error == %#v, want published file to be removed", err)
+	}
+}
+
+func getPod(t *testing.T, s *apiServer) *corev1.Pod {
+	t.Helper()
+
+	pod, err := s.clientset.CoreV1().Pods("default").Get("kvm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pod
+}
+
+// waitFor polls the given condition until it is met. It fails when the
+// command returns early or the condition is not met within 10 seconds.
+func waitFor(t *testing.T, errChan <-chan error, condition func() bool) {
+	t.Helper()
+
+	timeout := time.After(10 * time.Second)
+	for !condition() {
+		select {
+		case err := <-errChan:
+			t.Fatalf("command returned early with error %#v", err)
+		case <-timeout:
+			t.Fatalf("condition not met within 10 seconds")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Test_Command_watchServicePorts verifies that changed ports of the guest
+// cluster service are signalled, while other changes are not.
+func Test_Command_watchServicePorts(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "master",
+			Namespace: "default",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "https", Port: 443},
+			},
+		},
+	}
+	k8sClient := fake.NewSimpleClientset(svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := watchServicePorts(ctx, k8sClient, "default", "master")
+
+	// The informer has to observe the service before updates are delivered.
+	time.Sleep(500 * time.Millisecond)
+
+	svc = svc.DeepCopy()
+	svc.Labels = map[string]string{"changed": "labels"}
+	_, err := k8sClient.CoreV1().Services("default").Update(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+		t.Fatal("changed labels must not be signalled")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	svc = svc.DeepCopy()
+	svc.Spec.Ports[0].Port = 6443
+	_, err = k8sClient.CoreV1().Services("default").Update(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("changed ports must be signalled")
+	}
+}
+
+// Test_Command_lead verifies that standby replicas are ready while they wait
+// for the Lease and that the leader is not ready before it reconciled.
+func Test_Command_lead(t *testing.T) {
+	testCases := []struct {
+		name          string
+		holder        string
+		expectedLead  bool
+		expectedReady bool
+	}{
+		{
+			name:          "case 0: standby replica is ready while the Lease is held by another replica",
+			holder:        "b",
+			expectedLead:  false,
+			expectedReady: true,
+		},
+		{
+			name:          "case 1: leader is not ready before it reconciled",
+			holder:        "",
+			expectedLead:  true,
+			expectedReady: false,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var objects []runtime.Object
+			if tc.holder != "" {
+				leaseDuration := int32(60)
+				now := metav1.NewMicroTime(time.Now())
+				objects = append(objects, &coordinationv1.Lease{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kvm-endpoint-updater",
+						Namespace: "default",
+					},
+					Spec: coordinationv1.LeaseSpec{
+						HolderIdentity:       &tc.holder,
+						LeaseDurationSeconds: &leaseDuration,
+						AcquireTime:          &now,
+						RenewTime:            &now,
+					},
+				})
+			}
+
+			var newLeader *leader.Leader
+			{
+				c := leader.DefaultConfig()
+				c.K8sClient = fake.NewSimpleClientset(objects...)
+				c.Logger = microloggertest.New()
+
+				c.Identity = "a"
+				c.LeaseDuration = 2 * time.Second
+				c.Name = "kvm-endpoint-updater"
+				c.Namespace = "default"
+				c.RenewDeadline = time.Second
+				c.RetryPeriod = 100 * time.Millisecond
+
+				var err error
+				newLeader, err = leader.New(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var newHealth *health.Health
+			{
+				c := health.DefaultConfig()
+				c.MaxAge = time.Minute
+
+				var err error
+				newHealth, err = health.New(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			readyChan := make(chan bool, 1)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- lead(ctx, newLeader, newHealth, func(ctx context.Context) {
+					readyChan <- newHealth.Ready() == nil
+					<-ctx.Done()
+				})
+			}()
+
+			// Without acquiring the leadership readiness is checked while
+			// waiting for the Lease.
+			var led bool
+			var ready bool
+			select {
+			case ready = <-readyChan:
+				led = true
+				// The elector renews right after acquiring the Lease. Stopping
+				// it in the middle of that races within client-go.
+				time.Sleep(50 * time.Millisecond)
+			case <-time.After(500 * time.Millisecond):
+				ready = newHealth.Ready() == nil
+			}
+
+			cancel()
+			err := <-errChan
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if led != tc.expectedLead {
+				t.Fatalf("led == %t, want %t", led, tc.expectedLead)
+			}
+			if ready != tc.expectedReady {
+				t.Fatalf("ready == %t, want %t", ready, tc.expectedReady)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package dns
+
+import (
+	"time"
+
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/dns/tsig"
+)
+
+type DNS struct {
+	Name    string
+	Net     string
+	Server  string
+	Timeout time.Duration
+	TSIG    tsig.TSIG
+	TTL     uint32
+	Zone    string
+}
@@ -0,0 +1,8 @@
+package tsig
+
+type TSIG struct {
+	Algorithm  string
+	Key        string
+	Secret     string
+	SecretFile string
+}
@@ -1,18 +1,88 @@
 package flag
 
 import (
+	"net"
+	"strings"
+
 	"github.com/giantswarm/microerror"
 
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/dns"
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/kubernetes"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/leaderelection"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/probe"
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/provider"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/publisher"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/reconcile"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/server"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/status"
+	"github.com/giantswarm/k8s-endpoint-updater/service/dnsupdater"
+	"github.com/giantswarm/k8s-endpoint-updater/service/provider/bridge"
+	kubernetesprovider "github.com/giantswarm/k8s-endpoint-updater/service/provider/kubernetes"
+	annotationpublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/annotation"
+	dnspublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/dns"
+	endpointspublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/endpoints"
+	endpointslicepublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/endpointslice"
+	filepublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/file"
+	objectpublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/object"
+	webhookpublisher "github.com/giantswarm/k8s-endpoint-updater/service/publisher/webhook"
+	"github.com/giantswarm/k8s-endpoint-updater/service/resource"
+	"github.com/giantswarm/k8s-endpoint-updater/service/restconfig"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
 )
 
 type Flag struct {
-	Kubernetes kubernetes.Kubernetes
-	Provider   provider.Provider
+	DNS            dns.DNS
+	Kubernetes     kubernetes.Kubernetes
+	LeaderElection leaderelection.LeaderElection
+	Probe          probe.Probe
+	Provider       provider.Provider
+	Publisher      publisher.Publisher
+	Reconcile      reconcile.Reconcile
+	Server         server.Server
+	Status         status.Status
 }
 
 func (f *Flag) Validate() error {
+	if f.DNS.Name != "" {
+		if f.DNS.Net != dnsupdater.NetUDP && f.DNS.Net != dnsupdater.NetTCP {
+			return microerror.Maskf(invalidFlagsError, "dns net must be one of %s, %s", dnsupdater.NetUDP, dnsupdater.NetTCP)
+		}
+		if f.DNS.Server == "" {
+			return microerror.Maskf(invalidFlagsError, "dns server must not be empty")
+		}
+		if f.DNS.Timeout < 0 {
+			return microerror.Maskf(invalidFlagsError, "dns timeout must not be negative")
+		}
+		if !contains(dnsupdater.Algorithms(), f.DNS.TSIG.Algorithm) {
+			return microerror.Maskf(invalidFlagsError, "dns tsig algorithm must be one of %s", strings.Join(dnsupdater.Algorithms(), ", "))
+		}
+		if f.DNS.TSIG.Secret != "" && f.DNS.TSIG.SecretFile != "" {
+			return microerror.Maskf(invalidFlagsError, "dns tsig secret and secret file must not be used together")
+		}
+		if (f.DNS.TSIG.Key == "") != (f.DNS.TSIG.Secret == "" && f.DNS.TSIG.SecretFile == "") {
+			return microerror.Maskf(invalidFlagsError, "dns tsig key and secret must be used together")
+		}
+	}
+
+	if f.Kubernetes.Burst < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes burst must not be negative")
+	}
+	if f.Kubernetes.QPS < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes qps must not be negative")
+	}
+	if f.Kubernetes.Timeout < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes timeout must not be negative")
+	}
+	if f.Kubernetes.TLS.ReloadInterval < 0 {
+		return microerror.Maskf(invalidFlagsError, "kubernetes tls reload interval must not be negative")
+	}
+	if f.Kubernetes.Token != "" && f.Kubernetes.TokenFile != "" {
+		return microerror.Maskf(invalidFlagsError, "kubernetes token and token file must not be used together")
+	}
+	if !restconfig.IsMode(f.Kubernetes.Mode) {
+		return microerror.Maskf(invalidFlagsError, "kubernetes mode must be one of %s", strings.Join(restconfig.Modes(), ", "))
+	}
+
 	if f.Kubernetes.Cluster.Namespace == "" {
 		return microerror.Maskf(invalidFlagsError, "guest cluster namespace must not be empty")
 	}
@@ -20,12 +90,159 @@ func (f *Flag) Validate() error {
 		return microerror.Maskf(invalidFlagsError, "guest cluster service must not be empty")
 	}
 
-	if f.Provider.Kind == "env" && f.Provider.Env.Prefix == "" {
-		return microerror.Maskf(invalidFlagsError, "env prefix must not be empty")
+	if f.Kubernetes.Target.Resource != "" {
+		_, err := resource.Parse(f.Kubernetes.Target.Resource)
+		if err != nil {
+			return microerror.Maskf(invalidFlagsError, "kubernetes target resource %s", err)
+		}
+		_, err = updater.ParseObjectField(f.Kubernetes.Target.Field)
+		if err != nil {
+			return microerror.Maskf(invalidFlagsError, "kubernetes target %s", err)
+		}
+		if f.Kubernetes.Target.Name == "" {
+			return microerror.Maskf(invalidFlagsError, "kubernetes target name must not be empty")
+		}
+	}
+
+	if f.LeaderElection.Enabled {
+		if f.LeaderElection.Identity == "" {
+			return microerror.Maskf(invalidFlagsError, "leader election identity must not be empty")
+		}
+		if f.LeaderElection.Name == "" {
+			return microerror.Maskf(invalidFlagsError, "leader election name must not be empty")
+		}
+		if f.LeaderElection.RenewDeadline >= f.LeaderElection.LeaseDuration {
+			return microerror.Maskf(invalidFlagsError, "leader election renew deadline must be less than lease duration")
+		}
+	}
+
+	if f.Probe.Kind != "" {
+		if f.Probe.Kind != "https" && f.Probe.Kind != "tcp" {
+			return microerror.Maskf(invalidFlagsError, "probe kind must be one of 'https', 'tcp' or empty")
+		}
+		if f.Probe.Port <= 0 || f.Probe.Port > 65535 {
+			return microerror.Maskf(invalidFlagsError, "probe port must be between 1 and 65535")
+		}
+	}
+
+	if !contains(bridge.AddressTypes(), f.Provider.Bridge.AddressType) {
+		return microerror.Maskf(invalidFlagsError, "bridge address type must be one of %s", strings.Join(bridge.AddressTypes(), ", "))
+	}
+	for _, cidr := range f.Provider.Bridge.CIDRs {
+		_, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return microerror.Maskf(invalidFlagsError, "bridge CIDR %q must be valid: %s", cidr, err)
+		}
+	}
+	if !contains(bridge.Scopes(), f.Provider.Bridge.Scope) {
+		return microerror.Maskf(invalidFlagsError, "bridge scope must be one of %s", strings.Join(bridge.Scopes(), ", "))
+	}
+	if f.Provider.Bridge.WaitTimeout < 0 {
+		return microerror.Maskf(invalidFlagsError, "bridge wait timeout must not be negative")
+	}
+	if f.Provider.Bridge.NetNS != "" && f.Provider.Bridge.PID != 0 {
+		return microerror.Maskf(invalidFlagsError, "bridge network namespace and PID must not be used together")
+	}
+	if f.Provider.Bridge.PID < 0 {
+		return microerror.Maskf(invalidFlagsError, "bridge PID must not be negative")
+	}
+	if f.Provider.Kind != bridge.Kind && f.Provider.Kind != kubernetesprovider.Kind {
+		return microerror.Maskf(invalidFlagsError, "provider kind must be one of %s, %s", bridge.Kind, kubernetesprovider.Kind)
 	}
-	if f.Provider.Kind == "" {
-		return microerror.Maskf(invalidFlagsError, "provider kind must not be empty")
+	if f.Provider.Kind == kubernetesprovider.Kind {
+		if !contains(kubernetesprovider.Families(), f.Provider.Kubernetes.Family) {
+			return microerror.Maskf(invalidFlagsError, "kubernetes provider family must be one of %s", strings.Join(kubernetesprovider.Families(), ", "))
+		}
+		if !contains(kubernetesprovider.Sources(), f.Provider.Kubernetes.Source) {
+			return microerror.Maskf(invalidFlagsError, "kubernetes provider source must be one of %s", strings.Join(kubernetesprovider.Sources(), ", "))
+		}
+		if f.Provider.Kubernetes.Annotation != "" && f.Provider.Kubernetes.JSONPath != "" {
+			return microerror.Maskf(invalidFlagsError, "kubernetes provider annotation and JSONPath must not be used together")
+		}
+		if f.Provider.Kubernetes.Name == "" {
+			return microerror.Maskf(invalidFlagsError, "kubernetes provider name must not be empty")
+		}
+		if f.Provider.Kubernetes.Resource != "" {
+			_, err := resource.Parse(f.Provider.Kubernetes.Resource)
+			if err != nil {
+				return microerror.Maskf(invalidFlagsError, "kubernetes provider resource %s", err)
+			}
+		}
+	}
+
+	if len(f.Publisher.Kinds) == 0 {
+		return microerror.Maskf(invalidFlagsError, "publisher kinds must not be empty")
+	}
+	for i, kind := range f.Publisher.Kinds {
+		if !contains(PublisherKinds(), kind) {
+			return microerror.Maskf(invalidFlagsError, "publisher kind %q must be one of %s", kind, strings.Join(PublisherKinds(), ", "))
+		}
+		if contains(f.Publisher.Kinds[:i], kind) {
+			return microerror.Maskf(invalidFlagsError, "publisher kind %q must not be given twice", kind)
+		}
+	}
+	for _, kind := range f.Publisher.Optional {
+		if !contains(f.Publisher.Kinds, kind) {
+			return microerror.Maskf(invalidFlagsError, "optional publisher %q must be one of the publisher kinds", kind)
+		}
+	}
+	if contains(f.Publisher.Kinds, dnspublisher.Kind) && f.DNS.Name == "" {
+		return microerror.Maskf(invalidFlagsError, "dns name must not be empty for the %s publisher", dnspublisher.Kind)
+	}
+	if contains(f.Publisher.Kinds, filepublisher.Kind) && f.Publisher.File.Path == "" {
+		return microerror.Maskf(invalidFlagsError, "publisher file path must not be empty for the %s publisher", filepublisher.Kind)
+	}
+	if contains(f.Publisher.Kinds, objectpublisher.Kind) && f.Kubernetes.Target.Resource == "" {
+		return microerror.Maskf(invalidFlagsError, "kubernetes target resource must not be empty for the %s publisher", objectpublisher.Kind)
+	}
+	if contains(f.Publisher.Kinds, webhookpublisher.Kind) {
+		if f.Publisher.Webhook.URL == "" {
+			return microerror.Maskf(invalidFlagsError, "publisher webhook URL must not be empty for the %s publisher", webhookpublisher.Kind)
+		}
+		if f.Publisher.Webhook.Timeout < 0 {
+			return microerror.Maskf(invalidFlagsError, "publisher webhook timeout must not be negative")
+		}
+		if f.Publisher.Webhook.Token != "" && f.Publisher.Webhook.TokenFile != "" {
+			return microerror.Maskf(invalidFlagsError, "publisher webhook token and token file must not be used together")
+		}
+	}
+
+	if f.Reconcile.Interval < 0 {
+		return microerror.Maskf(invalidFlagsError, "reconcile interval must not be negative")
+	}
+	if f.Reconcile.MaxAge < 0 {
+		return microerror.Maskf(invalidFlagsError, "reconcile max age must not be negative")
+	}
+	if f.Reconcile.Interval != 0 && f.Reconcile.MaxAge != 0 && f.Reconcile.MaxAge <= f.Reconcile.Interval {
+		return microerror.Maskf(invalidFlagsError, "reconcile max age must be greater than reconcile interval")
+	}
+
+	if f.Status.Kind != "" && f.Status.Kind != "configmap" && f.Status.Kind != "endpointbinding" {
+		return microerror.Maskf(invalidFlagsError, "status kind must be one of 'configmap', 'endpointbinding' or empty")
 	}
 
 	return nil
 }
+
+// PublisherKinds returns the kinds of all publishers.
+func PublisherKinds() []string {
+	return []string{
+		annotationpublisher.Kind,
+		dnspublisher.Kind,
+		endpointspublisher.Kind,
+		endpointslicepublisher.Kind,
+		filepublisher.Kind,
+		objectpublisher.Kind,
+		webhookpublisher.Kind,
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
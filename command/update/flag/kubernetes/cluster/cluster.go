@@ -1,6 +1,7 @@
 package cluster
 
 type Cluster struct {
-	Namespace string
-	Service   string
+	Namespace     string
+	PortOverrides []string
+	Service       string
 }
@@ -1,15 +1,29 @@
 package kubernetes
 
 import (
+	"time"
+
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/kubernetes/cluster"
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/kubernetes/pod"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/kubernetes/target"
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/kubernetes/tls"
 )
 
 type Kubernetes struct {
-	Address   string
-	Cluster   cluster.Cluster
-	InCluster bool
-	Pod       pod.Pod
-	TLS       tls.TLS
+	Address       string
+	AllowInsecure bool
+	Burst         int
+	Cluster       cluster.Cluster
+	Context       string
+	EndpointSlice bool
+	InCluster     bool
+	Kubeconfig    string
+	Mode          string
+	Pod           pod.Pod
+	QPS           float32
+	Target        target.Target
+	Timeout       time.Duration
+	TLS           tls.TLS
+	Token         string
+	TokenFile     string
 }
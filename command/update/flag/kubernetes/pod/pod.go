@@ -1,5 +1,7 @@
 package pod
 
 type Pod struct {
-	Name string
+	Cleanup       bool
+	Name          string
+	ReadinessGate bool
 }
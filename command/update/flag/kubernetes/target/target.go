@@ -0,0 +1,8 @@
+package target
+
+type Target struct {
+	Field     string
+	Name      string
+	Namespace string
+	Resource  string
+}
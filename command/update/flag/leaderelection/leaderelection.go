@@ -0,0 +1,12 @@
+package leaderelection
+
+import "time"
+
+type LeaderElection struct {
+	Enabled       bool
+	Identity      string
+	LeaseDuration time.Duration
+	Name          string
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
@@ -0,0 +1,13 @@
+package probe
+
+import "time"
+
+type Probe struct {
+	FailureThreshold int
+	Kind             string
+	Path             string
+	Period           time.Duration
+	Port             int
+	SuccessThreshold int
+	Timeout          time.Duration
+}
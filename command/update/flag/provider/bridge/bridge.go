@@ -1,5 +1,14 @@
 package bridge
 
+import "time"
+
 type Bridge struct {
-	Name string
+	AddressType string
+	CIDRs       []string
+	Label       string
+	Name        string
+	NetNS       string
+	PID         int
+	Scope       string
+	WaitTimeout time.Duration
 }
@@ -0,0 +1,11 @@
+package kubernetes
+
+type Kubernetes struct {
+	Annotation string
+	Family     string
+	JSONPath   string
+	Name       string
+	Namespace  string
+	Resource   string
+	Source     string
+}
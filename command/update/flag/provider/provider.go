@@ -4,11 +4,13 @@ import (
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/provider/bridge"
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/provider/env"
 	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/provider/etcd"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/provider/kubernetes"
 )
 
 type Provider struct {
-	Bridge bridge.Bridge
-	Env    env.Env
-	Etcd   etcd.Etcd
-	Kind   string
+	Bridge     bridge.Bridge
+	Env        env.Env
+	Etcd       etcd.Etcd
+	Kind       string
+	Kubernetes kubernetes.Kubernetes
 }
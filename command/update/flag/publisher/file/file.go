@@ -0,0 +1,5 @@
+package file
+
+type File struct {
+	Path string
+}
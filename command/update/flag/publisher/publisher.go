@@ -0,0 +1,13 @@
+package publisher
+
+import (
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/publisher/file"
+	"github.com/giantswarm/k8s-endpoint-updater/command/update/flag/publisher/webhook"
+)
+
+type Publisher struct {
+	File     file.File
+	Kinds    []string
+	Optional []string
+	Webhook  webhook.Webhook
+}
@@ -0,0 +1,10 @@
+package webhook
+
+import "time"
+
+type Webhook struct {
+	Timeout   time.Duration
+	Token     string
+	TokenFile string
+	URL       string
+}
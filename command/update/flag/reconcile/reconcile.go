@@ -0,0 +1,8 @@
+package reconcile
+
+import "time"
+
+type Reconcile struct {
+	Interval time.Duration
+	MaxAge   time.Duration
+}
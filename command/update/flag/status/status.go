@@ -0,0 +1,5 @@
+package status
+
+type Status struct {
+	Kind string
+}
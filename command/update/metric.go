@@ -0,0 +1,66 @@
+package update
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	prometheusNamespace = "k8s_endpoint_updater"
+	prometheusSubsystem = "update"
+)
+
+var (
+	lookupTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "provider_lookup_total",
+			Help:      "Number of provider lookups partitioned by provider kind and result.",
+		},
+		[]string{"kind", "result"},
+	)
+	lookupDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "provider_lookup_duration_seconds",
+			Help:      "Latency of provider lookups in seconds partitioned by provider kind.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"kind"},
+	)
+	publishTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "publish_total",
+			Help:      "Number of publications partitioned by publisher kind and result.",
+		},
+		[]string{"kind", "result"},
+	)
+	publishedIPInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "published_ip_info",
+			Help:      "IP currently published to the KVM pod. The value is always 1.",
+		},
+		[]string{"namespace", "pod", "ip"},
+	)
+	lastReconcileTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "last_successful_reconcile_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful reconciliation.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(lookupTotal)
+	prometheus.MustRegister(lookupDuration)
+	prometheus.MustRegister(publishTotal)
+	prometheus.MustRegister(publishedIPInfo)
+	prometheus.MustRegister(lastReconcileTimestamp)
+}
@@ -0,0 +1,239 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	cenkaltibackoff "github.com/cenkalti/backoff"
+	"github.com/giantswarm/backoff"
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/health"
+	"github.com/giantswarm/k8s-endpoint-updater/service/prober"
+	"github.com/giantswarm/k8s-endpoint-updater/service/provider"
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
+)
+
+// reconciler looks up the VM IP using the configured provider and publishes
+// it to the KVM pod using the updater.
+type reconciler struct {
+	health   *health.Health
+	logger   micrologger.Logger
+	prober   *prober.Prober
+	provider provider.Provider
+	kind     string
+	updater  *updater.Updater
+
+	// k8sWatchClient watches the guest cluster service. Its requests are not
+	// bounded by a timeout.
+	k8sWatchClient kubernetes.Interface
+
+	// sinks are the publishers the VM IP is fanned out to.
+	sinks []sink
+
+	generation      int64
+	lastLookupError string
+	publishedIP     net.IP
+}
+
+// sink is a publisher the VM IP is fanned out to.
+type sink struct {
+	kind      string
+	optional  bool
+	publisher publisher.Publisher
+}
+
+// Reconcile executes a single reconciliation. Lookup and publication are
+// retried individually using the given backoff. Retries are aborted when ctx
+// is done. Kubernetes API calls in flight are aborted on termination, see
+// restconfig.AbortOnDone.
+func (r *reconciler) Reconcile(ctx context.Context, b backoff.BackOff) error {
+	var err error
+
+	b = cenkaltibackoff.WithContext(b, ctx)
+
+	r.generation++
+	r.lastLookupError = ""
+	defer r.updateStatus(ctx)
+
+	// Here we lookup the VM IP we are interested in.
+	var podIP net.IP
+	{
+		action := func() error {
+			podIP, err = r.lookup(ctx)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return nil
+		}
+
+		err := r.retry(b, "failed looking up VM IP", action)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		_ = r.logger.Log("level", "debug", "message", "looked up VM IP", "ip", podIP.String())
+	}
+
+	// Without prober the VM is considered ready right away. Otherwise the VM
+	// has to pass the probes first.
+	ready := true
+	if r.prober != nil {
+		r.prober.SetTarget(podIP)
+		ready = r.prober.Ready()
+	}
+
+	// Fan out the VM IP to all configured publishers.
+	err = r.publish(ctx, b, podIP, ready)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if f.Kubernetes.Pod.ReadinessGate {
+		b.Reset()
+
+		status := corev1.ConditionTrue
+		reason := updater.ReasonPublished
+		message := fmt.Sprintf("Published VM IP %s.", podIP)
+		if !ready {
+			status = corev1.ConditionFalse
+			reason = updater.ReasonNotReady
+			message = fmt.Sprintf("Published VM IP %s is not ready.", podIP)
+		}
+
+		action := func() error {
+			err := r.updater.UpdatePodCondition(ctx, f.Kubernetes.Cluster.Namespace, f.Kubernetes.Pod.Name, status, reason, message)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return nil
+		}
+
+		err := r.retry(b, "failed updating pod condition", action)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	if !podIP.Equal(r.publishedIP) {
+		publishedIPInfo.Reset()
+		publishedIPInfo.WithLabelValues(f.Kubernetes.Cluster.Namespace, f.Kubernetes.Pod.Name, podIP.String()).Set(1)
+		r.publishedIP = podIP
+	}
+	now := time.Now()
+	r.health.Reconciled(now)
+	lastReconcileTimestamp.Set(float64(now.Unix()))
+
+	return nil
+}
+
+// publish publishes the VM IP to all sinks. Every sink is retried on its own
+// using the given backoff, so that a failing sink does not keep the others from
+// being published to. Failures of optional sinks are only logged. The returned
+// error lists all failed sinks which are not optional.
+func (r *reconciler) publish(ctx context.Context, b backoff.BackOff, podIP net.IP, ready bool) error {
+	var failed []string
+	for _, s := range r.sinks {
+		s := s
+		b.Reset()
+
+		action := func() error {
+			err := s.publisher.Publish(ctx, podIP, ready)
+			if err != nil {
+				return microerror.Mask(err)
+			}
+
+			return nil
+		}
+
+		err := r.retry(b, "failed publishing VM IP to "+s.kind, action)
+		if ctx.Err() != nil {
+			return microerror.Mask(ctx.Err())
+		} else if s.optional && err != nil {
+			publishTotal.WithLabelValues(s.kind, "failure").Inc()
+			_ = r.logger.Log("level", "warning", "message", "failed publishing VM IP to optional publisher", "publisher", s.kind, "ip", podIP.String(), "error", err)
+			continue
+		} else if err != nil {
+			publishTotal.WithLabelValues(s.kind, "failure").Inc()
+			_ = r.logger.Log("level", "error", "message", "failed publishing VM IP", "publisher", s.kind, "ip", podIP.String(), "error", err)
+			failed = append(failed, s.kind)
+			continue
+		}
+		publishTotal.WithLabelValues(s.kind, "success").Inc()
+
+		_ = r.logger.Log("level", "debug", "message", "published VM IP", "publisher", s.kind, "ip", podIP.String(), "ready", ready)
+	}
+
+	if len(failed) != 0 {
+		return microerror.Maskf(executionFailedError, "publishing VM IP to %s failed", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// retry executes o using the given backoff and logs every failed attempt with
+// the given message.
+func (r *reconciler) retry(b backoff.BackOff, message string, o backoff.Operation) error {
+	var attempt int
+	notify := func(err error, d time.Duration) {
+		attempt++
+		_ = r.logger.Log("level", "warning", "message", message, "attempt", attempt, "retryIn", d.String(), "error", err)
+	}
+
+	err := backoff.RetryNotify(o, b, notify)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (r *reconciler) lookup(ctx context.Context) (net.IP, error) {
+	start := time.Now()
+	ip, err := r.provider.Lookup(ctx)
+	lookupDuration.WithLabelValues(r.kind).Observe(time.Since(start).Seconds())
+	if err != nil {
+		lookupTotal.WithLabelValues(r.kind, "failure").Inc()
+		r.lastLookupError = err.Error()
+		return nil, microerror.Mask(err)
+	}
+	lookupTotal.WithLabelValues(r.kind, "success").Inc()
+
+	return ip, nil
+}
+
+// updateStatus writes the status of the current reconciliation. Failures are
+// only logged because the status is informational. Nothing is written when ctx
+// is done.
+func (r *reconciler) updateStatus(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	publishers := map[string]publisher.Status{}
+	for _, s := range r.sinks {
+		publishers[s.kind] = s.publisher.Status()
+	}
+
+	status := updater.Status{
+		Generation:      r.generation,
+		IP:              r.publishedIP,
+		LastLookupError: r.lastLookupError,
+		Provider:        r.kind,
+		Publishers:      publishers,
+	}
+
+	err := r.updater.UpdateStatus(ctx, f.Kubernetes.Cluster.Namespace, f.Kubernetes.Cluster.Service, f.Kubernetes.Pod.Name, status)
+	if err != nil {
+		_ = r.logger.Log("level", "error", "message", "failed updating status", "error", err)
+	}
+}
@@ -1,11 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
-	"github.com/giantswarm/micrologger"
-
 	"github.com/giantswarm/k8s-endpoint-updater/command"
+	"github.com/giantswarm/k8s-endpoint-updater/service/logging"
 )
 
 var (
@@ -18,15 +18,15 @@ var (
 func main() {
 	var err error
 
-	// Create a new logger which is used by all packages.
-	var newLogger micrologger.Logger
+	// Create a new logger which is used by all packages. Its format and level
+	// are configured by the root command once the flags are parsed.
+	var newLogger *logging.Logger
 	{
-		loggerConfig := micrologger.Config{
-			IOWriter: os.Stdout,
-		}
-		newLogger, err = micrologger.New(loggerConfig)
+		loggerConfig := logging.DefaultConfig()
+		newLogger, err = logging.New(loggerConfig)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	}
 
@@ -43,12 +43,15 @@ func main() {
 
 		newCommand, err = command.New(commandConfig)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	}
 
+	// Errors like invalid flag values are printed by cobra together with the
+	// usage already.
 	err = newCommand.CobraCommand().Execute()
 	if err != nil {
-		panic(err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,36 @@
+// Package call bounds Kubernetes API calls by a context. The typed and dynamic
+// clients of the client-go version in use do not take a context, so calls in
+// flight are aborted through the transport instead, see restconfig.AbortOnDone.
+// Every single call is bounded by the timeout of the REST config as well, see
+// restconfig.Config.Timeout.
+package call
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Do executes f unless ctx is already done. The error of f is returned
+// unchanged, so that it can be asserted with the helpers of the Kubernetes API
+// errors package. Errors of f are replaced by the masked context error once ctx
+// is done, so that callers can tell termination apart from failed calls.
+func Do(ctx context.Context, f func() error) error {
+	if ctx.Err() != nil {
+		return microerror.Mask(ctx.Err())
+	}
+
+	err := f()
+	if err != nil && ctx.Err() != nil {
+		return microerror.Mask(ctx.Err())
+	}
+
+	return err
+}
+
+// IsCancelled asserts errors caused by a cancelled context or an exceeded
+// deadline.
+func IsCancelled(err error) bool {
+	c := microerror.Cause(err)
+	return c == context.Canceled || c == context.DeadlineExceeded
+}
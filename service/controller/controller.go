@@ -0,0 +1,352 @@
+// Package controller implements the cluster wide controller watching labelled
+// KVM pods and reconciling the Endpoints of their guest cluster services.
+package controller
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
+	"github.com/giantswarm/k8s-endpoint-updater/service/provider"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
+)
+
+const (
+	// LabelService is the label of KVM pods naming the guest cluster service in
+	// the pod's namespace whose Endpoints are managed by the controller.
+	LabelService = "endpoint.kvm.giantswarm.io/service"
+)
+
+// Config represents the configuration used to create a new controller.
+type Config struct {
+	// Dependencies.
+	K8sClient kubernetes.Interface
+	Logger    micrologger.Logger
+	Provider  provider.PodProvider
+	Updater   *updater.Updater
+
+	// Settings.
+
+	// Namespace restricts the watched pods to a single namespace. All
+	// namespaces are watched when empty.
+	Namespace string
+	// ResyncPeriod is the interval in which all guest cluster services are
+	// reconciled again.
+	ResyncPeriod time.Duration
+	// Selector is an additional label selector KVM pods have to match. Pods
+	// always have to carry LabelService.
+	Selector string
+	// Workers is the number of services reconciled concurrently.
+	Workers int
+}
+
+// DefaultConfig provides a default configuration to create a new controller
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		K8sClient: nil,
+		Logger:    nil,
+		Provider:  nil,
+		Updater:   nil,
+
+		// Settings.
+		Namespace:    "",
+		ResyncPeriod: 5 * time.Minute,
+		Selector:     "",
+		Workers:      1,
+	}
+}
+
+// New creates a new controller.
+func New(config Config) (*Controller, error) {
+	// Dependencies.
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.K8sClient must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+	if config.Provider == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Provider must not be empty")
+	}
+	if config.Updater == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Updater must not be empty")
+	}
+
+	// Settings.
+	if config.ResyncPeriod < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.ResyncPeriod must not be negative")
+	}
+	if config.Workers <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Workers must be greater than zero")
+	}
+
+	var selector labels.Selector
+	{
+		var err error
+
+		selector, err = labels.Parse(config.Selector)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.Selector %s", err)
+		}
+
+		r, err := labels.NewRequirement(LabelService, selection.Exists, nil)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		selector = selector.Add(*r)
+	}
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(
+		config.K8sClient,
+		config.ResyncPeriod,
+		informers.WithNamespace(config.Namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = selector.String()
+		}),
+	)
+	podInformer := informerFactory.Core().V1().Pods()
+
+	// Services are watched without label selector because guest cluster
+	// services are not labelled. Only changes of services referenced by KVM
+	// pods are of interest.
+	serviceInformerFactory := informers.NewSharedInformerFactoryWithOptions(
+		config.K8sClient,
+		config.ResyncPeriod,
+		informers.WithNamespace(config.Namespace),
+	)
+	serviceInformer := serviceInformerFactory.Core().V1().Services()
+
+	newController := &Controller{
+		// Dependencies.
+		logger:   config.Logger,
+		provider: config.Provider,
+		updater:  config.Updater,
+
+		// Internals.
+		informerFactory:        informerFactory,
+		podInformer:            podInformer.Informer(),
+		podLister:              podInformer.Lister(),
+		queue:                  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "endpoints"),
+		serviceInformer:        serviceInformer.Informer(),
+		serviceInformerFactory: serviceInformerFactory,
+
+		// Settings.
+		workers: config.Workers,
+	}
+
+	newController.podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: newController.enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// The service label might have changed, in which case the Endpoints of
+			// both services need to be reconciled.
+			newController.enqueue(oldObj)
+			newController.enqueue(newObj)
+		},
+		DeleteFunc: newController.enqueue,
+	})
+
+	// Endpoint ports are derived from the service spec, so changed service
+	// ports have to be reconciled as well.
+	newController.serviceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldService, ok := oldObj.(*corev1.Service)
+			if !ok {
+				return
+			}
+			newService, ok := newObj.(*corev1.Service)
+			if !ok {
+				return
+			}
+
+			if !reflect.DeepEqual(oldService.Spec.Ports, newService.Spec.Ports) {
+				newController.enqueueService(newService)
+			}
+		},
+	})
+
+	return newController, nil
+}
+
+type Controller struct {
+	// Dependencies.
+	logger   micrologger.Logger
+	provider provider.PodProvider
+	updater  *updater.Updater
+
+	// Internals.
+	informerFactory        informers.SharedInformerFactory
+	podInformer            cache.SharedIndexInformer
+	podLister              corev1listers.PodLister
+	queue                  workqueue.RateLimitingInterface
+	serviceInformer        cache.SharedIndexInformer
+	serviceInformerFactory informers.SharedInformerFactory
+
+	// Settings.
+	workers int
+}
+
+// Sync starts watching KVM pods and services and blocks until the caches are
+// synced. Sync does not reconcile anything and can thus be used by replicas
+// which are not leading, so that they keep warm caches and can report
+// readiness.
+func (c *Controller) Sync(ctx context.Context) error {
+	c.informerFactory.Start(ctx.Done())
+	c.serviceInformerFactory.Start(ctx.Done())
+
+	_ = c.logger.Log("level", "debug", "message", "waiting for caches to sync")
+
+	if !cache.WaitForCacheSync(ctx.Done(), c.podInformer.HasSynced, c.serviceInformer.HasSynced) {
+		return microerror.Maskf(executionFailedError, "caches did not sync")
+	}
+
+	_ = c.logger.Log("level", "debug", "message", "caches synced")
+
+	return nil
+}
+
+// Boot starts watching KVM pods and blocks until the given context is done.
+// The given synced function is called once the caches are synced. Caches
+// already synced by Sync are reused.
+func (c *Controller) Boot(ctx context.Context, synced func()) error {
+	defer c.queue.ShutDown()
+
+	err := c.Sync(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if synced != nil {
+		synced()
+	}
+
+	for i := 0; i < c.workers; i++ {
+		go wait.Until(func() { c.work(ctx) }, time.Second, ctx.Done())
+	}
+
+	<-ctx.Done()
+
+	return nil
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	service := pod.Labels[LabelService]
+	if service == "" {
+		return
+	}
+
+	c.queue.Add(pod.Namespace + "/" + service)
+}
+
+// enqueueService enqueues the given service if any KVM pod references it.
+func (c *Controller) enqueueService(svc *corev1.Service) {
+	selector := labels.SelectorFromSet(labels.Set{LabelService: svc.Name})
+
+	pods, err := c.podLister.Pods(svc.Namespace).List(selector)
+	if err != nil || len(pods) == 0 {
+		return
+	}
+
+	c.queue.Add(svc.Namespace + "/" + svc.Name)
+}
+
+func (c *Controller) work(ctx context.Context) {
+	for c.processNext(ctx) {
+	}
+}
+
+func (c *Controller) processNext(ctx context.Context) bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(item)
+
+	key := item.(string)
+
+	err := c.reconcile(ctx, key)
+	if call.IsCancelled(err) && ctx.Err() != nil {
+		// The controller is shutting down.
+		return true
+	} else if err != nil {
+		_ = c.logger.Log("level", "error", "message", "failed reconciling endpoints", "service", key, "attempt", c.queue.NumRequeues(item)+1, "error", err)
+		c.queue.AddRateLimited(item)
+		return true
+	}
+
+	c.queue.Forget(item)
+
+	return true
+}
+
+// reconcile publishes the VM IPs of all KVM pods labelled with the service
+// identified by the given key as Endpoints of that service. VMs published as
+// not ready end up in the not ready addresses.
+func (c *Controller) reconcile(ctx context.Context, key string) error {
+	namespace, service, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{LabelService: service})
+
+	pods, err := c.podLister.Pods(namespace).List(selector)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var targets []updater.Target
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		// Pods without VM IP are skipped. Once the IP is known the pod gets
+		// updated and the service is reconciled again.
+		ip, err := c.provider.LookupPod(ctx, pod)
+		if err != nil {
+			_ = c.logger.Log("level", "debug", "message", "skipping KVM pod without VM IP", "namespace", pod.Namespace, "pod", pod.Name, "error", err)
+			continue
+		}
+
+		// VMs are ready unless explicitly published as not ready, e.g. by the
+		// update command probing them.
+		ready := pod.Annotations[updater.AnnotationReady] != "false"
+
+		targets = append(targets, updater.Target{IP: ip, Pod: pod, Ready: ready})
+	}
+
+	err = c.updater.UpdateEndpoints(ctx, namespace, service, targets)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_ = c.logger.Log("level", "debug", "message", "reconciled endpoints", "namespace", namespace, "service", service, "addresses", len(targets))
+
+	return nil
+}
@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/provider/annotation"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
+)
+
+const (
+	annotationIP = "endpoint.kvm.giantswarm.io/ip"
+)
+
+func newTestController(t *testing.T, objects ...runtime.Object) (*Controller, *fake.Clientset) {
+	t.Helper()
+
+	k8sClient := fake.NewSimpleClientset(objects...)
+	logger := microloggertest.New()
+
+	var newProvider *annotation.Provider
+	{
+		c := annotation.DefaultConfig()
+		c.Logger = logger
+		c.Annotation = annotationIP
+
+		var err error
+		newProvider, err = annotation.New(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var newUpdater *updater.Updater
+	{
+		c := updater.DefaultConfig()
+		c.DynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+		c.EventRecorder = record.NewFakeRecorder(10)
+		c.K8sClient = k8sClient
+		c.Logger = logger
+
+		var err error
+		newUpdater, err = updater.New(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := DefaultConfig()
+	c.K8sClient = k8sClient
+	c.Logger = logger
+	c.Provider = newProvider
+	c.Updater = newUpdater
+
+	newController, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newController, k8sClient
+}
+
+func newTestPod(name string, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: annotations,
+			Labels:      map[string]string{LabelService: "master"},
+			UID:         types.UID("uid-" + name),
+		},
+	}
+}
+
+func newTestService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "master",
+			Namespace: "default",
+			UID:       "master-uid",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "https", Port: 443},
+			},
+		},
+	}
+}
+
+func Test_Controller_Sync(t *testing.T) {
+	testCases := []struct {
+		name         string
+		cancelled    bool
+		errorMatcher func(error) bool
+	}{
+		{
+			name:         "case 0: caches sync",
+			cancelled:    false,
+			errorMatcher: nil,
+		},
+		{
+			name:         "case 1: cancelled before caches synced",
+			cancelled:    true,
+			errorMatcher: IsExecutionFailed,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			c, _ := newTestController(t, newTestPod("kvm", nil))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if tc.cancelled {
+				cancel()
+			}
+
+			err := c.Sync(ctx)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && !c.podInformer.HasSynced() {
+				t.Fatalf("pod informer must be synced")
+			}
+		})
+	}
+}
+
+func Test_Controller_reconcile(t *testing.T) {
+	testCases := []struct {
+		name                      string
+		pods                      []runtime.Object
+		expectedAddresses         []string
+		expectedNotReadyAddresses []string
+	}{
+		{
+			name: "case 0: VM without ready annotation is ready",
+			pods: []runtime.Object{
+				newTestPod("kvm-0", map[string]string{annotationIP: "10.0.0.2"}),
+			},
+			expectedAddresses:         []string{"10.0.0.2"},
+			expectedNotReadyAddresses: nil,
+		},
+		{
+			name: "case 1: VMs are split by their ready annotation",
+			pods: []runtime.Object{
+				newTestPod("kvm-0", map[string]string{annotationIP: "10.0.0.2", updater.AnnotationReady: "true"}),
+				newTestPod("kvm-1", map[string]string{annotationIP: "10.0.0.3", updater.AnnotationReady: "false"}),
+			},
+			expectedAddresses:         []string{"10.0.0.2"},
+			expectedNotReadyAddresses: []string{"10.0.0.3"},
+		},
+		{
+			name: "case 2: VMs not being ready only",
+			pods: []runtime.Object{
+				newTestPod("kvm-0", map[string]string{annotationIP: "10.0.0.2", updater.AnnotationReady: "false"}),
+			},
+			expectedAddresses:         nil,
+			expectedNotReadyAddresses: []string{"10.0.0.2"},
+		},
+		{
+			name: "case 3: pods without VM IP are skipped",
+			pods: []runtime.Object{
+				newTestPod("kvm-0", map[string]string{annotationIP: "10.0.0.2"}),
+				newTestPod("kvm-1", nil),
+			},
+			expectedAddresses:         []string{"10.0.0.2"},
+			expectedNotReadyAddresses: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			objects := append([]runtime.Object{newTestService()}, tc.pods...)
+			c, k8sClient := newTestController(t, objects...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			err := c.Sync(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.reconcile(ctx, "default/master")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			endpoints, err := k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(endpoints.Subsets) != 1 {
+				t.Fatalf("subsets == %v, want 1", endpoints.Subsets)
+			}
+
+			addresses := ips(endpoints.Subsets[0].Addresses)
+			if fmt.Sprint(addresses) != fmt.Sprint(tc.expectedAddresses) {
+				t.Fatalf("addresses == %v, want %v", addresses, tc.expectedAddresses)
+			}
+			notReadyAddresses := ips(endpoints.Subsets[0].NotReadyAddresses)
+			if fmt.Sprint(notReadyAddresses) != fmt.Sprint(tc.expectedNotReadyAddresses) {
+				t.Fatalf("not ready addresses == %v, want %v", notReadyAddresses, tc.expectedNotReadyAddresses)
+			}
+		})
+	}
+}
+
+func Test_Controller_processNext(t *testing.T) {
+	testCases := []struct {
+		name             string
+		objects          []runtime.Object
+		expectedRequeues int
+	}{
+		{
+			name:             "case 0: reconciled service is forgotten",
+			objects:          []runtime.Object{newTestService(), newTestPod("kvm-0", map[string]string{annotationIP: "10.0.0.2"})},
+			expectedRequeues: 0,
+		},
+		{
+			name:             "case 1: deleted service without KVM pods is forgotten",
+			objects:          nil,
+			expectedRequeues: 0,
+		},
+		{
+			name:             "case 2: missing service with KVM pods is retried",
+			objects:          []runtime.Object{newTestPod("kvm-0", map[string]string{annotationIP: "10.0.0.2"})},
+			expectedRequeues: 2,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			c, k8sClient := newTestController(t, tc.objects...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			err := c.Sync(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The key is enqueued as after a previous failed attempt.
+			c.queue.AddRateLimited("default/master")
+
+			if !c.processNext(ctx) {
+				t.Fatalf("processNext == false, want true")
+			}
+
+			requeues := c.queue.NumRequeues("default/master")
+			if requeues != tc.expectedRequeues {
+				t.Fatalf("requeues == %d, want %d", requeues, tc.expectedRequeues)
+			}
+
+			_, err = k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+			if tc.objects == nil && !errors.IsNotFound(err) {
+				t.Fatalf("error == %#v, want not found", err)
+			}
+		})
+	}
+}
+
+func ips(addresses []corev1.EndpointAddress) []string {
+	var ips []string
+	for _, a := range addresses {
+		ips = append(ips, a.IP)
+	}
+
+	return ips
+}
@@ -0,0 +1,361 @@
+// Package dnsupdater publishes the VM IP as A or AAAA record using RFC 2136
+// dynamic DNS updates, optionally authenticated with TSIG (RFC 8945), so that
+// consumers can resolve guest clusters by hostname.
+package dnsupdater
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultTimeout is the default timeout of a single dynamic update.
+	DefaultTimeout = 10 * time.Second
+	// DefaultTTL is the default TTL of the published record in seconds.
+	DefaultTTL = 60
+)
+
+// Transport protocols the updates are sent with.
+const (
+	NetTCP = "tcp"
+	NetUDP = "udp"
+)
+
+const (
+	// tsigFudge is the permitted clock skew in seconds between us and the
+	// DNS server.
+	tsigFudge = 300
+)
+
+var algorithms = map[string]string{
+	"hmac-md5":    dns.HmacMD5,
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// Algorithms returns all supported TSIG algorithms.
+func Algorithms() []string {
+	return []string{"hmac-md5", "hmac-sha1", "hmac-sha256", "hmac-sha512"}
+}
+
+// Config represents the configuration used to create a new DNS updater.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+
+	// Name is the name of the record the VM IP is published as, e.g.
+	// "api.guest.example.com". The record is owned by the updater, i.e. all A
+	// and AAAA records of the name are replaced.
+	Name string
+	// Net is the transport protocol, either NetUDP or NetTCP.
+	Net string
+	// Server is the address of the authoritative DNS server as host:port.
+	Server string
+	// Timeout bounds every single dynamic update. Zero means updates are only
+	// bounded by the given contexts.
+	Timeout time.Duration
+	// TSIGAlgorithm is the TSIG algorithm. It is one of Algorithms.
+	TSIGAlgorithm string
+	// TSIGKey is the name of the TSIG key. Updates are not signed when empty.
+	TSIGKey string
+	// TSIGSecret is the base64 encoded secret of the TSIG key.
+	TSIGSecret string
+	// TTL is the TTL of the published record in seconds.
+	TTL uint32
+	// Zone is the zone the record is part of. Defaults to the parent of Name.
+	Zone string
+}
+
+// DefaultConfig provides a default configuration to create a new DNS updater
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger: nil,
+
+		// Settings.
+		Name:          "",
+		Net:           NetUDP,
+		Server:        "",
+		Timeout:       DefaultTimeout,
+		TSIGAlgorithm: "hmac-sha256",
+		TSIGKey:       "",
+		TSIGSecret:    "",
+		TTL:           DefaultTTL,
+		Zone:          "",
+	}
+}
+
+// New creates a new DNS updater.
+func New(config Config) (*Updater, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must not be empty")
+	}
+	if _, ok := dns.IsDomainName(config.Name); !ok {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must be a domain name")
+	}
+	if config.Net != NetUDP && config.Net != NetTCP {
+		return nil, microerror.Maskf(invalidConfigError, "config.Net must be one of %s, %s", NetUDP, NetTCP)
+	}
+	if config.Server == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Server must not be empty")
+	}
+	if _, _, err := net.SplitHostPort(config.Server); err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Server must be given as host:port")
+	}
+	if config.Timeout < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Timeout must not be negative")
+	}
+	if (config.TSIGKey == "") != (config.TSIGSecret == "") {
+		return nil, microerror.Maskf(invalidConfigError, "config.TSIGKey and config.TSIGSecret must be used together")
+	}
+	algorithm, ok := algorithms[config.TSIGAlgorithm]
+	if config.TSIGKey != "" && !ok {
+		return nil, microerror.Maskf(invalidConfigError, "config.TSIGAlgorithm must be one of %s", strings.Join(Algorithms(), ", "))
+	}
+	if config.TSIGSecret != "" {
+		_, err := base64.StdEncoding.DecodeString(config.TSIGSecret)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.TSIGSecret must be base64 encoded")
+		}
+	}
+
+	name := strings.ToLower(dns.Fqdn(config.Name))
+
+	zone := config.Zone
+	if zone == "" {
+		labels := dns.SplitDomainName(name)
+		if len(labels) < 2 {
+			return nil, microerror.Maskf(invalidConfigError, "config.Zone must not be empty for top level names")
+		}
+		zone = strings.Join(labels[1:], ".")
+	}
+	zone = strings.ToLower(dns.Fqdn(zone))
+	if !dns.IsSubDomain(zone, name) {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must be part of config.Zone")
+	}
+
+	client := &dns.Client{
+		Net: config.Net,
+	}
+
+	var tsigKey string
+	if config.TSIGKey != "" {
+		tsigKey = strings.ToLower(dns.Fqdn(config.TSIGKey))
+		client.TsigSecret = map[string]string{tsigKey: config.TSIGSecret}
+	}
+
+	newUpdater := &Updater{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Internals.
+		client: client,
+		mutex:  sync.Mutex{},
+
+		// Settings.
+		name:          name,
+		server:        config.Server,
+		timeout:       config.Timeout,
+		tsigAlgorithm: algorithm,
+		tsigKey:       tsigKey,
+		ttl:           config.TTL,
+		zone:          zone,
+	}
+
+	return newUpdater, nil
+}
+
+type Updater struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Internals.
+	client *dns.Client
+	mutex  sync.Mutex
+	// published is the IP published by the last successful update. It is
+	// nil initially and after the record got removed.
+	published net.IP
+
+	// Settings.
+	name          string
+	server        string
+	timeout       time.Duration
+	tsigAlgorithm string
+	tsigKey       string
+	ttl           uint32
+	zone          string
+}
+
+// Update replaces the A and AAAA records of the configured name with a single
+// record of the given IP. It is a no-op when the IP did not change since the
+// last successful update and the server still serves it, so that records
+// changed or removed by someone else are restored. Rejected updates result in
+// an error asserted by IsUpdateFailed.
+func (u *Updater) Update(ctx context.Context, ip net.IP) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if ip.Equal(u.published) {
+		inSync, err := u.inSync(ctx, ip)
+		if err != nil {
+			// The update is sent anyway, as it is idempotent.
+			_ = u.logger.Log("level", "warning", "message", "failed querying DNS records", "name", u.name, "error", err)
+		} else if inSync {
+			return nil
+		} else {
+			_ = u.logger.Log("level", "warning", "message", "published DNS records changed", "name", u.name, "ip", ip.String())
+		}
+	}
+
+	var rr dns.RR
+	if ip4 := ip.To4(); ip4 != nil {
+		rr = &dns.A{Hdr: u.header(dns.TypeA, u.ttl), A: ip4}
+	} else {
+		rr = &dns.AAAA{Hdr: u.header(dns.TypeAAAA, u.ttl), AAAA: ip}
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(u.zone)
+	m.RemoveRRset(u.rrsets())
+	m.Insert([]dns.RR{rr})
+
+	err := u.exchange(ctx, m)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_ = u.logger.Log("level", "info", "message", "published IP to DNS", "name", u.name, "ip", ip.String(), "previous", ipString(u.published))
+	u.published = ip
+
+	return nil
+}
+
+// Remove deletes the A and AAAA records of the configured name. It is a no-op
+// when the records do not exist.
+func (u *Updater) Remove(ctx context.Context) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	m := new(dns.Msg)
+	m.SetUpdate(u.zone)
+	m.RemoveRRset(u.rrsets())
+
+	err := u.exchange(ctx, m)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_ = u.logger.Log("level", "info", "message", "removed IP from DNS", "name", u.name, "ip", ipString(u.published))
+	u.published = nil
+
+	return nil
+}
+
+func (u *Updater) exchange(ctx context.Context, m *dns.Msg) error {
+	r, err := u.send(ctx, m)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return microerror.Maskf(updateFailedError, "server %s responded %s for %s", u.server, dns.RcodeToString[r.Rcode], u.name)
+	}
+
+	return nil
+}
+
+// inSync returns whether the server serves exactly the given IP as A and AAAA
+// records of the configured name.
+func (u *Updater) inSync(ctx context.Context, ip net.IP) (bool, error) {
+	var ips []net.IP
+	for _, rrtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		m := new(dns.Msg)
+		m.SetQuestion(u.name, rrtype)
+
+		r, err := u.send(ctx, m)
+		if err != nil {
+			return false, microerror.Mask(err)
+		}
+		if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			return false, microerror.Maskf(queryFailedError, "server %s responded %s for %s", u.server, dns.RcodeToString[r.Rcode], u.name)
+		}
+
+		for _, rr := range r.Answer {
+			if !strings.EqualFold(rr.Header().Name, u.name) {
+				continue
+			}
+
+			switch rr := rr.(type) {
+			case *dns.A:
+				ips = append(ips, rr.A)
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA)
+			}
+		}
+	}
+
+	return len(ips) == 1 && ips[0].Equal(ip), nil
+}
+
+// send signs the given message when configured and sends it to the server.
+func (u *Updater) send(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	if u.tsigKey != "" {
+		m.SetTsig(u.tsigKey, u.tsigAlgorithm, tsigFudge, time.Now().Unix())
+	}
+
+	if u.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.timeout)
+		defer cancel()
+	}
+
+	r, _, err := u.client.ExchangeContext(ctx, m, u.server)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return r, nil
+}
+
+func (u *Updater) header(rrtype uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{
+		Name:   u.name,
+		Rrtype: rrtype,
+		Class:  dns.ClassINET,
+		Ttl:    ttl,
+	}
+}
+
+// rrsets returns the RRsets owned by the updater, to be used with
+// RemoveRRset.
+func (u *Updater) rrsets() []dns.RR {
+	return []dns.RR{
+		&dns.ANY{Hdr: u.header(dns.TypeA, 0)},
+		&dns.ANY{Hdr: u.header(dns.TypeAAAA, 0)},
+	}
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	return ip.String()
+}
@@ -0,0 +1,387 @@
+package dnsupdater
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"github.com/miekg/dns"
+)
+
+const (
+	testKey    = "updater."
+	testSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0"
+)
+
+// testServer is an in-process DNS server applying dynamic updates of the zone
+// example.com. to its records and answering queries for them. Updates which
+// are not signed with the test key are refused.
+type testServer struct {
+	addr string
+
+	mutex   sync.Mutex
+	records map[uint16][]string
+	updates int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		addr:    pc.LocalAddr().String(),
+		records: map[uint16][]string{},
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn: pc,
+		Handler:    dns.HandlerFunc(s.serveDNS),
+		// The default rejects everything but queries and notifies.
+		MsgAcceptFunc:     func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+		NotifyStartedFunc: func() { close(started) },
+		TsigSecret:        map[string]string{testKey: testSecret},
+	}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	<-started
+
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+
+	return s
+}
+
+func (s *testServer) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	switch {
+	case r.Opcode == dns.OpcodeQuery && r.Question[0].Name == "api.example.com.":
+		for _, rr := range s.records[r.Question[0].Qtype] {
+			answer, err := dns.NewRR(rr)
+			if err != nil {
+				m.Rcode = dns.RcodeServerFailure
+				break
+			}
+			m.Answer = append(m.Answer, answer)
+		}
+		if len(s.records) == 0 {
+			m.Rcode = dns.RcodeNameError
+		}
+	case r.Opcode != dns.OpcodeUpdate || r.Question[0].Name != "example.com.":
+		m.Rcode = dns.RcodeRefused
+	case r.IsTsig() == nil || w.TsigStatus() != nil:
+		m.Rcode = dns.RcodeNotAuth
+	default:
+		s.updates++
+		for _, rr := range r.Ns {
+			h := rr.Header()
+			if h.Name != "api.example.com." {
+				continue
+			}
+
+			switch h.Class {
+			case dns.ClassANY:
+				delete(s.records, h.Rrtype)
+			case dns.ClassINET:
+				s.records[h.Rrtype] = append(s.records[h.Rrtype], rr.String())
+			}
+		}
+	}
+
+	if r.IsTsig() != nil && w.TsigStatus() == nil {
+		m.SetTsig(testKey, dns.HmacSHA256, tsigFudge, time.Now().Unix())
+	}
+
+	_ = w.WriteMsg(m)
+}
+
+func (s *testServer) state() (map[uint16][]string, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	records := map[uint16][]string{}
+	for k, v := range s.records {
+		records[k] = v
+	}
+
+	return records, s.updates
+}
+
+// setRecords replaces the records of the server, e.g. by someone else than the
+// updater.
+func (s *testServer) setRecords(records map[uint16][]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.records = records
+}
+
+func newTestUpdater(t *testing.T, server string, secret string) *Updater {
+	t.Helper()
+
+	c := DefaultConfig()
+	c.Logger = microloggertest.New()
+	c.Name = "api.example.com"
+	c.Server = server
+	c.Timeout = time.Second
+	c.TSIGKey = "updater"
+	c.TSIGSecret = secret
+
+	u, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func Test_Updater_Update(t *testing.T) {
+	s := newTestServer(t)
+	u := newTestUpdater(t, s.addr, testSecret)
+
+	err := u.Update(context.Background(), net.ParseIP("10.1.2.3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, updates := s.state()
+	if len(records[dns.TypeA]) != 1 || len(records[dns.TypeAAAA]) != 0 || updates != 1 {
+		t.Fatalf("records == %v after %d updates, want single A record", records, updates)
+	}
+	if records[dns.TypeA][0] != "api.example.com.\t60\tIN\tA\t10.1.2.3" {
+		t.Fatalf("record == %q, want 10.1.2.3", records[dns.TypeA][0])
+	}
+
+	// Updating the same IP again does not send any update as long as the
+	// server serves it.
+	err = u.Update(context.Background(), net.ParseIP("10.1.2.3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, updates := s.state(); updates != 1 {
+		t.Fatalf("updates == %d, want 1", updates)
+	}
+
+	// Changing the IP family replaces the A record.
+	err = u.Update(context.Background(), net.ParseIP("fd00::3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, updates = s.state()
+	if len(records[dns.TypeA]) != 0 || len(records[dns.TypeAAAA]) != 1 || updates != 2 {
+		t.Fatalf("records == %v after %d updates, want single AAAA record", records, updates)
+	}
+
+	err = u.Remove(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ = s.state()
+	if len(records) != 0 {
+		t.Fatalf("records == %v, want none", records)
+	}
+
+	// The IP is published again after having been removed.
+	err = u.Update(context.Background(), net.ParseIP("fd00::3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, updates := s.state(); updates != 4 {
+		t.Fatalf("updates == %d, want 4", updates)
+	}
+}
+
+func Test_Updater_Update_Changed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		records map[uint16][]string
+	}{
+		{
+			name:    "case 0: record removed by someone else is restored",
+			records: map[uint16][]string{},
+		},
+		{
+			name: "case 1: record replaced by someone else is restored",
+			records: map[uint16][]string{
+				dns.TypeA: {"api.example.com.\t60\tIN\tA\t10.9.9.9"},
+			},
+		},
+		{
+			name: "case 2: record added by someone else is removed",
+			records: map[uint16][]string{
+				dns.TypeA:    {"api.example.com.\t60\tIN\tA\t10.1.2.3"},
+				dns.TypeAAAA: {"api.example.com.\t60\tIN\tAAAA\tfd00::9"},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			s := newTestServer(t)
+			u := newTestUpdater(t, s.addr, testSecret)
+
+			err := u.Update(context.Background(), net.ParseIP("10.1.2.3"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s.setRecords(tc.records)
+
+			err = u.Update(context.Background(), net.ParseIP("10.1.2.3"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			records, updates := s.state()
+			if len(records[dns.TypeA]) != 1 || len(records[dns.TypeAAAA]) != 0 || updates != 2 {
+				t.Fatalf("records == %v after %d updates, want single A record", records, updates)
+			}
+			if records[dns.TypeA][0] != "api.example.com.\t60\tIN\tA\t10.1.2.3" {
+				t.Fatalf("record == %q, want 10.1.2.3", records[dns.TypeA][0])
+			}
+		})
+	}
+}
+
+func Test_Updater_Update_Failed(t *testing.T) {
+	s := newTestServer(t)
+
+	testCases := []struct {
+		name         string
+		server       string
+		secret       string
+		errorMatcher func(error) bool
+	}{
+		{
+			name:         "case 0: wrong secret",
+			server:       s.addr,
+			secret:       "d3Jvbmctc2VjcmV0",
+			errorMatcher: IsUpdateFailed,
+		},
+		{
+			name:         "case 1: unsigned update",
+			server:       s.addr,
+			secret:       "",
+			errorMatcher: IsUpdateFailed,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			c := DefaultConfig()
+			c.Logger = microloggertest.New()
+			c.Name = "api.example.com"
+			c.Server = tc.server
+			c.Timeout = time.Second
+			if tc.secret != "" {
+				c.TSIGKey = "updater"
+				c.TSIGSecret = tc.secret
+			}
+
+			u, err := New(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = u.Update(context.Background(), net.ParseIP("10.1.2.3"))
+			if !tc.errorMatcher(err) {
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if records, _ := s.state(); len(records) != 0 {
+				t.Fatalf("records == %v, want none", records)
+			}
+		})
+	}
+}
+
+func Test_New(t *testing.T) {
+	testCases := []struct {
+		config       func(c *Config)
+		expectedZone string
+		errorMatcher func(error) bool
+	}{
+		{
+			config:       func(c *Config) {},
+			expectedZone: "example.com.",
+		},
+		{
+			config:       func(c *Config) { c.Zone = "Example.com" },
+			expectedZone: "example.com.",
+		},
+		{
+			config:       func(c *Config) { c.Zone = "other.com" },
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			config:       func(c *Config) { c.Name = "com" },
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			config:       func(c *Config) { c.Server = "127.0.0.1" },
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			config:       func(c *Config) { c.TSIGKey = "updater" },
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			config:       func(c *Config) { c.TSIGKey = "updater"; c.TSIGSecret = "%" },
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			config:       func(c *Config) { c.TSIGKey = "updater"; c.TSIGSecret = testSecret; c.TSIGAlgorithm = "hmac-sha3" },
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			config:       func(c *Config) { c.Net = "sctp" },
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			c := DefaultConfig()
+			c.Logger = microloggertest.New()
+			c.Name = "api.example.com"
+			c.Server = "127.0.0.1:53"
+			tc.config(&c)
+
+			u, err := New(c)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && u.zone != tc.expectedZone {
+				t.Fatalf("zone == %q, want %q", u.zone, tc.expectedZone)
+			}
+		})
+	}
+}
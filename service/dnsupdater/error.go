@@ -0,0 +1,24 @@
+package dnsupdater
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var updateFailedError = microerror.New("update failed")
+
+// IsUpdateFailed asserts updateFailedError.
+func IsUpdateFailed(err error) bool {
+	return microerror.Cause(err) == updateFailedError
+}
+
+var queryFailedError = microerror.New("query failed")
+
+// IsQueryFailed asserts queryFailedError.
+func IsQueryFailed(err error) bool {
+	return microerror.Cause(err) == queryFailedError
+}
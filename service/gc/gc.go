@@ -0,0 +1,216 @@
+// Package gc implements the garbage collection of objects created by the
+// updater whose owners do not exist anymore.
+package gc
+
+import (
+	"context"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
+)
+
+var (
+	// managedResources are all resources the updater creates objects of.
+	managedResources = []schema.GroupVersionResource{
+		{Version: "v1", Resource: "configmaps"},
+		{Version: "v1", Resource: "endpoints"},
+		{Group: "discovery.k8s.io", Version: "v1", Resource: "endpointslices"},
+		{Group: "endpoint.kvm.giantswarm.io", Version: "v1alpha1", Resource: "endpointbindings"},
+	}
+)
+
+// Config represents the configuration used to create a new garbage collector.
+type Config struct {
+	// Dependencies.
+	DynamicClient dynamic.Interface
+	K8sClient     kubernetes.Interface
+	Logger        micrologger.Logger
+
+	// Settings.
+
+	// DryRun only logs orphaned objects instead of deleting them.
+	DryRun bool
+	// Namespace restricts the garbage collection to a single namespace. All
+	// namespaces are considered when empty.
+	Namespace string
+}
+
+// DefaultConfig provides a default configuration to create a new garbage
+// collector by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		DynamicClient: nil,
+		K8sClient:     nil,
+		Logger:        nil,
+
+		// Settings.
+		DryRun:    false,
+		Namespace: "",
+	}
+}
+
+// New creates a new garbage collector.
+func New(config Config) (*GC, error) {
+	// Dependencies.
+	if config.DynamicClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.DynamicClient must not be empty")
+	}
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.K8sClient must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	newGC := &GC{
+		// Dependencies.
+		dynamicClient: config.DynamicClient,
+		k8sClient:     config.K8sClient,
+		logger:        config.Logger,
+
+		// Settings.
+		dryRun:    config.DryRun,
+		namespace: config.Namespace,
+	}
+
+	return newGC, nil
+}
+
+type GC struct {
+	// Dependencies.
+	dynamicClient dynamic.Interface
+	k8sClient     kubernetes.Interface
+	logger        micrologger.Logger
+
+	// Settings.
+	dryRun    bool
+	namespace string
+}
+
+// Collect finds objects labelled as managed by the updater whose owners do not
+// exist anymore and deletes them. It returns the number of orphaned objects.
+func (g *GC) Collect(ctx context.Context) (int, error) {
+	var orphans int
+
+	selector := metav1.ListOptions{
+		LabelSelector: updater.LabelManagedBy + "=" + updater.ManagedBy,
+	}
+
+	for _, r := range managedResources {
+		var list *unstructured.UnstructuredList
+		err := call.Do(ctx, func() error {
+			var err error
+			list, err = g.dynamicClient.Resource(r).Namespace(g.namespace).List(selector)
+			return err
+		})
+		if errors.IsNotFound(err) {
+			// The resource is not served by the cluster, e.g. the EndpointBinding
+			// CRD is not installed.
+			continue
+		} else if err != nil {
+			return 0, microerror.Mask(err)
+		}
+
+		for _, item := range list.Items {
+			orphaned, err := g.isOrphaned(ctx, item)
+			if err != nil {
+				return 0, microerror.Mask(err)
+			}
+			if !orphaned {
+				continue
+			}
+
+			orphans++
+
+			if g.dryRun {
+				_ = g.logger.Log("level", "info", "message", "found orphaned object", "resource", r.Resource, "namespace", item.GetNamespace(), "name", item.GetName())
+				continue
+			}
+
+			err = call.Do(ctx, func() error {
+				return g.dynamicClient.Resource(r).Namespace(item.GetNamespace()).Delete(item.GetName(), &metav1.DeleteOptions{})
+			})
+			if errors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return 0, microerror.Mask(err)
+			}
+
+			_ = g.logger.Log("level", "info", "message", "deleted orphaned object", "resource", r.Resource, "namespace", item.GetNamespace(), "name", item.GetName())
+		}
+	}
+
+	return orphans, nil
+}
+
+// isOrphaned checks whether any owner of the given object is gone. Objects
+// created before owner references were set are checked against the KVM pod
+// named by their pod annotation or, for older objects, their pod label.
+func (g *GC) isOrphaned(ctx context.Context, obj unstructured.Unstructured) (bool, error) {
+	owners := obj.GetOwnerReferences()
+
+	if len(owners) == 0 {
+		podName := obj.GetAnnotations()[updater.AnnotationPod]
+		if podName == "" {
+			podName = obj.GetLabels()[updater.LabelPod]
+		}
+		if podName == "" {
+			return false, nil
+		}
+
+		owners = []metav1.OwnerReference{{Kind: "Pod", Name: podName}}
+	}
+
+	for _, o := range owners {
+		var uid types.UID
+		var err error
+
+		switch o.Kind {
+		case "Pod":
+			err = call.Do(ctx, func() error {
+				p, err := g.k8sClient.CoreV1().Pods(obj.GetNamespace()).Get(o.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				uid = p.GetUID()
+				return nil
+			})
+		case "Service":
+			err = call.Do(ctx, func() error {
+				s, err := g.k8sClient.CoreV1().Services(obj.GetNamespace()).Get(o.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				uid = s.GetUID()
+				return nil
+			})
+		default:
+			continue
+		}
+
+		if errors.IsNotFound(err) {
+			return true, nil
+		} else if err != nil {
+			return false, microerror.Mask(err)
+		}
+
+		// An owner with the same name but a different UID got recreated and is
+		// not the owner of the object anymore.
+		if o.UID != "" && o.UID != uid {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,17 @@
+package health
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var notReadyError = microerror.New("not ready")
+
+// IsNotReady asserts notReadyError.
+func IsNotReady(err error) bool {
+	return microerror.Cause(err) == notReadyError
+}
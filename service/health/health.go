@@ -0,0 +1,99 @@
+// Package health tracks the reconciliation state used to answer liveness and
+// readiness probes.
+package health
+
+import (
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Config represents the configuration used to create a new health tracker.
+type Config struct {
+	// Settings.
+
+	// MaxAge is the maximum age of the last successful reconciliation for the
+	// tracker to be considered ready. Zero disables the freshness check so that
+	// a single successful reconciliation is sufficient.
+	MaxAge time.Duration
+}
+
+// DefaultConfig provides a default configuration to create a new health
+// tracker by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Settings.
+		MaxAge: 0,
+	}
+}
+
+// New creates a new health tracker.
+func New(config Config) (*Health, error) {
+	// Settings.
+	if config.MaxAge < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.MaxAge must not be negative")
+	}
+
+	newHealth := &Health{
+		// Internals.
+		mutex:         sync.RWMutex{},
+		lastReconcile: time.Time{},
+		standby:       false,
+
+		// Settings.
+		maxAge: config.MaxAge,
+	}
+
+	return newHealth, nil
+}
+
+type Health struct {
+	// Internals.
+	mutex         sync.RWMutex
+	lastReconcile time.Time
+	standby       bool
+
+	// Settings.
+	maxAge time.Duration
+}
+
+// Reconciled records a successful reconciliation at the given time.
+func (h *Health) Reconciled(t time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastReconcile = t
+}
+
+// Standby records whether we stand by, e.g. while waiting for the leadership.
+// Standing by is considered ready, as there is nothing to reconcile.
+func (h *Health) Standby(standby bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.standby = standby
+}
+
+// Ready returns an error matched by IsNotReady when no reconciliation
+// succeeded yet or the last successful one is older than the configured
+// maximum age, unless we stand by.
+func (h *Health) Ready() error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.standby {
+		return nil
+	}
+	if h.lastReconcile.IsZero() {
+		return microerror.Maskf(notReadyError, "endpoint not published yet")
+	}
+	if h.maxAge != 0 {
+		age := time.Since(h.lastReconcile)
+		if age > h.maxAge {
+			return microerror.Maskf(notReadyError, "last successful reconciliation %s ago exceeds %s", age.Round(time.Second), h.maxAge)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package ipmath
+
+import "github.com/giantswarm/microerror"
+
+var invalidIPError = microerror.New("invalid IP")
+
+// IsInvalidIP asserts invalidIPError.
+func IsInvalidIP(err error) bool {
+	return microerror.Cause(err) == invalidIPError
+}
+
+var outOfRangeError = microerror.New("out of range")
+
+// IsOutOfRange asserts outOfRangeError.
+func IsOutOfRange(err error) bool {
+	return microerror.Cause(err) == outOfRangeError
+}
+
+var overflowError = microerror.New("overflow")
+
+// IsOverflow asserts overflowError.
+func IsOverflow(err error) bool {
+	return microerror.Cause(err) == overflowError
+}
@@ -0,0 +1,178 @@
+// Package ipmath implements arithmetic on IPv4 and IPv6 addresses and their
+// networks. IPv4 addresses are always returned in their 4 byte form, IPv6
+// addresses in their 16 byte form.
+package ipmath
+
+import (
+	"math/big"
+	"net"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Add returns the address n addresses after ip, or before ip for negative n.
+// Results beyond the address space of the family of ip are rejected with an
+// error asserted by IsOverflow instead of wrapping around.
+func Add(ip net.IP, n int64) (net.IP, error) {
+	i, size, err := toInt(ip)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	i.Add(i, big.NewInt(n))
+
+	result, ok := fromInt(i, size)
+	if !ok {
+		return nil, microerror.Maskf(overflowError, "%s %+d", ip, n)
+	}
+
+	return result, nil
+}
+
+// Offset returns the number of addresses from a to b, which is negative when b
+// is before a. Both addresses have to be of the same family.
+func Offset(a, b net.IP) (*big.Int, error) {
+	i, sizeA, err := toInt(a)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	j, sizeB, err := toInt(b)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	if sizeA != sizeB {
+		return nil, microerror.Maskf(invalidIPError, "%s and %s must be of the same family", a, b)
+	}
+
+	return j.Sub(j, i), nil
+}
+
+// Contains reports whether ip is part of the network n. Addresses of the other
+// family are never part of n.
+func Contains(n *net.IPNet, ip net.IP) bool {
+	if n == nil || is4(n.IP) != is4(ip) {
+		return false
+	}
+
+	return n.Contains(ip)
+}
+
+// Network returns the network address of n, i.e. its first address.
+func Network(n *net.IPNet) net.IP {
+	ip := normalize(n.IP)
+	mask := maskFor(n, len(ip))
+
+	return ip.Mask(mask)
+}
+
+// Broadcast returns the broadcast address of n, i.e. its last address. IPv6
+// has no broadcast, but the last address is returned likewise.
+func Broadcast(n *net.IPNet) net.IP {
+	ip := normalize(n.IP)
+	mask := maskFor(n, len(ip))
+
+	last := make(net.IP, len(ip))
+	for i := range ip {
+		last[i] = ip[i] | ^mask[i]
+	}
+
+	return last
+}
+
+// IsNetwork reports whether ip is the network address of n. Networks without
+// room for a network address, i.e. IPv4 /31 and /32 and IPv6 /127 and /128,
+// have none.
+func IsNetwork(n *net.IPNet, ip net.IP) bool {
+	if !Contains(n, ip) || !hasReserved(n) {
+		return false
+	}
+
+	return Network(n).Equal(ip)
+}
+
+// IsBroadcast reports whether ip is the broadcast address of the IPv4 network
+// n. IPv6 networks and IPv4 /31 and /32 have none.
+func IsBroadcast(n *net.IPNet, ip net.IP) bool {
+	if !Contains(n, ip) || !is4(ip) || !hasReserved(n) {
+		return false
+	}
+
+	return Broadcast(n).Equal(ip)
+}
+
+// AddInNetwork is like Add but additionally rejects results not usable as host
+// address of the network n, i.e. addresses outside of n, its network address
+// and its broadcast address, with an error asserted by IsOutOfRange.
+func AddInNetwork(n *net.IPNet, ip net.IP, delta int64) (net.IP, error) {
+	result, err := Add(ip, delta)
+	if IsOverflow(err) {
+		return nil, microerror.Maskf(outOfRangeError, "%s %+d is not within %s", ip, delta, n)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	if !Contains(n, result) {
+		return nil, microerror.Maskf(outOfRangeError, "%s is not within %s", result, n)
+	}
+	if IsNetwork(n, result) {
+		return nil, microerror.Maskf(outOfRangeError, "%s is the network address of %s", result, n)
+	}
+	if IsBroadcast(n, result) {
+		return nil, microerror.Maskf(outOfRangeError, "%s is the broadcast address of %s", result, n)
+	}
+
+	return result, nil
+}
+
+// hasReserved reports whether n is large enough to reserve its first and last
+// address.
+func hasReserved(n *net.IPNet) bool {
+	ones, bits := n.Mask.Size()
+	return bits-ones > 1
+}
+
+func is4(ip net.IP) bool {
+	return ip.To4() != nil
+}
+
+// maskFor returns the mask of n in the given length, as masks of IPv4
+// networks might be given in their 16 byte form.
+func maskFor(n *net.IPNet, size int) net.IPMask {
+	mask := n.Mask
+	if len(mask) == net.IPv6len && size == net.IPv4len {
+		mask = mask[12:]
+	}
+
+	return mask
+}
+
+func normalize(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+
+	return ip.To16()
+}
+
+func toInt(ip net.IP) (*big.Int, int, error) {
+	n := normalize(ip)
+	if n == nil {
+		return nil, 0, microerror.Maskf(invalidIPError, "%s", ip)
+	}
+
+	return new(big.Int).SetBytes(n), len(n), nil
+}
+
+// fromInt returns false when i does not fit into an address of the given
+// size.
+func fromInt(i *big.Int, size int) (net.IP, bool) {
+	if i.Sign() < 0 || i.BitLen() > size*8 {
+		return nil, false
+	}
+
+	b := i.Bytes()
+	ip := make(net.IP, size)
+	copy(ip[size-len(b):], b)
+
+	return ip, true
+}
@@ -0,0 +1,170 @@
+package ipmath
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func Test_Add(t *testing.T) {
+	testCases := []struct {
+		ip           net.IP
+		n            int64
+		expected     string
+		errorMatcher func(error) bool
+	}{
+		{ip: net.ParseIP("10.0.0.1"), n: 1, expected: "10.0.0.2"},
+		{ip: net.ParseIP("10.0.0.255"), n: 1, expected: "10.0.1.0"},
+		{ip: net.ParseIP("10.0.255.255"), n: 1, expected: "10.1.0.0"},
+		{ip: net.ParseIP("10.0.1.0"), n: -1, expected: "10.0.0.255"},
+		{ip: net.ParseIP("10.0.0.1").To4(), n: 256, expected: "10.0.1.1"},
+		{ip: net.ParseIP("255.255.255.255"), n: 1, errorMatcher: IsOverflow},
+		{ip: net.ParseIP("0.0.0.0"), n: -1, errorMatcher: IsOverflow},
+		{ip: net.ParseIP("fd00::ffff"), n: 1, expected: "fd00::1:0"},
+		{ip: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), n: 1, errorMatcher: IsOverflow},
+		{ip: nil, n: 1, errorMatcher: IsInvalidIP},
+		{ip: net.IP{1, 2, 3}, n: 1, errorMatcher: IsInvalidIP},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			ip, err := Add(tc.ip, tc.n)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher != nil {
+				return
+			}
+			if ip.String() != tc.expected {
+				t.Fatalf("ip == %s, want %s", ip, tc.expected)
+			}
+			if tc.ip.To4() != nil && len(ip) != net.IPv4len {
+				t.Fatalf("len(ip) == %d, want %d", len(ip), net.IPv4len)
+			}
+		})
+	}
+}
+
+func Test_Offset(t *testing.T) {
+	testCases := []struct {
+		a            string
+		b            string
+		expected     string
+		errorMatcher func(error) bool
+	}{
+		{a: "10.0.0.1", b: "10.0.1.1", expected: "256"},
+		{a: "10.0.1.1", b: "10.0.0.1", expected: "-256"},
+		{a: "::", b: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", expected: "340282366920938463463374607431768211455"},
+		{a: "10.0.0.1", b: "fd00::1", errorMatcher: IsInvalidIP},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			offset, err := Offset(net.ParseIP(tc.a), net.ParseIP(tc.b))
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && offset.String() != tc.expected {
+				t.Fatalf("offset == %s, want %s", offset, tc.expected)
+			}
+		})
+	}
+}
+
+func Test_Network_Broadcast(t *testing.T) {
+	testCases := []struct {
+		network           string
+		expectedNetwork   string
+		expectedBroadcast string
+	}{
+		{network: "10.0.0.0/24", expectedNetwork: "10.0.0.0", expectedBroadcast: "10.0.0.255"},
+		{network: "10.0.4.0/22", expectedNetwork: "10.0.4.0", expectedBroadcast: "10.0.7.255"},
+		{network: "10.0.0.1/32", expectedNetwork: "10.0.0.1", expectedBroadcast: "10.0.0.1"},
+		{network: "fd00::/64", expectedNetwork: "fd00::", expectedBroadcast: "fd00::ffff:ffff:ffff:ffff"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			n := mustParseCIDR(t, tc.network)
+
+			if ip := Network(n); ip.String() != tc.expectedNetwork {
+				t.Fatalf("network == %s, want %s", ip, tc.expectedNetwork)
+			}
+			if ip := Broadcast(n); ip.String() != tc.expectedBroadcast {
+				t.Fatalf("broadcast == %s, want %s", ip, tc.expectedBroadcast)
+			}
+		})
+	}
+}
+
+func Test_AddInNetwork(t *testing.T) {
+	testCases := []struct {
+		network      string
+		ip           string
+		delta        int64
+		expected     string
+		errorMatcher func(error) bool
+	}{
+		{network: "10.0.0.0/24", ip: "10.0.0.1", delta: 1, expected: "10.0.0.2"},
+		{network: "10.0.0.0/16", ip: "10.0.0.255", delta: 1, expected: "10.0.1.0"},
+		{network: "10.0.0.0/24", ip: "10.0.0.254", delta: 1, errorMatcher: IsOutOfRange},
+		{network: "10.0.0.0/24", ip: "10.0.0.255", delta: 1, errorMatcher: IsOutOfRange},
+		{network: "10.0.0.0/24", ip: "10.0.0.1", delta: -1, errorMatcher: IsOutOfRange},
+		{network: "10.0.0.0/31", ip: "10.0.0.0", delta: 1, expected: "10.0.0.1"},
+		{network: "10.0.0.1/32", ip: "10.0.0.1", delta: 1, errorMatcher: IsOutOfRange},
+		{network: "255.255.255.254/31", ip: "255.255.255.255", delta: 1, errorMatcher: IsOutOfRange},
+		{network: "fd00::/64", ip: "fd00::1", delta: 1, expected: "fd00::2"},
+		{network: "fd00::/64", ip: "fd00::ffff:ffff:ffff:ffff", delta: 1, errorMatcher: IsOutOfRange},
+		{network: "fd00::/64", ip: "fd00::ffff:ffff:ffff:fffe", delta: 1, expected: "fd00::ffff:ffff:ffff:ffff"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			ip, err := AddInNetwork(mustParseCIDR(t, tc.network), net.ParseIP(tc.ip), tc.delta)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && ip.String() != tc.expected {
+				t.Fatalf("ip == %s, want %s", ip, tc.expected)
+			}
+		})
+	}
+}
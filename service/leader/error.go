@@ -0,0 +1,17 @@
+package leader
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var leadershipLostError = microerror.New("leadership lost")
+
+// IsLeadershipLost asserts leadershipLostError.
+func IsLeadershipLost(err error) bool {
+	return microerror.Cause(err) == leadershipLostError
+}
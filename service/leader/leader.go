@@ -0,0 +1,255 @@
+// Package leader implements Lease based leader election so that only a single
+// replica out of several redundant ones writes to Kubernetes.
+package leader
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Config represents the configuration used to create a new leader elector.
+type Config struct {
+	// Dependencies.
+	K8sClient kubernetes.Interface
+	Logger    micrologger.Logger
+
+	// Settings.
+
+	// Identity uniquely identifies the replica taking part in the election,
+	// e.g. the pod name.
+	Identity string
+	// Name is the name of the Lease object used as lock.
+	Name string
+	// Namespace is the namespace of the Lease object used as lock.
+	Namespace string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// DefaultConfig provides a default configuration to create a new leader
+// elector by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		K8sClient: nil,
+		Logger:    nil,
+
+		// Settings.
+		Identity:  "",
+		Name:      "",
+		Namespace: "",
+
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+}
+
+// DefaultIdentity returns an identity unique to this process, consisting of
+// the hostname and a random suffix. Processes sharing a pod share the hostname,
+// so the suffix keeps their identities apart.
+func DefaultIdentity() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	return hostname + "_" + rand.String(8), nil
+}
+
+// New creates a new leader elector.
+func New(config Config) (*Leader, error) {
+	// Dependencies.
+	if config.K8sClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.K8sClient must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Identity == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Identity must not be empty")
+	}
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must not be empty")
+	}
+	if config.Namespace == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Namespace must not be empty")
+	}
+
+	newLeader := &Leader{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Internals.
+		lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      config.Name,
+				Namespace: config.Namespace,
+			},
+			Client: config.K8sClient.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: config.Identity,
+			},
+		},
+
+		// Settings.
+		identity:      config.Identity,
+		name:          config.Name,
+		leaseDuration: config.LeaseDuration,
+		renewDeadline: config.RenewDeadline,
+		retryPeriod:   config.RetryPeriod,
+	}
+
+	return newLeader, nil
+}
+
+type Leader struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Internals.
+	lock resourcelock.Interface
+
+	// Settings.
+	identity      string
+	name          string
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+// Run blocks until the leadership got acquired and then executes lead. The
+// context passed to lead is cancelled as soon as the leadership is lost or the
+// given context is done. The Lease is held until lead returned and released
+// afterwards, so that lead can clean up on termination without another replica
+// taking over in the meantime. Run returns an error matched by
+// IsLeadershipLost once lead returned because of lost leadership, or nil when
+// the given context got cancelled or lead returned on its own.
+func (l *Leader) Run(ctx context.Context, lead func(ctx context.Context)) error {
+	isLeader.WithLabelValues(l.name).Set(0)
+
+	// The elector gets its own context, which is only cancelled once lead
+	// returned. Cancelling it releases the Lease.
+	electorCtx, cancelElector := context.WithCancel(context.Background())
+	defer cancelElector()
+
+	var mutex sync.Mutex
+	var leading bool
+	var lost bool
+	finished := make(chan struct{})
+
+	// When the given context is done before the leadership got acquired there
+	// is nothing to wait for and the elector is stopped right away.
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-electorCtx.Done():
+			return
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if !leading {
+			cancelElector()
+		}
+	}()
+
+	c := leaderelection.LeaderElectionConfig{
+		Lock:          l.lock,
+		LeaseDuration: l.leaseDuration,
+		RenewDeadline: l.renewDeadline,
+		RetryPeriod:   l.retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(electedCtx context.Context) {
+				mutex.Lock()
+				if electorCtx.Err() != nil {
+					mutex.Unlock()
+					return
+				}
+				leading = true
+				mutex.Unlock()
+
+				defer close(finished)
+				defer cancelElector()
+
+				_ = l.logger.Log("level", "info", "message", "acquired leadership", "identity", l.identity)
+				isLeader.WithLabelValues(l.name).Set(1)
+				transitionTotal.WithLabelValues(l.name, "acquired").Inc()
+
+				leadCtx, cancel := context.WithCancel(electedCtx)
+				defer cancel()
+
+				go func() {
+					select {
+					case <-ctx.Done():
+						cancel()
+					case <-leadCtx.Done():
+					}
+				}()
+
+				lead(leadCtx)
+
+				lost = electedCtx.Err() != nil && ctx.Err() == nil
+			},
+			OnStoppedLeading: func() {},
+			OnNewLeader: func(identity string) {
+				if identity == l.identity {
+					return
+				}
+				_ = l.logger.Log("level", "info", "message", "observed new leader", "leader", identity, "identity", l.identity)
+			},
+		},
+		ReleaseOnCancel: true,
+		Name:            l.lock.Describe(),
+	}
+
+	elector, err := leaderelection.NewLeaderElector(c)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	elector.Run(electorCtx)
+
+	// The elector does not wait for lead to return when the leadership got
+	// lost. We do, so that the caller can rely on lead being done once Run
+	// returned. Stopping the elector first makes sure that lead does not start
+	// after we checked.
+	cancelElector()
+
+	mutex.Lock()
+	wasLeading := leading
+	mutex.Unlock()
+
+	if wasLeading {
+		<-finished
+		isLeader.WithLabelValues(l.name).Set(0)
+		if lost {
+			_ = l.logger.Log("level", "warning", "message", "lost leadership", "identity", l.identity)
+			transitionTotal.WithLabelValues(l.name, "lost").Inc()
+		} else {
+			_ = l.logger.Log("level", "info", "message", "released leadership", "identity", l.identity)
+			transitionTotal.WithLabelValues(l.name, "released").Inc()
+		}
+	}
+
+	if lost {
+		return microerror.Maskf(leadershipLostError, "identity %s", l.identity)
+	}
+
+	return nil
+}
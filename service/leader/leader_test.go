@@ -0,0 +1,158 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestLeader(t *testing.T, identity string, objects ...runtime.Object) (*Leader, *fake.Clientset) {
+	t.Helper()
+
+	k8sClient := fake.NewSimpleClientset(objects...)
+
+	c := DefaultConfig()
+	c.K8sClient = k8sClient
+	c.Logger = microloggertest.New()
+
+	c.Identity = identity
+	c.Name = "kvm-endpoint-updater"
+	c.Namespace = "default"
+
+	c.LeaseDuration = 2 * time.Second
+	c.RenewDeadline = time.Second
+	c.RetryPeriod = 100 * time.Millisecond
+
+	l, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l, k8sClient
+}
+
+func holder(t *testing.T, k8sClient *fake.Clientset) string {
+	t.Helper()
+
+	lease, err := k8sClient.CoordinationV1().Leases("default").Get("kvm-endpoint-updater", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+
+	return *lease.Spec.HolderIdentity
+}
+
+// Test_Leader_Run_HoldsLeaseUntilLeadReturned verifies that the Lease is kept
+// while lead cleans up after the context got cancelled and released only
+// afterwards.
+func Test_Leader_Run_HoldsLeaseUntilLeadReturned(t *testing.T) {
+	l, k8sClient := newTestLeader(t, "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	acquired := make(chan struct{})
+	var holderOnCleanup string
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- l.Run(ctx, func(ctx context.Context) {
+			close(acquired)
+			<-ctx.Done()
+
+			// Cleaning up takes longer than the Lease would be held without
+			// renewal.
+			time.Sleep(3 * time.Second)
+			holderOnCleanup = holder(t, k8sClient)
+		})
+	}()
+
+	select {
+	case <-acquired:
+	case <-time.After(10 * time.Second):
+		t.Fatal("leadership not acquired")
+	}
+
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return")
+	}
+
+	if holderOnCleanup != "a" {
+		t.Fatalf("holder on cleanup == %q, want %q", holderOnCleanup, "a")
+	}
+	if h := holder(t, k8sClient); h != "" {
+		t.Fatalf("holder after Run == %q, want released", h)
+	}
+}
+
+// Test_Leader_Run_CancelledWhileFollowing verifies that Run returns without
+// executing lead when the context gets cancelled before the leadership got
+// acquired.
+func Test_Leader_Run_CancelledWhileFollowing(t *testing.T) {
+	identity := "b"
+	leaseDuration := int32(60)
+	now := metav1.NewMicroTime(time.Now())
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kvm-endpoint-updater",
+			Namespace: "default",
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &identity,
+			LeaseDurationSeconds: &leaseDuration,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}
+
+	l, k8sClient := newTestLeader(t, "a", lease)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	var led bool
+	err := l.Run(ctx, func(ctx context.Context) {
+		led = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if led {
+		t.Fatal("lead must not be executed")
+	}
+	if h := holder(t, k8sClient); h != "b" {
+		t.Fatalf("holder == %q, want %q", h, "b")
+	}
+}
+
+func Test_Leader_DefaultIdentity(t *testing.T) {
+	a, err := DefaultIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := DefaultIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a == b {
+		t.Fatalf("identities must differ, got %q twice", a)
+	}
+}
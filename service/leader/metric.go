@@ -0,0 +1,36 @@
+package leader
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	prometheusNamespace = "k8s_endpoint_updater"
+	prometheusSubsystem = "leader"
+)
+
+var (
+	isLeader = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "is_leader",
+			Help:      "Whether this replica currently holds the leadership. 1 means leader, 0 means follower.",
+		},
+		[]string{"name"},
+	)
+	transitionTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "transition_total",
+			Help:      "Number of leadership transitions of this replica partitioned by transition, i.e. acquired, lost or released.",
+		},
+		[]string{"name", "transition"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(isLeader)
+	prometheus.MustRegister(transitionTotal)
+}
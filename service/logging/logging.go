@@ -0,0 +1,279 @@
+// Package logging implements the logger used by all commands. It filters
+// messages by level, renders them as JSON or logfmt text and formats errors as
+// human readable chains. Format and level can be changed after creation, so
+// that the logger can be handed out before command line flags are parsed.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/giantswarm/micrologger/loggermeta"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-stack/stack"
+)
+
+const (
+	// FormatJSON renders every message as JSON object.
+	FormatJSON = "json"
+	// FormatText renders every message as logfmt line.
+	FormatText = "text"
+)
+
+const (
+	LevelDebug   = "debug"
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+const (
+	// KeyLevel is the key of the level of a message. Messages without level
+	// are logged as LevelInfo.
+	KeyLevel = "level"
+	// KeyMessage is the key of the human readable message.
+	KeyMessage = "message"
+	// KeyStack is the key of the stack of logged errors. It is only added on
+	// LevelDebug.
+	KeyStack = "stack"
+)
+
+var (
+	levelOrder = map[string]int{
+		LevelDebug:   0,
+		LevelInfo:    1,
+		LevelWarning: 2,
+		LevelError:   3,
+	}
+)
+
+// Config represents the configuration used to create a new logger.
+type Config struct {
+	// Dependencies.
+	IOWriter io.Writer
+
+	// Settings.
+	Format string
+	Level  string
+}
+
+// DefaultConfig provides a default configuration to create a new logger by
+// best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		IOWriter: os.Stdout,
+
+		// Settings.
+		Format: FormatJSON,
+		Level:  LevelInfo,
+	}
+}
+
+// New creates a new logger.
+func New(config Config) (*Logger, error) {
+	// Dependencies.
+	if config.IOWriter == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.IOWriter must not be empty")
+	}
+
+	s := &settings{
+		writer: kitlog.NewSyncWriter(config.IOWriter),
+	}
+
+	// Settings.
+	err := s.setFormat(config.Format)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	err = s.setLevel(config.Level)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	newLogger := &Logger{
+		settings: s,
+	}
+
+	return newLogger, nil
+}
+
+// Logger implements micrologger.Logger.
+type Logger struct {
+	// settings are shared with all loggers created by With.
+	settings *settings
+	keyVals  []interface{}
+}
+
+type settings struct {
+	mutex  sync.RWMutex
+	format string
+	level  int
+	logger kitlog.Logger
+	writer io.Writer
+}
+
+// Formats returns all supported formats.
+func Formats() []string {
+	return []string{
+		FormatJSON,
+		FormatText,
+	}
+}
+
+// Levels returns all supported levels in increasing severity.
+func Levels() []string {
+	return []string{
+		LevelDebug,
+		LevelInfo,
+		LevelWarning,
+		LevelError,
+	}
+}
+
+func (l *Logger) Log(keyVals ...interface{}) error {
+	return l.log(stack.Caller(1), keyVals)
+}
+
+func (l *Logger) LogCtx(ctx context.Context, keyVals ...interface{}) error {
+	meta, ok := loggermeta.FromContext(ctx)
+	if ok {
+		keyVals = append([]interface{}{}, keyVals...)
+		for k, v := range meta.KeyVals {
+			keyVals = append(keyVals, k, v)
+		}
+	}
+
+	return l.log(stack.Caller(1), keyVals)
+}
+
+// SetFormat changes the format of this logger and all loggers created by With.
+func (l *Logger) SetFormat(format string) error {
+	err := l.settings.setFormat(format)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// SetLevel changes the minimum level of messages logged by this logger and all
+// loggers created by With.
+func (l *Logger) SetLevel(level string) error {
+	err := l.settings.setLevel(level)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (l *Logger) With(keyVals ...interface{}) micrologger.Logger {
+	newLogger := &Logger{
+		settings: l.settings,
+		keyVals:  append(append([]interface{}{}, l.keyVals...), keyVals...),
+	}
+
+	return newLogger
+}
+
+func (l *Logger) log(caller stack.Call, keyVals []interface{}) error {
+	keyVals = append(append([]interface{}{}, l.keyVals...), keyVals...)
+	if len(keyVals)%2 != 0 {
+		keyVals = append(keyVals, kitlog.ErrMissingValue)
+	}
+
+	level := LevelInfo
+	for i := 0; i < len(keyVals); i += 2 {
+		if keyVals[i] == KeyLevel {
+			s, ok := keyVals[i+1].(string)
+			if ok {
+				if _, ok := levelOrder[s]; ok {
+					level = s
+				}
+			}
+		}
+	}
+
+	l.settings.mutex.RLock()
+	logger := l.settings.logger
+	minLevel := l.settings.level
+	l.settings.mutex.RUnlock()
+
+	if levelOrder[level] < minLevel {
+		return nil
+	}
+
+	// The level and message come first, which matters for the text format.
+	out := []interface{}{
+		"time", time.Now().UTC().Format("2006-01-02T15:04:05.999999-07:00"),
+		KeyLevel, level,
+	}
+	for i := 0; i < len(keyVals); i += 2 {
+		if keyVals[i] == KeyMessage {
+			out = append(out, keyVals[i], keyVals[i+1])
+		}
+	}
+	for i := 0; i < len(keyVals); i += 2 {
+		k, v := keyVals[i], keyVals[i+1]
+		if k == KeyLevel || k == KeyMessage {
+			continue
+		}
+
+		err, ok := v.(error)
+		if !ok {
+			out = append(out, k, v)
+			continue
+		}
+
+		// Masked errors render the messages of their chain without locations,
+		// e.g. "service must not be empty: invalid config". The locations are
+		// only part of the stack.
+		out = append(out, k, err.Error())
+		if minLevel == levelOrder[LevelDebug] && microerror.Stack(err) != err.Error() {
+			out = append(out, KeyStack, microerror.Stack(err))
+		}
+	}
+	out = append(out, "caller", fmt.Sprintf("%+v", caller))
+
+	return logger.Log(out...)
+}
+
+func (s *settings) setFormat(format string) error {
+	var logger kitlog.Logger
+	switch format {
+	case FormatJSON:
+		logger = kitlog.NewJSONLogger(s.writer)
+	case FormatText:
+		logger = kitlog.NewLogfmtLogger(s.writer)
+	default:
+		return microerror.Maskf(invalidConfigError, "format must be one of %s", strings.Join(Formats(), ", "))
+	}
+
+	s.mutex.Lock()
+	s.format = format
+	s.logger = logger
+	s.mutex.Unlock()
+
+	return nil
+}
+
+func (s *settings) setLevel(level string) error {
+	l, ok := levelOrder[level]
+	if !ok {
+		return microerror.Maskf(invalidConfigError, "level must be one of %s", strings.Join(Levels(), ", "))
+	}
+
+	s.mutex.Lock()
+	s.level = l
+	s.mutex.Unlock()
+
+	return nil
+}
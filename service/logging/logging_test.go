@@ -0,0 +1,255 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/loggermeta"
+)
+
+func Test_Logger_Log_Level(t *testing.T) {
+	testCases := []struct {
+		name          string
+		level         string
+		keyVals       []interface{}
+		expectedLevel string
+	}{
+		{
+			name:          "case 0: message with the configured level is logged",
+			level:         LevelInfo,
+			keyVals:       []interface{}{"level", "info", "message", "test"},
+			expectedLevel: LevelInfo,
+		},
+		{
+			name:          "case 1: message with a higher level is logged",
+			level:         LevelInfo,
+			keyVals:       []interface{}{"level", "error", "message", "test"},
+			expectedLevel: LevelError,
+		},
+		{
+			name:          "case 2: message with a lower level is dropped",
+			level:         LevelWarning,
+			keyVals:       []interface{}{"level", "info", "message", "test"},
+			expectedLevel: "",
+		},
+		{
+			name:          "case 3: message without level is logged as info",
+			level:         LevelInfo,
+			keyVals:       []interface{}{"message", "test"},
+			expectedLevel: LevelInfo,
+		},
+		{
+			name:          "case 4: message without level is dropped above info",
+			level:         LevelError,
+			keyVals:       []interface{}{"message", "test"},
+			expectedLevel: "",
+		},
+		{
+			name:          "case 5: message with unknown level is logged as info",
+			level:         LevelDebug,
+			keyVals:       []interface{}{"level", "trace", "message", "test"},
+			expectedLevel: LevelInfo,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var b bytes.Buffer
+			l := newTestLogger(t, &b, FormatJSON, tc.level)
+
+			err := l.Log(tc.keyVals...)
+			if err != nil {
+				t.Fatalf("error == %#v, want nil", err)
+			}
+
+			if tc.expectedLevel == "" {
+				if b.Len() != 0 {
+					t.Fatalf("output == %q, want empty", b.String())
+				}
+				return
+			}
+
+			m := decode(t, &b)
+			if m[KeyLevel] != tc.expectedLevel {
+				t.Fatalf("level == %q, want %q", m[KeyLevel], tc.expectedLevel)
+			}
+			if m[KeyMessage] != "test" {
+				t.Fatalf("message == %q, want %q", m[KeyMessage], "test")
+			}
+		})
+	}
+}
+
+func Test_Logger_SetLevel(t *testing.T) {
+	var b bytes.Buffer
+	l := newTestLogger(t, &b, FormatJSON, LevelInfo)
+	child := l.With("component", "test")
+
+	_ = child.Log("level", "debug", "message", "test")
+	if b.Len() != 0 {
+		t.Fatalf("output == %q, want empty", b.String())
+	}
+
+	err := l.SetLevel(LevelDebug)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	_ = child.Log("level", "debug", "message", "test")
+	if b.Len() == 0 {
+		t.Fatalf("output is empty, want debug message of logger created by With")
+	}
+
+	err = l.SetLevel("trace")
+	if !IsInvalidConfig(err) {
+		t.Fatalf("error == %#v, want invalid config", err)
+	}
+}
+
+func Test_Logger_SetFormat(t *testing.T) {
+	var b bytes.Buffer
+	l := newTestLogger(t, &b, FormatJSON, LevelInfo)
+	child := l.With("component", "test")
+
+	_ = child.Log("level", "warning", "message", "test message", "key", "value")
+	m := decode(t, &b)
+	if m["component"] != "test" || m["key"] != "value" {
+		t.Fatalf("output == %#v, want component and key", m)
+	}
+
+	err := l.SetFormat(FormatText)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	_ = child.Log("level", "warning", "message", "test message", "key", "value")
+	line := b.String()
+
+	// The level and message come right after the time.
+	fields := strings.SplitN(line, " ", 2)
+	if !strings.HasPrefix(fields[0], "time=") {
+		t.Fatalf("output == %q, want time first", line)
+	}
+	if !strings.HasPrefix(fields[1], `level=warning message="test message" component=test key=value `) {
+		t.Fatalf("output == %q, want level, message and key values in order", line)
+	}
+
+	err = l.SetFormat("yaml")
+	if !IsInvalidConfig(err) {
+		t.Fatalf("error == %#v, want invalid config", err)
+	}
+}
+
+func Test_Logger_With(t *testing.T) {
+	var b bytes.Buffer
+	l := newTestLogger(t, &b, FormatJSON, LevelInfo)
+
+	parent := l.With("a", "1")
+	child := parent.With("b", "2")
+	sibling := parent.With("c", "3")
+
+	_ = child.Log("message", "test")
+	m := decode(t, &b)
+	if m["a"] != "1" || m["b"] != "2" || m["c"] != nil {
+		t.Fatalf("output == %#v, want a and b", m)
+	}
+
+	_ = sibling.Log("message", "test")
+	m = decode(t, &b)
+	if m["a"] != "1" || m["b"] != nil || m["c"] != "3" {
+		t.Fatalf("output == %#v, want a and c", m)
+	}
+
+	_ = parent.Log("message", "test")
+	m = decode(t, &b)
+	if m["a"] != "1" || m["b"] != nil || m["c"] != nil {
+		t.Fatalf("output == %#v, want a", m)
+	}
+
+	meta := loggermeta.New()
+	meta.KeyVals["d"] = "4"
+	ctx := loggermeta.NewContext(context.Background(), meta)
+
+	_ = child.LogCtx(ctx, "message", "test")
+	m = decode(t, &b)
+	if m["a"] != "1" || m["b"] != "2" || m["d"] != "4" {
+		t.Fatalf("output == %#v, want a, b and d", m)
+	}
+}
+
+func Test_Logger_Log_Error(t *testing.T) {
+	testCases := []struct {
+		name          string
+		level         string
+		expectedStack bool
+	}{
+		{
+			name:          "case 0: errors are rendered without stack",
+			level:         LevelInfo,
+			expectedStack: false,
+		},
+		{
+			name:          "case 1: errors are rendered with stack on debug",
+			level:         LevelDebug,
+			expectedStack: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var b bytes.Buffer
+			l := newTestLogger(t, &b, FormatJSON, tc.level)
+
+			err := microerror.Mask(microerror.Maskf(invalidConfigError, "service must not be empty"))
+
+			_ = l.Log("level", "error", "message", "test", "error", err)
+			m := decode(t, &b)
+
+			if m["error"] != "service must not be empty: invalid config" {
+				t.Fatalf("error == %q, want %q", m["error"], "service must not be empty: invalid config")
+			}
+
+			_, ok := m[KeyStack]
+			if ok != tc.expectedStack {
+				t.Fatalf("stack == %q, want present == %t", m[KeyStack], tc.expectedStack)
+			}
+		})
+	}
+}
+
+func newTestLogger(t *testing.T, b *bytes.Buffer, format, level string) *Logger {
+	c := DefaultConfig()
+
+	c.IOWriter = b
+
+	c.Format = format
+	c.Level = level
+
+	l, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+// decode returns the single JSON message written to b and resets b.
+func decode(t *testing.T, b *bytes.Buffer) map[string]interface{} {
+	var m map[string]interface{}
+	err := json.Unmarshal(b.Bytes(), &m)
+	if err != nil {
+		t.Fatalf("output == %q, want single JSON message: %s", b.String(), err)
+	}
+	b.Reset()
+
+	return m
+}
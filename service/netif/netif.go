@@ -0,0 +1,188 @@
+// Package netif provides the network interfaces and addresses of a network
+// namespace, either the one of the process or another one identified by name,
+// path or PID.
+package netif
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/giantswarm/microerror"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+const (
+	// NetNSDir is the directory of named network namespaces as managed by
+	// ip-netns(8).
+	NetNSDir = "/var/run/netns"
+)
+
+const (
+	// flagSecondary is IFA_F_SECONDARY, marking all but the first address of
+	// a subnet on an interface.
+	flagSecondary = 0x01
+)
+
+// Source provides the network interfaces of a network namespace.
+type Source interface {
+	// Interfaces returns all interfaces. The returned error is asserted by
+	// IsNotFound when the network namespace does not exist.
+	Interfaces() ([]Interface, error)
+}
+
+// Interface is a network interface together with its addresses.
+type Interface struct {
+	Name  string
+	Flags net.Flags
+	Addrs []Addr
+}
+
+// Addr is a single address of a network interface.
+type Addr struct {
+	IPNet *net.IPNet
+	// Label is the label of the address, e.g. "br0:1" for aliases.
+	Label string
+	// Scope is the scope of the address, e.g. ScopeGlobal or ScopeLink.
+	Scope int
+	// Secondary is true for all but the first address of a subnet.
+	Secondary bool
+}
+
+// Config represents the configuration used to create a new netlink source.
+type Config struct {
+	// Settings.
+
+	// NetNS is the network namespace to inspect, either given by its name in
+	// NetNSDir or by its path. The network namespace of the process is used
+	// when NetNS and PID are empty.
+	NetNS string
+	// PID identifies the network namespace to inspect by the process running
+	// in it.
+	PID int
+}
+
+// DefaultConfig provides a default configuration to create a new netlink
+// source by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Settings.
+		NetNS: "",
+		PID:   0,
+	}
+}
+
+// New creates a new netlink source. Inspecting another network namespace
+// requires CAP_SYS_ADMIN.
+func New(config Config) (*Netlink, error) {
+	// Settings.
+	if config.NetNS != "" && config.PID != 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.NetNS and config.PID must not be used together")
+	}
+	if config.PID < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.PID must not be negative")
+	}
+
+	var path string
+	if config.NetNS != "" {
+		path = config.NetNS
+		if filepath.Base(path) == path {
+			path = filepath.Join(NetNSDir, path)
+		}
+	}
+	if config.PID != 0 {
+		path = filepath.Join("/proc", strconv.Itoa(config.PID), "ns", "net")
+	}
+
+	newNetlink := &Netlink{
+		// Settings.
+		path: path,
+	}
+
+	return newNetlink, nil
+}
+
+// Netlink is a source querying interfaces via netlink.
+type Netlink struct {
+	// Settings.
+	path string
+}
+
+// Interfaces returns all interfaces. The network namespace is opened for every
+// call, so that a namespace being recreated, e.g. when the VM restarts, is
+// picked up.
+func (n *Netlink) Interfaces() ([]Interface, error) {
+	h, err := n.handle()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer h.Delete()
+
+	links, err := h.LinkList()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var interfaces []Interface
+	for _, link := range links {
+		i, err := toInterface(h, link)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		interfaces = append(interfaces, i)
+	}
+
+	return interfaces, nil
+}
+
+func (n *Netlink) handle() (*netlink.Handle, error) {
+	if n.path == "" {
+		h, err := netlink.NewHandle()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		return h, nil
+	}
+
+	ns, err := netns.GetFromPath(n.path)
+	if os.IsNotExist(err) {
+		return nil, microerror.Maskf(notFoundError, "network namespace %s", n.path)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+	defer ns.Close()
+
+	h, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return h, nil
+}
+
+func toInterface(h *netlink.Handle, link netlink.Link) (Interface, error) {
+	addrs, err := h.AddrList(link, syscall.AF_UNSPEC)
+	if err != nil {
+		return Interface{}, microerror.Mask(err)
+	}
+
+	i := Interface{
+		Name:  link.Attrs().Name,
+		Flags: link.Attrs().Flags,
+	}
+	for _, a := range addrs {
+		i.Addrs = append(i.Addrs, Addr{
+			IPNet:     a.IPNet,
+			Label:     a.Label,
+			Scope:     a.Scope,
+			Secondary: a.Flags&flagSecondary != 0,
+		})
+	}
+
+	return i, nil
+}
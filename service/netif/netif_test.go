@@ -0,0 +1,134 @@
+package netif
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+)
+
+func Test_New(t *testing.T) {
+	testCases := []struct {
+		config       Config
+		expectedPath string
+		errorMatcher func(error) bool
+	}{
+		{
+			config:       Config{},
+			expectedPath: "",
+		},
+		{
+			config:       Config{NetNS: "vm0"},
+			expectedPath: "/var/run/netns/vm0",
+		},
+		{
+			config:       Config{NetNS: "/run/netns/vm0"},
+			expectedPath: "/run/netns/vm0",
+		},
+		{
+			config:       Config{PID: 42},
+			expectedPath: "/proc/42/ns/net",
+		},
+		{
+			config:       Config{NetNS: "vm0", PID: 42},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			config:       Config{PID: -1},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			n, err := New(tc.config)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && n.path != tc.expectedPath {
+				t.Fatalf("path == %q, want %q", n.path, tc.expectedPath)
+			}
+		})
+	}
+}
+
+func Test_Netlink_Interfaces(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config Config
+	}{
+		{
+			name:   "case 0: network namespace of the process",
+			config: Config{},
+		},
+		{
+			name:   "case 1: network namespace given by PID",
+			config: Config{PID: os.Getpid()},
+		},
+		{
+			name:   "case 2: network namespace given by path",
+			config: Config{NetNS: "/proc/self/ns/net"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			n, err := New(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			interfaces, err := n.Interfaces()
+			if os.IsPermission(microerror.Cause(err)) {
+				t.Skip("entering network namespaces requires CAP_SYS_ADMIN")
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			var lo *Interface
+			for i := range interfaces {
+				if interfaces[i].Name == "lo" {
+					lo = &interfaces[i]
+				}
+			}
+			if lo == nil || lo.Flags&net.FlagLoopback == 0 {
+				t.Fatalf("interfaces == %#v, want loopback", interfaces)
+			}
+
+			var found bool
+			for _, a := range lo.Addrs {
+				if a.IPNet.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("addresses == %v, want 127.0.0.1", lo.Addrs)
+			}
+		})
+	}
+}
+
+func Test_Netlink_Interfaces_MissingNetNS(t *testing.T) {
+	n, err := New(Config{NetNS: "k8s-endpoint-updater-missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = n.Interfaces()
+	if !IsNotFound(err) {
+		t.Fatalf("error == %#v, want not found", err)
+	}
+}
@@ -0,0 +1,56 @@
+package netif
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Scopes of addresses as defined by the kernel, see ip-address(8).
+const (
+	ScopeGlobal  = 0
+	ScopeSite    = 200
+	ScopeLink    = 253
+	ScopeHost    = 254
+	ScopeNowhere = 255
+)
+
+var scopeNames = map[int]string{
+	ScopeGlobal:  "global",
+	ScopeSite:    "site",
+	ScopeLink:    "link",
+	ScopeHost:    "host",
+	ScopeNowhere: "nowhere",
+}
+
+// ScopeName returns the name of the given scope as used by ip-address(8).
+// Scopes without name are returned as number.
+func ScopeName(scope int) string {
+	name, ok := scopeNames[scope]
+	if !ok {
+		return strconv.Itoa(scope)
+	}
+
+	return name
+}
+
+// ParseScope returns the scope of the given name as returned by ScopeName.
+func ParseScope(name string) (int, bool) {
+	for scope, n := range scopeNames {
+		if n == name {
+			return scope, true
+		}
+	}
+
+	return 0, false
+}
+
+// ScopeNames returns the sorted names of all known scopes.
+func ScopeNames() []string {
+	var names []string
+	for _, n := range scopeNames {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
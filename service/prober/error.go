@@ -0,0 +1,17 @@
+package prober
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var probeFailedError = microerror.New("probe failed")
+
+// IsProbeFailed asserts probeFailedError.
+func IsProbeFailed(err error) bool {
+	return microerror.Cause(err) == probeFailedError
+}
@@ -0,0 +1,26 @@
+package prober
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	prometheusNamespace = "k8s_endpoint_updater"
+	prometheusSubsystem = "prober"
+)
+
+var (
+	probeTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "probe_total",
+			Help:      "Number of VM probes partitioned by probe kind and result.",
+		},
+		[]string{"kind", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(probeTotal)
+}
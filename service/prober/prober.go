@@ -0,0 +1,294 @@
+// Package prober implements active probing of the VM so that the published
+// endpoint is only marked ready once the VM actually serves traffic.
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+)
+
+const (
+	// KindHTTPS probes the VM with an HTTPS GET request. Any status code in the
+	// 2xx and 3xx range counts as success. Like the kubelet the server
+	// certificate is not verified.
+	KindHTTPS = "https"
+	// KindTCP probes the VM by opening a TCP connection.
+	KindTCP = "tcp"
+)
+
+// Config represents the configuration used to create a new prober.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+
+	// FailureThreshold is the number of consecutive failed probes after which a
+	// ready VM is considered not ready anymore.
+	FailureThreshold int
+	// Kind is the kind of probe, either KindHTTPS or KindTCP.
+	Kind string
+	// Path is the path requested by HTTPS probes.
+	Path string
+	// Period is the interval in which probes are executed.
+	Period time.Duration
+	// Port is the port of the VM being probed.
+	Port int
+	// SuccessThreshold is the number of consecutive successful probes after
+	// which a not ready VM is considered ready.
+	SuccessThreshold int
+	// Timeout is the timeout of a single probe.
+	Timeout time.Duration
+}
+
+// DefaultConfig provides a default configuration to create a new prober by
+// best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger: nil,
+
+		// Settings.
+		FailureThreshold: 3,
+		Kind:             "",
+		Path:             "/healthz",
+		Period:           10 * time.Second,
+		Port:             0,
+		SuccessThreshold: 1,
+		Timeout:          5 * time.Second,
+	}
+}
+
+// New creates a new prober.
+func New(config Config) (*Prober, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.FailureThreshold <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.FailureThreshold must be greater than zero")
+	}
+	if config.Kind != KindHTTPS && config.Kind != KindTCP {
+		return nil, microerror.Maskf(invalidConfigError, "config.Kind must be one of %q or %q", KindHTTPS, KindTCP)
+	}
+	if config.Period <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Period must be greater than zero")
+	}
+	if config.Port <= 0 || config.Port > 65535 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Port must be between 1 and 65535")
+	}
+	if config.SuccessThreshold <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.SuccessThreshold must be greater than zero")
+	}
+	if config.Timeout <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Timeout must be greater than zero")
+	}
+
+	newProber := &Prober{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Internals.
+		changes: make(chan struct{}, 1),
+		httpClient: &http.Client{
+			Timeout: config.Timeout,
+			Transport: &http.Transport{
+				// #nosec G402 VM certificates are not issued for the VM IP.
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives: true,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		mutex: sync.Mutex{},
+
+		// Settings.
+		failureThreshold: config.FailureThreshold,
+		kind:             config.Kind,
+		path:             config.Path,
+		period:           config.Period,
+		port:             config.Port,
+		successThreshold: config.SuccessThreshold,
+		timeout:          config.Timeout,
+	}
+
+	return newProber, nil
+}
+
+type Prober struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Internals.
+	changes    chan struct{}
+	httpClient *http.Client
+	mutex      sync.Mutex
+	failures   int
+	ready      bool
+	successes  int
+	target     net.IP
+
+	// Settings.
+	failureThreshold int
+	kind             string
+	path             string
+	period           time.Duration
+	port             int
+	successThreshold int
+	timeout          time.Duration
+}
+
+// Boot probes the current target periodically until the given context is
+// done.
+func (p *Prober) Boot(ctx context.Context) {
+	ticker := time.NewTicker(p.period)
+	defer ticker.Stop()
+
+	for {
+		p.probeTarget(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Changes returns a channel receiving a value whenever the readiness of the
+// target changed.
+func (p *Prober) Changes() <-chan struct{} {
+	return p.changes
+}
+
+// Probe executes a single probe against the given IP.
+func (p *Prober) Probe(ctx context.Context, ip net.IP) error {
+	address := net.JoinHostPort(ip.String(), strconv.Itoa(p.port))
+
+	switch p.kind {
+	case KindHTTPS:
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://%s%s", address, p.path), nil)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		res, err := p.httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return microerror.Maskf(probeFailedError, "%s", err)
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+			return microerror.Maskf(probeFailedError, "GET %s returned status code %d", req.URL, res.StatusCode)
+		}
+	case KindTCP:
+		d := net.Dialer{Timeout: p.timeout}
+
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return microerror.Maskf(probeFailedError, "%s", err)
+		}
+		_ = conn.Close()
+	}
+
+	return nil
+}
+
+// Ready returns whether the current target passed the configured number of
+// consecutive probes.
+func (p *Prober) Ready() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.ready
+}
+
+// SetTarget sets the IP being probed. Changing the target resets the
+// readiness, so that a new VM has to pass the probes first.
+func (p *Prober) SetTarget(ip net.IP) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if ip.Equal(p.target) {
+		return
+	}
+
+	p.target = ip
+	p.failures = 0
+	p.successes = 0
+	p.setReady(false)
+}
+
+func (p *Prober) probeTarget(ctx context.Context) {
+	p.mutex.Lock()
+	target := p.target
+	p.mutex.Unlock()
+
+	if target == nil {
+		return
+	}
+
+	err := p.Probe(ctx, target)
+	if ctx.Err() != nil {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// The target might have changed while probing, in which case the result is
+	// meaningless.
+	if !target.Equal(p.target) {
+		return
+	}
+
+	if err != nil {
+		probeTotal.WithLabelValues(p.kind, "failure").Inc()
+		_ = p.logger.Log("level", "debug", "message", "failed probing VM", "ip", target.String(), "error", err)
+
+		p.failures++
+		p.successes = 0
+		if p.failures >= p.failureThreshold {
+			p.setReady(false)
+		}
+	} else {
+		probeTotal.WithLabelValues(p.kind, "success").Inc()
+
+		p.successes++
+		p.failures = 0
+		if p.successes >= p.successThreshold {
+			p.setReady(true)
+		}
+	}
+}
+
+// setReady must be called with the mutex held.
+func (p *Prober) setReady(ready bool) {
+	if p.ready == ready {
+		return
+	}
+
+	p.ready = ready
+	if ready {
+		_ = p.logger.Log("level", "info", "message", "VM became ready", "ip", p.target.String())
+	} else {
+		_ = p.logger.Log("level", "warning", "message", "VM became not ready", "ip", p.target.String())
+	}
+
+	select {
+	case p.changes <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,294 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+)
+
+func Test_Prober_Probe(t *testing.T) {
+	testCases := []struct {
+		name         string
+		kind         string
+		statusCode   int
+		closed       bool
+		errorMatcher func(error) bool
+	}{
+		{
+			name:         "case 0: HTTPS probe succeeds on 200",
+			kind:         KindHTTPS,
+			statusCode:   http.StatusOK,
+			errorMatcher: nil,
+		},
+		{
+			name:         "case 1: HTTPS probe succeeds on redirects",
+			kind:         KindHTTPS,
+			statusCode:   http.StatusFound,
+			errorMatcher: nil,
+		},
+		{
+			name:         "case 2: HTTPS probe fails on 500",
+			kind:         KindHTTPS,
+			statusCode:   http.StatusInternalServerError,
+			errorMatcher: IsProbeFailed,
+		},
+		{
+			name:         "case 3: TCP probe succeeds on open port",
+			kind:         KindTCP,
+			errorMatcher: nil,
+		},
+		{
+			name:         "case 4: TCP probe fails on closed port",
+			kind:         KindTCP,
+			closed:       true,
+			errorMatcher: IsProbeFailed,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/healthz" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				if tc.statusCode == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			ip, port := hostPort(t, server)
+			if tc.closed {
+				server.Close()
+			}
+
+			p := newTestProber(t, tc.kind, port, 1, 1)
+
+			err := p.Probe(context.Background(), ip)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
+
+func Test_Prober_probeTarget(t *testing.T) {
+	testCases := []struct {
+		name             string
+		successThreshold int
+		failureThreshold int
+		results          []bool
+		expectedReady    []bool
+		expectedChanges  int
+	}{
+		{
+			name:             "case 0: single success makes ready",
+			successThreshold: 1,
+			failureThreshold: 3,
+			results:          []bool{true},
+			expectedReady:    []bool{true},
+			expectedChanges:  1,
+		},
+		{
+			name:             "case 1: ready after success threshold",
+			successThreshold: 2,
+			failureThreshold: 3,
+			results:          []bool{true, true},
+			expectedReady:    []bool{false, true},
+			expectedChanges:  1,
+		},
+		{
+			name:             "case 2: not ready after failure threshold",
+			successThreshold: 1,
+			failureThreshold: 3,
+			results:          []bool{true, false, false, false},
+			expectedReady:    []bool{true, true, true, false},
+			expectedChanges:  2,
+		},
+		{
+			name:             "case 3: flapping VM stays ready as failures are reset by successes",
+			successThreshold: 1,
+			failureThreshold: 2,
+			results:          []bool{true, false, true, false, true, false},
+			expectedReady:    []bool{true, true, true, true, true, true},
+			expectedChanges:  1,
+		},
+		{
+			name:             "case 4: flapping VM does not become ready as successes are reset by failures",
+			successThreshold: 2,
+			failureThreshold: 1,
+			results:          []bool{true, false, true, false},
+			expectedReady:    []bool{false, false, false, false},
+			expectedChanges:  0,
+		},
+		{
+			name:             "case 5: VM becomes ready again after recovering",
+			successThreshold: 2,
+			failureThreshold: 1,
+			results:          []bool{true, true, false, true, true},
+			expectedReady:    []bool{false, true, false, false, true},
+			expectedChanges:  3,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var healthy atomic.Value
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !healthy.Load().(bool) {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer server.Close()
+
+			ip, port := hostPort(t, server)
+
+			p := newTestProber(t, KindHTTPS, port, tc.successThreshold, tc.failureThreshold)
+			p.SetTarget(ip)
+
+			var changes int
+			for j, result := range tc.results {
+				healthy.Store(result)
+				p.probeTarget(context.Background())
+
+				select {
+				case <-p.Changes():
+					changes++
+				default:
+				}
+
+				if p.Ready() != tc.expectedReady[j] {
+					t.Fatalf("ready after probe %d == %t, want %t", j, p.Ready(), tc.expectedReady[j])
+				}
+			}
+
+			if changes != tc.expectedChanges {
+				t.Fatalf("changes == %d, want %d", changes, tc.expectedChanges)
+			}
+		})
+	}
+}
+
+func Test_Prober_SetTarget(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ip, port := hostPort(t, server)
+
+	p := newTestProber(t, KindHTTPS, port, 1, 3)
+
+	// Without target nothing is probed.
+	p.probeTarget(context.Background())
+	if p.Ready() {
+		t.Fatalf("ready == true, want false without target")
+	}
+
+	p.SetTarget(ip)
+	p.probeTarget(context.Background())
+	if !p.Ready() {
+		t.Fatalf("ready == false, want true")
+	}
+	<-p.Changes()
+
+	// Setting the same target keeps the readiness.
+	p.SetTarget(net.ParseIP(ip.String()))
+	if !p.Ready() {
+		t.Fatalf("ready == false, want true for unchanged target")
+	}
+
+	// A new target has to pass the probes first.
+	p.SetTarget(net.ParseIP("127.0.0.2"))
+	if p.Ready() {
+		t.Fatalf("ready == true, want false for changed target")
+	}
+	select {
+	case <-p.Changes():
+	default:
+		t.Fatalf("changed readiness must be signalled")
+	}
+}
+
+func Test_Prober_Boot(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ip, port := hostPort(t, server)
+
+	p := newTestProber(t, KindHTTPS, port, 2, 1)
+	p.period = 10 * time.Millisecond
+	p.SetTarget(ip)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.Boot(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-p.Changes():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("readiness change not signalled within 5 seconds")
+	}
+	if !p.Ready() {
+		t.Fatalf("ready == false, want true")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Boot did not return within 5 seconds")
+	}
+}
+
+func newTestProber(t *testing.T, kind string, port int, successThreshold, failureThreshold int) *Prober {
+	t.Helper()
+
+	c := DefaultConfig()
+	c.Logger = microloggertest.New()
+
+	c.FailureThreshold = failureThreshold
+	c.Kind = kind
+	c.Port = port
+	c.SuccessThreshold = successThreshold
+	c.Timeout = time.Second
+
+	p, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func hostPort(t *testing.T, server *httptest.Server) (net.IP, int) {
+	t.Helper()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+
+	return addr.IP, addr.Port
+}
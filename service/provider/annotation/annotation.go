@@ -0,0 +1,89 @@
+// Package annotation implements a provider reading the VM IP from an
+// annotation of the KVM pod, e.g. written by a node-local agent or by the
+// update command running as sidecar.
+package annotation
+
+import (
+	"context"
+	"net"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	Kind = "annotation"
+)
+
+// Config represents the configuration used to create a new provider.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+
+	// Annotation is the key of the pod annotation holding the VM IP.
+	Annotation string
+}
+
+// DefaultConfig provides a default configuration to create a new provider
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger: nil,
+
+		// Settings.
+		Annotation: "",
+	}
+}
+
+// New creates a new provider.
+func New(config Config) (*Provider, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Annotation == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Annotation must not be empty")
+	}
+
+	newProvider := &Provider{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Settings.
+		annotation: config.Annotation,
+	}
+
+	return newProvider, nil
+}
+
+type Provider struct {
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Settings.
+	annotation string
+}
+
+func (p *Provider) LookupPod(ctx context.Context, pod *corev1.Pod) (net.IP, error) {
+	if ctx.Err() != nil {
+		return nil, microerror.Mask(ctx.Err())
+	}
+
+	value, ok := pod.Annotations[p.annotation]
+	if !ok {
+		return nil, microerror.Maskf(notFoundError, "annotation %q of pod %s/%s", p.annotation, pod.Namespace, pod.Name)
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, microerror.Maskf(invalidAnnotationError, "annotation %q of pod %s/%s must be an IP but is %q", p.annotation, pod.Namespace, pod.Name, value)
+	}
+
+	return ip, nil
+}
@@ -0,0 +1,24 @@
+package annotation
+
+import "github.com/giantswarm/microerror"
+
+var invalidAnnotationError = microerror.New("invalid annotation")
+
+// IsInvalidAnnotation asserts invalidAnnotationError.
+func IsInvalidAnnotation(err error) bool {
+	return microerror.Cause(err) == invalidAnnotationError
+}
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var notFoundError = microerror.New("not found")
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
@@ -1,27 +1,56 @@
 package bridge
 
 import (
-	"errors"
+	"context"
 	"net"
+	"time"
 
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/ipmath"
+	"github.com/giantswarm/k8s-endpoint-updater/service/netif"
 )
 
 const (
 	Kind = "bridge"
 )
 
+const (
+	// DefaultWaitTimeout is the default time Wait waits for the bridge.
+	DefaultWaitTimeout = 5 * time.Minute
+)
+
 // Config represents the configuration used to create a new provider.
 type Config struct {
 	// Dependencies.
 	Logger micrologger.Logger
+	// Source provides the bridge, either from the network namespace of the
+	// process or from another one.
+	Source netif.Source
 
 	// Settings.
 
+	// AddressType restricts the bridge addresses to primary or secondary ones.
+	// It is one of AddressTypeAny, AddressTypePrimary or AddressTypeSecondary.
+	AddressType string
 	// BridgeName is the bridge name of the underlying host used to lookup the endpoint
 	// IP.
 	BridgeName string
+	// Label restricts the bridge addresses to the ones with the given label.
+	// Addresses with any label match when empty.
+	Label string
+	// Networks restricts the bridge addresses to the ones within any of the
+	// given networks. Addresses within any network match when empty.
+	Networks []*net.IPNet
+	// Scope restricts the bridge addresses to the ones with the given scope. It
+	// is one of Scopes.
+	Scope string
+	// WaitInterval is the interval in which the bridge is checked by Wait.
+	WaitInterval time.Duration
+	// WaitTimeout is the time Wait waits for the bridge before failing. Zero
+	// disables waiting.
+	WaitTimeout time.Duration
 }
 
 // DefaultConfig provides a default configuration to create a new provider
@@ -30,9 +59,16 @@ func DefaultConfig() Config {
 	return Config{
 		// Dependencies.
 		Logger: nil,
+		Source: nil,
 
 		// Settings.
-		BridgeName: "",
+		AddressType:  AddressTypePrimary,
+		BridgeName:   "",
+		Label:        "",
+		Networks:     nil,
+		Scope:        netif.ScopeName(netif.ScopeGlobal),
+		WaitInterval: time.Second,
+		WaitTimeout:  DefaultWaitTimeout,
 	}
 }
 
@@ -42,18 +78,37 @@ func New(config Config) (*Provider, error) {
 	if config.Logger == nil {
 		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
 	}
+	if config.Source == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Source must not be empty")
+	}
 
 	// Settings.
 	if config.BridgeName == "" {
 		return nil, microerror.Maskf(invalidConfigError, "config.BridgeName must not be empty")
 	}
 
+	if config.WaitInterval <= 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.WaitInterval must be greater than zero")
+	}
+	if config.WaitTimeout < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.WaitTimeout must not be negative")
+	}
+
+	selector, err := newSelector(config.AddressType, config.Label, config.Networks, config.Scope)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
 	newProvider := &Provider{
 		// Dependencies.
 		logger: config.Logger,
+		source: config.Source,
 
 		// Settings.
-		bridgeName: config.BridgeName,
+		bridgeName:   config.BridgeName,
+		selector:     selector,
+		waitInterval: config.WaitInterval,
+		waitTimeout:  config.WaitTimeout,
 	}
 
 	return newProvider, nil
@@ -62,22 +117,21 @@ func New(config Config) (*Provider, error) {
 type Provider struct {
 	// Dependencies.
 	logger micrologger.Logger
+	source netif.Source
 
 	// Settings.
-	bridgeName string
+	bridgeName   string
+	selector     selector
+	waitInterval time.Duration
+	waitTimeout  time.Duration
 }
 
-func (p *Provider) Lookup() (net.IP, error) {
-	// We fetch the interface first because it holds all IP addresses associated
-	// with it.
-	netInterface, err := net.InterfaceByName(p.bridgeName)
-	if err != nil {
-		return nil, microerror.Mask(err)
+func (p *Provider) Lookup(ctx context.Context) (net.IP, error) {
+	if ctx.Err() != nil {
+		return nil, microerror.Mask(ctx.Err())
 	}
 
-	// The interface addresses have to be parsed to find the actual IPV4 we are
-	// interested in.
-	ip, err := ipv4FromInterface(netInterface)
+	bridgeNet, err := p.bridgeNet()
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
@@ -90,51 +144,54 @@ func (p *Provider) Lookup() (net.IP, error) {
 	//     - The IP address after the IP address of the Flannel bridge is the IP
 	//       address of the guest cluster VM.
 	//
-	next := incrIPV4(ip)
+	// The VM IP has to be a host address of the bridge's subnet. Anything else
+	// means the assumptions above do not hold and is rejected.
+	//
+	next, err := ipmath.AddInNetwork(bridgeNet, bridgeNet.IP, 1)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	_ = p.logger.Log("level", "debug", "message", "looked up VM IP from bridge", "provider", Kind, "bridge", p.bridgeName, "bridgeAddress", bridgeNet.String(), "ip", next.String())
 
 	return next, nil
 }
 
-func incrIPV4(ip net.IP) net.IP {
-	c := net.ParseIP(ip.String())
+// bridgeNet returns the selected IPV4 address of the bridge together with the
+// network it is part of. The returned error tells why the bridge is not
+// usable. It is asserted by IsInterfaceNotFound when the bridge or its network
+// namespace does not exist, by IsInterfaceDown when the bridge is down and by
+// IsNotFound or IsAmbiguous when the address selection fails.
+func (p *Provider) bridgeNet() (*net.IPNet, error) {
+	// We fetch the interface first because it holds all IP addresses associated
+	// with it.
+	interfaces, err := p.source.Interfaces()
+	if netif.IsNotFound(err) {
+		return nil, microerror.Maskf(interfaceNotFoundError, "%s", err)
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
 
-	for j := len(c) - 1; j >= 0; j-- {
-		c[j]++
-		if c[j] > 0 {
-			break
+	var netInterface *netif.Interface
+	for i := range interfaces {
+		if interfaces[i].Name == p.bridgeName {
+			netInterface = &interfaces[i]
 		}
 	}
 
-	return c
-}
+	if netInterface == nil {
+		return nil, microerror.Maskf(interfaceNotFoundError, "interface %s", p.bridgeName)
+	}
+	if netInterface.Flags&net.FlagUp == 0 {
+		return nil, microerror.Maskf(interfaceDownError, "interface %s", p.bridgeName)
+	}
 
-func ipv4FromInterface(netInterface *net.Interface) (net.IP, error) {
-	addrs, err := netInterface.Addrs()
+	// The interface addresses have to be parsed to find the actual IPV4 we are
+	// interested in.
+	bridgeNet, err := p.selector.Select(netInterface.Addrs)
 	if err != nil {
 		return nil, microerror.Mask(err)
 	}
-	for _, addr := range addrs {
-		var ip net.IP
-
-		switch v := addr.(type) {
-		case *net.IPNet:
-			ip = v.IP
-		case *net.IPAddr:
-			ip = v.IP
-		}
-
-		if ip == nil {
-			continue
-		}
-
-		ipv4 := ip.To4()
-		if ipv4 == nil {
-			// Not an ipv4 address.
-			continue
-		}
-
-		return ipv4, nil
-	}
 
-	return nil, errors.New("IPV4 not found")
+	return bridgeNet, nil
 }
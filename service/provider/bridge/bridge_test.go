@@ -0,0 +1,274 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/ipmath"
+	"github.com/giantswarm/k8s-endpoint-updater/service/netif"
+)
+
+type fakeSource map[string]netif.Interface
+
+func (f fakeSource) Interfaces() ([]netif.Interface, error) {
+	var interfaces []netif.Interface
+	for _, i := range f {
+		interfaces = append(interfaces, i)
+	}
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Name < interfaces[j].Name })
+
+	return interfaces, nil
+}
+
+func mustParseAddr(t *testing.T, s string) netif.Addr {
+	t.Helper()
+
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipNet.IP = ip
+
+	return netif.Addr{IPNet: ipNet}
+}
+
+func Test_Provider_Lookup(t *testing.T) {
+	testCases := []struct {
+		name         string
+		addrs        []string
+		expectedIP   net.IP
+		errorMatcher func(error) bool
+	}{
+		{
+			name:       "case 0: single IPv4 address",
+			addrs:      []string{"10.1.2.1/24"},
+			expectedIP: net.ParseIP("10.1.2.2"),
+		},
+		{
+			name:       "case 1: IPv6 addresses are skipped",
+			addrs:      []string{"fe80::1/64", "10.1.2.1/24"},
+			expectedIP: net.ParseIP("10.1.2.2"),
+		},
+		{
+			name:         "case 2: multiple IPv4 addresses are ambiguous",
+			addrs:        []string{"10.1.2.1/24", "10.1.3.1/24"},
+			errorMatcher: IsAmbiguous,
+		},
+		{
+			name:       "case 3: increment across octet boundary",
+			addrs:      []string{"10.0.0.255/16"},
+			expectedIP: net.ParseIP("10.0.1.0"),
+		},
+		{
+			name:         "case 4: next address is the broadcast address",
+			addrs:        []string{"10.1.2.254/24"},
+			errorMatcher: ipmath.IsOutOfRange,
+		},
+		{
+			name:         "case 5: next address is outside of the subnet",
+			addrs:        []string{"10.1.2.1/32"},
+			errorMatcher: ipmath.IsOutOfRange,
+		},
+		{
+			name:         "case 6: IPv6 addresses only",
+			addrs:        []string{"fe80::1/64"},
+			errorMatcher: IsNotFound,
+		},
+		{
+			name:         "case 7: no addresses",
+			addrs:        []string{},
+			errorMatcher: IsNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var addrs []netif.Addr
+			for _, a := range tc.addrs {
+				addrs = append(addrs, mustParseAddr(t, a))
+			}
+
+			c := DefaultConfig()
+			c.Logger = microloggertest.New()
+			c.Source = fakeSource{"br0": {Name: "br0", Flags: net.FlagUp, Addrs: addrs}}
+			c.BridgeName = "br0"
+
+			p, err := New(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ip, err := p.Lookup(context.Background())
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !ip.Equal(tc.expectedIP) {
+				t.Fatalf("ip == %s, want %s", ip, tc.expectedIP)
+			}
+		})
+	}
+}
+
+func Test_Provider_Lookup_MissingInterface(t *testing.T) {
+	c := DefaultConfig()
+	c.Logger = microloggertest.New()
+	c.Source = fakeSource{}
+	c.BridgeName = "br0"
+
+	p, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Lookup(context.Background())
+	if !IsInterfaceNotFound(err) {
+		t.Fatalf("error == %#v, want interface not found", err)
+	}
+}
+
+func Test_Provider_Lookup_Selection(t *testing.T) {
+	addrs := []netif.Addr{
+		{IPNet: mustParseAddr(t, "127.0.0.1/8").IPNet, Label: "br0", Scope: netif.ScopeHost},
+		{IPNet: mustParseAddr(t, "10.1.2.1/24").IPNet, Label: "br0", Scope: netif.ScopeGlobal},
+		{IPNet: mustParseAddr(t, "10.1.2.9/24").IPNet, Label: "br0:vm", Scope: netif.ScopeGlobal, Secondary: true},
+		{IPNet: mustParseAddr(t, "10.1.3.1/24").IPNet, Label: "br0", Scope: netif.ScopeGlobal},
+		{IPNet: mustParseAddr(t, "169.254.0.1/16").IPNet, Label: "br0", Scope: netif.ScopeLink},
+	}
+
+	testCases := []struct {
+		name         string
+		addressType  string
+		label        string
+		networks     []string
+		scope        string
+		expectedIP   net.IP
+		errorMatcher func(error) bool
+	}{
+		{
+			name:         "case 0: defaults match multiple primary global addresses",
+			addressType:  AddressTypePrimary,
+			scope:        "global",
+			errorMatcher: IsAmbiguous,
+		},
+		{
+			name:        "case 1: network filter",
+			addressType: AddressTypePrimary,
+			networks:    []string{"10.1.3.0/24"},
+			scope:       "global",
+			expectedIP:  net.ParseIP("10.1.3.2"),
+		},
+		{
+			name:        "case 2: secondary address",
+			addressType: AddressTypeSecondary,
+			scope:       "global",
+			expectedIP:  net.ParseIP("10.1.2.10"),
+		},
+		{
+			name:        "case 3: label",
+			addressType: AddressTypeAny,
+			label:       "br0:vm",
+			scope:       ScopeAny,
+			expectedIP:  net.ParseIP("10.1.2.10"),
+		},
+		{
+			name:        "case 4: scope",
+			addressType: AddressTypeAny,
+			scope:       "link",
+			expectedIP:  net.ParseIP("169.254.0.2"),
+		},
+		{
+			name:         "case 5: no match",
+			addressType:  AddressTypePrimary,
+			networks:     []string{"192.168.0.0/16"},
+			scope:        "global",
+			errorMatcher: IsNotFound,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			c := DefaultConfig()
+			c.Logger = microloggertest.New()
+			c.Source = fakeSource{"br0": {Name: "br0", Flags: net.FlagUp, Addrs: addrs}}
+			c.AddressType = tc.addressType
+			c.BridgeName = "br0"
+			c.Label = tc.label
+			for _, n := range tc.networks {
+				c.Networks = append(c.Networks, mustParseAddr(t, n).IPNet)
+			}
+			c.Scope = tc.scope
+
+			p, err := New(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ip, err := p.Lookup(context.Background())
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !ip.Equal(tc.expectedIP) {
+				t.Fatalf("ip == %s, want %s", ip, tc.expectedIP)
+			}
+			// Ambiguous errors have to list the candidates for diagnosis.
+			if IsAmbiguous(err) && !strings.Contains(err.Error(), "10.1.3.1/24") {
+				t.Fatalf("error == %q, want candidates", err.Error())
+			}
+		})
+	}
+}
+
+func Test_New_Selection(t *testing.T) {
+	testCases := []struct {
+		addressType string
+		scope       string
+	}{
+		{addressType: "tertiary", scope: "global"},
+		{addressType: AddressTypeAny, scope: "universe"},
+		{addressType: "", scope: ""},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			c := DefaultConfig()
+			c.Logger = microloggertest.New()
+			c.Source = fakeSource{}
+			c.AddressType = tc.addressType
+			c.BridgeName = "br0"
+			c.Scope = tc.scope
+
+			_, err := New(c)
+			if !IsInvalidConfig(err) {
+				t.Fatalf("error == %#v, want invalid config", err)
+			}
+		})
+	}
+}
@@ -8,3 +8,38 @@ var invalidConfigError = microerror.New("invalid config")
 func IsInvalidConfig(err error) bool {
 	return microerror.Cause(err) == invalidConfigError
 }
+
+var notFoundError = microerror.New("not found")
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
+
+var ambiguousError = microerror.New("ambiguous")
+
+// IsAmbiguous asserts ambiguousError.
+func IsAmbiguous(err error) bool {
+	return microerror.Cause(err) == ambiguousError
+}
+
+var interfaceDownError = microerror.New("interface down")
+
+// IsInterfaceDown asserts interfaceDownError.
+func IsInterfaceDown(err error) bool {
+	return microerror.Cause(err) == interfaceDownError
+}
+
+var interfaceNotFoundError = microerror.New("interface not found")
+
+// IsInterfaceNotFound asserts interfaceNotFoundError.
+func IsInterfaceNotFound(err error) bool {
+	return microerror.Cause(err) == interfaceNotFoundError
+}
+
+var waitTimeoutError = microerror.New("wait timeout")
+
+// IsWaitTimeout asserts waitTimeoutError.
+func IsWaitTimeout(err error) bool {
+	return microerror.Cause(err) == waitTimeoutError
+}
@@ -0,0 +1,176 @@
+package bridge
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/ipmath"
+	"github.com/giantswarm/k8s-endpoint-updater/service/netif"
+)
+
+// Address types the bridge addresses can be restricted to.
+const (
+	AddressTypeAny       = "any"
+	AddressTypePrimary   = "primary"
+	AddressTypeSecondary = "secondary"
+)
+
+const (
+	// ScopeAny matches addresses of all scopes.
+	ScopeAny = "any"
+)
+
+// AddressTypes returns all supported address types.
+func AddressTypes() []string {
+	return []string{AddressTypeAny, AddressTypePrimary, AddressTypeSecondary}
+}
+
+// Scopes returns all supported scopes.
+func Scopes() []string {
+	return append([]string{ScopeAny}, netif.ScopeNames()...)
+}
+
+// selector selects the single IPV4 address of the bridge the VM IP is derived
+// from.
+type selector struct {
+	addressType string
+	label       string
+	networks    []*net.IPNet
+	scope       string
+}
+
+// newSelector validates the given criteria and returns a selector for them.
+func newSelector(addressType, label string, networks []*net.IPNet, scope string) (selector, error) {
+	switch addressType {
+	case AddressTypeAny, AddressTypePrimary, AddressTypeSecondary:
+	default:
+		return selector{}, microerror.Maskf(invalidConfigError, "config.AddressType must be one of %s", strings.Join(AddressTypes(), ", "))
+	}
+	if _, ok := netif.ParseScope(scope); !ok && scope != ScopeAny {
+		return selector{}, microerror.Maskf(invalidConfigError, "config.Scope must be one of %s", strings.Join(Scopes(), ", "))
+	}
+	for _, n := range networks {
+		if n == nil {
+			return selector{}, microerror.Maskf(invalidConfigError, "config.Networks must not contain empty networks")
+		}
+	}
+
+	s := selector{
+		addressType: addressType,
+		label:       label,
+		networks:    networks,
+		scope:       scope,
+	}
+
+	return s, nil
+}
+
+// Select returns the single IPV4 address matching all criteria together with
+// the network it is part of. When no address matches the returned error is
+// asserted by IsNotFound, when multiple addresses match by IsAmbiguous. Both
+// errors list the IPV4 addresses of the bridge for diagnosis.
+func (s selector) Select(addrs []netif.Addr) (*net.IPNet, error) {
+	var all []netif.Addr
+	var candidates []netif.Addr
+	for _, addr := range addrs {
+		if addr.IPNet == nil || addr.IPNet.IP.To4() == nil {
+			// Not an ipv4 address.
+			continue
+		}
+
+		all = append(all, addr)
+		if s.matches(addr) {
+			candidates = append(candidates, addr)
+		}
+	}
+
+	if len(all) == 0 {
+		return nil, microerror.Maskf(notFoundError, "IPV4 address")
+	}
+	if len(candidates) == 0 {
+		return nil, microerror.Maskf(notFoundError, "IPV4 address matching %s among %s", s, describe(all))
+	}
+	if len(candidates) > 1 {
+		return nil, microerror.Maskf(ambiguousError, "IPV4 addresses matching %s: %s", s, describe(candidates))
+	}
+
+	c := candidates[0]
+
+	return &net.IPNet{IP: c.IPNet.IP.To4(), Mask: c.IPNet.Mask}, nil
+}
+
+func (s selector) String() string {
+	criteria := []string{
+		"type " + s.addressType,
+		"scope " + s.scope,
+	}
+	if s.label != "" {
+		criteria = append(criteria, "label "+s.label)
+	}
+	if len(s.networks) != 0 {
+		var networks []string
+		for _, n := range s.networks {
+			networks = append(networks, n.String())
+		}
+		criteria = append(criteria, "networks "+strings.Join(networks, ", "))
+	}
+
+	return "(" + strings.Join(criteria, "; ") + ")"
+}
+
+func (s selector) matches(addr netif.Addr) bool {
+	switch s.addressType {
+	case AddressTypePrimary:
+		if addr.Secondary {
+			return false
+		}
+	case AddressTypeSecondary:
+		if !addr.Secondary {
+			return false
+		}
+	}
+
+	if s.label != "" && addr.Label != s.label {
+		return false
+	}
+
+	if s.scope != ScopeAny && netif.ScopeName(addr.Scope) != s.scope {
+		return false
+	}
+
+	if len(s.networks) == 0 {
+		return true
+	}
+	for _, n := range s.networks {
+		if ipmath.Contains(n, addr.IPNet.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describe lists the given addresses together with the attributes they are
+// selected by.
+func describe(addrs []netif.Addr) string {
+	var descriptions []string
+	for _, a := range addrs {
+		addressType := AddressTypePrimary
+		if a.Secondary {
+			addressType = AddressTypeSecondary
+		}
+
+		d := fmt.Sprintf("%s (type %s; scope %s", a.IPNet, addressType, netif.ScopeName(a.Scope))
+		if a.Label != "" {
+			d += "; label " + a.Label
+		}
+		d += ")"
+
+		descriptions = append(descriptions, d)
+	}
+
+	return strings.Join(descriptions, ", ")
+}
@@ -0,0 +1,120 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+const (
+	// waitLogInterval is the interval in which the progress of Wait is logged
+	// while the state of the bridge does not change.
+	waitLogInterval = 30 * time.Second
+)
+
+// Bridge states reported while waiting.
+const (
+	stateInterfaceDown    = "interface down"
+	stateInterfaceMissing = "interface missing"
+	stateNoAddress        = "no IPV4 address yet"
+	stateUnknown          = "unknown"
+)
+
+// Wait blocks until the bridge exists, is up and has an IPV4 address matching
+// the selection criteria. The state of the bridge is logged whenever it
+// changes and periodically otherwise. After the configured wait timeout Wait
+// fails with an error asserted by IsWaitTimeout listing the existing
+// interfaces for diagnosis. Ambiguous addresses fail right away as they are a
+// configuration problem. Wait returns immediately when the wait timeout is
+// zero.
+func (p *Provider) Wait(ctx context.Context) error {
+	if p.waitTimeout == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	deadline := time.NewTimer(p.waitTimeout)
+	defer deadline.Stop()
+
+	var lastLog time.Time
+	var lastState string
+	for {
+		_, err := p.bridgeNet()
+		if err == nil {
+			if lastState != "" {
+				_ = p.logger.Log("level", "info", "message", "bridge is ready", "provider", Kind, "bridge", p.bridgeName, "waited", time.Since(start).Round(time.Second).String())
+			}
+
+			return nil
+		} else if IsAmbiguous(err) {
+			return microerror.Mask(err)
+		}
+
+		state := bridgeState(err)
+		if state != lastState || time.Since(lastLog) >= waitLogInterval {
+			_ = p.logger.Log("level", "info", "message", "waiting for bridge", "provider", Kind, "bridge", p.bridgeName, "state", state, "waited", time.Since(start).Round(time.Second).String(), "error", err)
+			lastLog = time.Now()
+			lastState = state
+		}
+
+		select {
+		case <-ctx.Done():
+			return microerror.Mask(ctx.Err())
+		case <-deadline.C:
+			return microerror.Maskf(waitTimeoutError, "bridge %s not ready after %s (%s: %s), existing interfaces: %s", p.bridgeName, p.waitTimeout, state, err, p.describeInterfaces())
+		case <-time.After(p.waitInterval):
+		}
+	}
+}
+
+func (p *Provider) describeInterfaces() string {
+	interfaces, err := p.source.Interfaces()
+	if err != nil {
+		return fmt.Sprintf("unknown (%s)", err)
+	}
+	if len(interfaces) == 0 {
+		return "none"
+	}
+
+	var descriptions []string
+	for _, i := range interfaces {
+		state := "down"
+		if i.Flags&net.FlagUp != 0 {
+			state = "up"
+		}
+
+		var addrs []string
+		for _, a := range i.Addrs {
+			if a.IPNet != nil {
+				addrs = append(addrs, a.IPNet.String())
+			}
+		}
+
+		d := fmt.Sprintf("%s (%s", i.Name, state)
+		if len(addrs) != 0 {
+			d += "; " + strings.Join(addrs, ", ")
+		}
+		d += ")"
+
+		descriptions = append(descriptions, d)
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+func bridgeState(err error) string {
+	switch {
+	case IsInterfaceNotFound(err):
+		return stateInterfaceMissing
+	case IsInterfaceDown(err):
+		return stateInterfaceDown
+	case IsNotFound(err):
+		return stateNoAddress
+	default:
+		return stateUnknown
+	}
+}
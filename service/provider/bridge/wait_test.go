@@ -0,0 +1,150 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/netif"
+)
+
+// steppingSource returns the interfaces of the next step on every call and
+// stays at the last step.
+type steppingSource struct {
+	calls int
+	steps []fakeSource
+}
+
+func (s *steppingSource) Interfaces() ([]netif.Interface, error) {
+	i := s.calls
+	if i >= len(s.steps) {
+		i = len(s.steps) - 1
+	}
+	s.calls++
+
+	return s.steps[i].Interfaces()
+}
+
+func newWaitProvider(t *testing.T, source netif.Source, timeout time.Duration) *Provider {
+	t.Helper()
+
+	c := DefaultConfig()
+	c.Logger = microloggertest.New()
+	c.Source = source
+	c.BridgeName = "br0"
+	c.WaitInterval = time.Millisecond
+	c.WaitTimeout = timeout
+
+	p, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func Test_Provider_Wait(t *testing.T) {
+	eth0 := netif.Interface{Name: "eth0", Flags: net.FlagUp, Addrs: []netif.Addr{mustParseAddr(t, "192.168.0.5/24")}}
+
+	source := &steppingSource{
+		steps: []fakeSource{
+			{"eth0": eth0},
+			{"eth0": eth0, "br0": {Name: "br0"}},
+			{"eth0": eth0, "br0": {Name: "br0", Flags: net.FlagUp}},
+			{"eth0": eth0, "br0": {Name: "br0", Flags: net.FlagUp, Addrs: []netif.Addr{mustParseAddr(t, "10.1.2.1/24")}}},
+		},
+	}
+
+	p := newWaitProvider(t, source, time.Minute)
+
+	err := p.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.calls != len(source.steps) {
+		t.Fatalf("calls == %d, want %d", source.calls, len(source.steps))
+	}
+}
+
+func Test_Provider_Wait_Timeout(t *testing.T) {
+	testCases := []struct {
+		name          string
+		source        fakeSource
+		expectedState string
+	}{
+		{
+			name:          "case 0: interface missing",
+			source:        fakeSource{},
+			expectedState: stateInterfaceMissing,
+		},
+		{
+			name:          "case 1: interface down",
+			source:        fakeSource{"br0": {Name: "br0"}},
+			expectedState: stateInterfaceDown,
+		},
+		{
+			name:          "case 2: no IPV4 address",
+			source:        fakeSource{"br0": {Name: "br0", Flags: net.FlagUp, Addrs: []netif.Addr{mustParseAddr(t, "fe80::1/64")}}},
+			expectedState: stateNoAddress,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.source["eth0"] = netif.Interface{Name: "eth0", Flags: net.FlagUp, Addrs: []netif.Addr{mustParseAddr(t, "192.168.0.5/24")}}
+
+			p := newWaitProvider(t, tc.source, 20*time.Millisecond)
+
+			err := p.Wait(context.Background())
+			if !IsWaitTimeout(err) {
+				t.Fatalf("error == %#v, want wait timeout", err)
+			}
+
+			// The error has to tell the state of the bridge and list the existing
+			// interfaces for diagnosis.
+			for _, s := range []string{tc.expectedState, "eth0 (up; 192.168.0.5/24)"} {
+				if !strings.Contains(err.Error(), s) {
+					t.Fatalf("error == %q, want it to contain %q", err.Error(), s)
+				}
+			}
+		})
+	}
+}
+
+func Test_Provider_Wait_Ambiguous(t *testing.T) {
+	source := fakeSource{
+		"br0": {Name: "br0", Flags: net.FlagUp, Addrs: []netif.Addr{mustParseAddr(t, "10.1.2.1/24"), mustParseAddr(t, "10.1.3.1/24")}},
+	}
+
+	p := newWaitProvider(t, source, time.Minute)
+
+	err := p.Wait(context.Background())
+	if !IsAmbiguous(err) {
+		t.Fatalf("error == %#v, want ambiguous", err)
+	}
+}
+
+func Test_Provider_Wait_Cancelled(t *testing.T) {
+	p := newWaitProvider(t, fakeSource{}, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := p.Wait(ctx)
+	if err == nil || IsWaitTimeout(err) {
+		t.Fatalf("error == %#v, want cancelled", err)
+	}
+}
+
+func Test_Provider_Wait_Disabled(t *testing.T) {
+	p := newWaitProvider(t, fakeSource{}, 0)
+
+	err := p.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
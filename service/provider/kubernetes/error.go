@@ -0,0 +1,24 @@
+package kubernetes
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var invalidIPError = microerror.New("invalid IP")
+
+// IsInvalidIP asserts invalidIPError.
+func IsInvalidIP(err error) bool {
+	return microerror.Cause(err) == invalidIPError
+}
+
+var notFoundError = microerror.New("not found")
+
+// IsNotFound asserts notFoundError.
+func IsNotFound(err error) bool {
+	return microerror.Cause(err) == notFoundError
+}
@@ -0,0 +1,286 @@
+// Package kubernetes implements a provider reading the VM IP from an object
+// in Kubernetes, e.g. the status of a pod or of a KubeVirt
+// VirtualMachineInstance, so that addresses already known to Kubernetes can be
+// published without access to the host network.
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
+)
+
+const (
+	Kind = "kubernetes"
+)
+
+// IP families the looked up IPs can be restricted to.
+const (
+	FamilyAny  = "any"
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// Sources are presets of the resource and JSONPath the IP is read from.
+const (
+	// SourcePod reads the IPs of a pod.
+	SourcePod = "pod"
+	// SourceVirtualMachineInstance reads the IPs of the interfaces of a
+	// KubeVirt VirtualMachineInstance.
+	SourceVirtualMachineInstance = "vmi"
+)
+
+type source struct {
+	jsonPath string
+	resource schema.GroupVersionResource
+}
+
+var sources = map[string]source{
+	SourcePod: {
+		jsonPath: "{.status.podIPs[*].ip} {.status.podIP}",
+		resource: schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+	},
+	SourceVirtualMachineInstance: {
+		jsonPath: "{.status.interfaces[*].ipAddress}",
+		resource: schema.GroupVersionResource{Group: "kubevirt.io", Version: "v1", Resource: "virtualmachineinstances"},
+	},
+}
+
+// Source returns the resource and JSONPath of the given source preset.
+func Source(name string) (schema.GroupVersionResource, string, bool) {
+	s, ok := sources[name]
+	return s.resource, s.jsonPath, ok
+}
+
+// Families returns all supported IP families.
+func Families() []string {
+	return []string{FamilyAny, FamilyIPv4, FamilyIPv6}
+}
+
+// Sources returns all source presets.
+func Sources() []string {
+	return []string{SourcePod, SourceVirtualMachineInstance}
+}
+
+// Config represents the configuration used to create a new provider.
+type Config struct {
+	// Dependencies.
+	DynamicClient dynamic.Interface
+	Logger        micrologger.Logger
+
+	// Settings.
+
+	// Annotation is the key of the annotation of the object holding the IP. It
+	// must not be used together with JSONPath.
+	Annotation string
+	// Family restricts the IPs to the given family. It is one of Families.
+	Family string
+	// JSONPath is the template selecting the IPs of the object, e.g.
+	// "{.status.podIPs[*].ip}". The first IP found is used. It must not be
+	// used together with Annotation.
+	JSONPath string
+	// Name is the name of the object.
+	Name string
+	// Namespace is the namespace of the object. It is empty for cluster scoped
+	// objects.
+	Namespace string
+	// Resource is the resource of the object.
+	Resource schema.GroupVersionResource
+}
+
+// DefaultConfig provides a default configuration to create a new provider
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		DynamicClient: nil,
+		Logger:        nil,
+
+		// Settings.
+		Annotation: "",
+		Family:     FamilyAny,
+		JSONPath:   "",
+		Name:       "",
+		Namespace:  "",
+		Resource:   schema.GroupVersionResource{},
+	}
+}
+
+// New creates a new provider.
+func New(config Config) (*Provider, error) {
+	// Dependencies.
+	if config.DynamicClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.DynamicClient must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Annotation == "" && config.JSONPath == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Annotation or config.JSONPath must not be empty")
+	}
+	if config.Annotation != "" && config.JSONPath != "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Annotation and config.JSONPath must not be used together")
+	}
+	switch config.Family {
+	case FamilyAny, FamilyIPv4, FamilyIPv6:
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "config.Family must be one of %s", strings.Join(Families(), ", "))
+	}
+	if config.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Name must not be empty")
+	}
+	if config.Resource.Resource == "" || config.Resource.Version == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Resource must not be empty")
+	}
+	var j *jsonpath.JSONPath
+	if config.JSONPath != "" {
+		j = jsonpath.New("ip").AllowMissingKeys(true)
+
+		err := j.Parse(config.JSONPath)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.JSONPath %s", err)
+		}
+	}
+
+	newProvider := &Provider{
+		// Dependencies.
+		dynamicClient: config.DynamicClient,
+		logger:        config.Logger,
+
+		// Internals.
+		jsonPath: j,
+
+		// Settings.
+		annotation: config.Annotation,
+		family:     config.Family,
+		name:       config.Name,
+		namespace:  config.Namespace,
+		resource:   config.Resource,
+	}
+
+	return newProvider, nil
+}
+
+type Provider struct {
+	// Dependencies.
+	dynamicClient dynamic.Interface
+	logger        micrologger.Logger
+
+	// Internals.
+	jsonPath *jsonpath.JSONPath
+
+	// Settings.
+	annotation string
+	family     string
+	name       string
+	namespace  string
+	resource   schema.GroupVersionResource
+}
+
+// Lookup fetches the configured object and returns the first IP of the
+// configured family it holds. Objects not holding any IP yet, e.g. pods not
+// scheduled yet, result in an error asserted by IsNotFound.
+func (p *Provider) Lookup(ctx context.Context) (net.IP, error) {
+	var obj *unstructured.Unstructured
+	err := call.Do(ctx, func() error {
+		var err error
+		obj, err = p.dynamicClient.Resource(p.resource).Namespace(p.namespace).Get(p.name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	values, err := p.values(obj)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var ip net.IP
+	for _, v := range values {
+		parsed := net.ParseIP(v)
+		if parsed == nil {
+			return nil, microerror.Maskf(invalidIPError, "%s must hold IPs but holds %q", p.object(), v)
+		}
+
+		if p.matches(parsed) {
+			ip = parsed
+			break
+		}
+	}
+	if ip == nil {
+		return nil, microerror.Maskf(notFoundError, "%s IP of %s", p.family, p.object())
+	}
+
+	_ = p.logger.Log("level", "debug", "message", "looked up VM IP from object", "provider", Kind, "object", p.object(), "ip", ip.String())
+
+	return ip, nil
+}
+
+func (p *Provider) matches(ip net.IP) bool {
+	switch p.family {
+	case FamilyIPv4:
+		return ip.To4() != nil
+	case FamilyIPv6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// object describes the configured object for logs and errors.
+func (p *Provider) object() string {
+	name := p.name
+	if p.namespace != "" {
+		name = p.namespace + "/" + name
+	}
+
+	return fmt.Sprintf("%s %s", p.resource.GroupResource(), name)
+}
+
+// values returns the non-empty values selected by the annotation or the
+// JSONPath.
+func (p *Provider) values(obj *unstructured.Unstructured) ([]string, error) {
+	if p.annotation != "" {
+		v := obj.GetAnnotations()[p.annotation]
+		if v == "" {
+			return nil, nil
+		}
+
+		return []string{v}, nil
+	}
+
+	results, err := p.jsonPath.FindResults(obj.UnstructuredContent())
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var values []string
+	for _, r := range results {
+		for _, v := range r {
+			if !v.IsValid() || !v.CanInterface() {
+				continue
+			}
+
+			s := strings.TrimSpace(fmt.Sprint(v.Interface()))
+			if s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,210 @@
+package kubernetes
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newTestPod(annotations map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]interface{}{
+				"name":        "vm0",
+				"namespace":   "default",
+				"annotations": annotations,
+			},
+			"status": status,
+		},
+	}
+}
+
+func newTestVMI(interfaces ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "kubevirt.io/v1",
+			"kind":       "VirtualMachineInstance",
+			"metadata": map[string]interface{}{
+				"name":      "vm0",
+				"namespace": "default",
+			},
+			"status": map[string]interface{}{
+				"interfaces": interfaces,
+			},
+		},
+	}
+}
+
+func Test_Provider_Lookup(t *testing.T) {
+	testCases := []struct {
+		name         string
+		obj          *unstructured.Unstructured
+		source       string
+		annotation   string
+		jsonPath     string
+		family       string
+		expectedIP   net.IP
+		errorMatcher func(error) bool
+	}{
+		{
+			name:       "case 0: pod IP",
+			obj:        newTestPod(nil, map[string]interface{}{"podIP": "10.1.2.3"}),
+			source:     SourcePod,
+			family:     FamilyAny,
+			expectedIP: net.ParseIP("10.1.2.3"),
+		},
+		{
+			name: "case 1: pod IPs restricted to IPv6",
+			obj: newTestPod(nil, map[string]interface{}{
+				"podIP":  "10.1.2.3",
+				"podIPs": []interface{}{map[string]interface{}{"ip": "10.1.2.3"}, map[string]interface{}{"ip": "fd00::3"}},
+			}),
+			source:     SourcePod,
+			family:     FamilyIPv6,
+			expectedIP: net.ParseIP("fd00::3"),
+		},
+		{
+			name:         "case 2: pod without IP yet",
+			obj:          newTestPod(nil, map[string]interface{}{"phase": "Pending"}),
+			source:       SourcePod,
+			family:       FamilyAny,
+			errorMatcher: IsNotFound,
+		},
+		{
+			name: "case 3: VirtualMachineInstance interfaces",
+			obj: newTestVMI(
+				map[string]interface{}{"name": "default"},
+				map[string]interface{}{"name": "default", "ipAddress": "fd00::4"},
+				map[string]interface{}{"name": "secondary", "ipAddress": "10.1.2.4"},
+			),
+			source:     SourceVirtualMachineInstance,
+			family:     FamilyIPv4,
+			expectedIP: net.ParseIP("10.1.2.4"),
+		},
+		{
+			name:       "case 4: annotation",
+			obj:        newTestPod(map[string]interface{}{"endpoint.kvm.giantswarm.io/ip": "10.1.2.5"}, nil),
+			source:     SourcePod,
+			annotation: "endpoint.kvm.giantswarm.io/ip",
+			family:     FamilyAny,
+			expectedIP: net.ParseIP("10.1.2.5"),
+		},
+		{
+			name:         "case 5: missing annotation",
+			obj:          newTestPod(nil, nil),
+			source:       SourcePod,
+			annotation:   "endpoint.kvm.giantswarm.io/ip",
+			family:       FamilyAny,
+			errorMatcher: IsNotFound,
+		},
+		{
+			name:         "case 6: invalid IP",
+			obj:          newTestPod(map[string]interface{}{"endpoint.kvm.giantswarm.io/ip": "vm0"}, nil),
+			source:       SourcePod,
+			annotation:   "endpoint.kvm.giantswarm.io/ip",
+			family:       FamilyAny,
+			errorMatcher: IsInvalidIP,
+		},
+		{
+			name:       "case 7: custom JSONPath",
+			obj:        newTestPod(nil, map[string]interface{}{"hostIP": "10.0.0.1"}),
+			source:     SourcePod,
+			jsonPath:   "{.status.hostIP}",
+			family:     FamilyAny,
+			expectedIP: net.ParseIP("10.0.0.1"),
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			resource, jsonPath, ok := Source(tc.source)
+			if !ok {
+				t.Fatalf("source %q not found", tc.source)
+			}
+
+			c := DefaultConfig()
+			c.DynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), tc.obj)
+			c.Logger = microloggertest.New()
+			c.Family = tc.family
+			c.Name = "vm0"
+			c.Namespace = "default"
+			c.Resource = resource
+			switch {
+			case tc.annotation != "":
+				c.Annotation = tc.annotation
+			case tc.jsonPath != "":
+				c.JSONPath = tc.jsonPath
+			default:
+				c.JSONPath = jsonPath
+			}
+
+			p, err := New(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ip, err := p.Lookup(context.Background())
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !ip.Equal(tc.expectedIP) {
+				t.Fatalf("ip == %s, want %s", ip, tc.expectedIP)
+			}
+		})
+	}
+}
+
+func Test_New(t *testing.T) {
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+	testCases := []struct {
+		annotation string
+		family     string
+		jsonPath   string
+		resource   schema.GroupVersionResource
+	}{
+		{family: FamilyAny, resource: pods},
+		{annotation: "a", jsonPath: "{.status.podIP}", family: FamilyAny, resource: pods},
+		{jsonPath: "{.status.podIP", family: FamilyAny, resource: pods},
+		{jsonPath: "{.status.podIP}", family: "ipv5", resource: pods},
+		{jsonPath: "{.status.podIP}", family: FamilyAny},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			c := DefaultConfig()
+			c.DynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			c.Logger = microloggertest.New()
+			c.Annotation = tc.annotation
+			c.Family = tc.family
+			c.JSONPath = tc.jsonPath
+			c.Name = "vm0"
+			c.Resource = tc.resource
+
+			_, err := New(c)
+			if !IsInvalidConfig(err) {
+				t.Fatalf("error == %#v, want invalid config", err)
+			}
+		})
+	}
+}
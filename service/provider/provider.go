@@ -1,9 +1,19 @@
 package provider
 
 import (
+	"context"
 	"net"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 type Provider interface {
-	Lookup() (net.IP, error)
+	Lookup(ctx context.Context) (net.IP, error)
+}
+
+// PodProvider looks up the VM IP of a given KVM pod. It is used in controller
+// mode where a single process manages many KVM pods and has no access to their
+// host network.
+type PodProvider interface {
+	LookupPod(ctx context.Context, pod *corev1.Pod) (net.IP, error)
 }
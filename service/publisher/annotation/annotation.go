@@ -0,0 +1,97 @@
+// Package annotation implements a publisher writing the VM IP and its readiness
+// as annotations of the KVM pod. This is the original way of publishing.
+package annotation
+
+import (
+	"context"
+	"net"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
+)
+
+const (
+	Kind = "annotation"
+)
+
+// Config represents the configuration used to create a new publisher.
+type Config struct {
+	// Dependencies.
+	Updater *updater.Updater
+
+	// Settings.
+
+	// Target identifies the KVM pod and guest cluster service.
+	Target publisher.Target
+}
+
+// DefaultConfig provides a default configuration to create a new publisher
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Updater: nil,
+
+		// Settings.
+		Target: publisher.Target{},
+	}
+}
+
+// New creates a new publisher.
+func New(config Config) (*Publisher, error) {
+	// Dependencies.
+	if config.Updater == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Updater must not be empty")
+	}
+
+	// Settings.
+	err := config.Target.Validate()
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Target.%s", err)
+	}
+
+	newPublisher := &Publisher{
+		// Dependencies.
+		updater: config.Updater,
+
+		// Settings.
+		target: config.Target,
+	}
+
+	return newPublisher, nil
+}
+
+type Publisher struct {
+	publisher.State
+
+	// Dependencies.
+	updater *updater.Updater
+
+	// Settings.
+	target publisher.Target
+}
+
+// Publish writes the annotations of the KVM pod. See updater.AddAnnotations.
+func (p *Publisher) Publish(ctx context.Context, ip net.IP, ready bool) error {
+	err := p.updater.AddAnnotations(ctx, p.target.Namespace, p.target.Service, p.target.Pod, ip, ready)
+	p.Published(ip, ready, err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Withdraw removes the annotations from the KVM pod. See
+// updater.RemoveAnnotations.
+func (p *Publisher) Withdraw(ctx context.Context) error {
+	err := p.updater.RemoveAnnotations(ctx, p.target.Namespace, p.target.Service, p.target.Pod)
+	p.Withdrawn(err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
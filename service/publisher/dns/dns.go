@@ -0,0 +1,77 @@
+// Package dns implements a publisher maintaining a DNS record of the VM IP via
+// RFC 2136 dynamic updates.
+package dns
+
+import (
+	"context"
+	"net"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/dnsupdater"
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+)
+
+const (
+	Kind = "dns"
+)
+
+// Config represents the configuration used to create a new publisher.
+type Config struct {
+	// Dependencies.
+	DNSUpdater *dnsupdater.Updater
+}
+
+// DefaultConfig provides a default configuration to create a new publisher
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		DNSUpdater: nil,
+	}
+}
+
+// New creates a new publisher.
+func New(config Config) (*Publisher, error) {
+	// Dependencies.
+	if config.DNSUpdater == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.DNSUpdater must not be empty")
+	}
+
+	newPublisher := &Publisher{
+		// Dependencies.
+		dnsUpdater: config.DNSUpdater,
+	}
+
+	return newPublisher, nil
+}
+
+type Publisher struct {
+	publisher.State
+
+	// Dependencies.
+	dnsUpdater *dnsupdater.Updater
+}
+
+// Publish replaces the DNS record with the VM IP. The readiness is not
+// published. See dnsupdater.Updater.Update.
+func (p *Publisher) Publish(ctx context.Context, ip net.IP, ready bool) error {
+	err := p.dnsUpdater.Update(ctx, ip)
+	p.Published(ip, ready, err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Withdraw deletes the DNS record. See dnsupdater.Updater.Remove.
+func (p *Publisher) Withdraw(ctx context.Context) error {
+	err := p.dnsUpdater.Remove(ctx)
+	p.Withdrawn(err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
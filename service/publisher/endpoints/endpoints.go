@@ -0,0 +1,100 @@
+// Package endpoints implements a publisher writing the VM IP into the Endpoints
+// of the guest cluster service. VMs not being ready are published as not ready
+// addresses. Only the address of the KVM pod is added or removed, so that the
+// updaters of several KVM pods can publish to the same Endpoints.
+package endpoints
+
+import (
+	"context"
+	"net"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
+)
+
+const (
+	Kind = "endpoints"
+)
+
+// Config represents the configuration used to create a new publisher.
+type Config struct {
+	// Dependencies.
+	Updater *updater.Updater
+
+	// Settings.
+
+	// Target identifies the KVM pod and guest cluster service.
+	Target publisher.Target
+}
+
+// DefaultConfig provides a default configuration to create a new publisher
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Updater: nil,
+
+		// Settings.
+		Target: publisher.Target{},
+	}
+}
+
+// New creates a new publisher.
+func New(config Config) (*Publisher, error) {
+	// Dependencies.
+	if config.Updater == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Updater must not be empty")
+	}
+
+	// Settings.
+	err := config.Target.Validate()
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Target.%s", err)
+	}
+
+	newPublisher := &Publisher{
+		// Dependencies.
+		updater: config.Updater,
+
+		// Settings.
+		target: config.Target,
+	}
+
+	return newPublisher, nil
+}
+
+type Publisher struct {
+	publisher.State
+
+	// Dependencies.
+	updater *updater.Updater
+
+	// Settings.
+	target publisher.Target
+}
+
+// Publish reconciles the address of the KVM pod in the Endpoints of the guest
+// cluster service. See updater.UpdatePodEndpoints.
+func (p *Publisher) Publish(ctx context.Context, ip net.IP, ready bool) error {
+	err := p.updater.UpdatePodEndpoints(ctx, p.target.Namespace, p.target.Service, p.target.Pod, ip, ready)
+	p.Published(ip, ready, err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Withdraw removes the address of the KVM pod from the Endpoints of the guest
+// cluster service. See updater.RemovePodEndpoints.
+func (p *Publisher) Withdraw(ctx context.Context) error {
+	err := p.updater.RemovePodEndpoints(ctx, p.target.Namespace, p.target.Service, p.target.Pod)
+	p.Withdrawn(err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
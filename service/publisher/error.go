@@ -0,0 +1,10 @@
+package publisher
+
+import "github.com/giantswarm/microerror"
+
+var invalidTargetError = microerror.New("invalid target")
+
+// IsInvalidTarget asserts invalidTargetError.
+func IsInvalidTarget(err error) bool {
+	return microerror.Cause(err) == invalidTargetError
+}
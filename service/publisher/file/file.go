@@ -0,0 +1,155 @@
+// Package file implements a publisher writing the VM IP as JSON document into a
+// file, e.g. to be consumed by a sidecar sharing a volume.
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+)
+
+const (
+	Kind = "file"
+)
+
+// Config represents the configuration used to create a new publisher.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+
+	// Path is the path of the file. The file is replaced atomically, so its
+	// directory has to be writable.
+	Path string
+	// Target identifies the KVM pod and guest cluster service.
+	Target publisher.Target
+}
+
+// DefaultConfig provides a default configuration to create a new publisher
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger: nil,
+
+		// Settings.
+		Path:   "",
+		Target: publisher.Target{},
+	}
+}
+
+// New creates a new publisher.
+func New(config Config) (*Publisher, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Path == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Path must not be empty")
+	}
+
+	newPublisher := &Publisher{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Settings.
+		path:   config.Path,
+		target: config.Target,
+	}
+
+	return newPublisher, nil
+}
+
+type Publisher struct {
+	publisher.State
+
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Settings.
+	path   string
+	target publisher.Target
+}
+
+// Publish writes the VM IP and its readiness into the file. The file is only
+// written when its content changes.
+func (p *Publisher) Publish(ctx context.Context, ip net.IP, ready bool) error {
+	err := p.write(publisher.NewPayload(p.target, ip, ready))
+	p.Published(ip, ready, err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Withdraw removes the file. It is a no-op when the file does not exist.
+func (p *Publisher) Withdraw(ctx context.Context) error {
+	err := os.Remove(p.path)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	p.Withdrawn(err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (p *Publisher) write(payload publisher.Payload) error {
+	b, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	b = append(b, '\n')
+
+	current, err := ioutil.ReadFile(p.path)
+	if err == nil && bytes.Equal(current, b) {
+		return nil
+	}
+
+	// The file is written next to its destination and renamed, so that
+	// readers never see partial content.
+	tmp, err := ioutil.TempFile(filepath.Dir(p.path), "."+filepath.Base(p.path)+".")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return microerror.Mask(err)
+	}
+	err = tmp.Chmod(0644)
+	if err != nil {
+		tmp.Close()
+		return microerror.Mask(err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = os.Rename(tmp.Name(), p.path)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_ = p.logger.Log("level", "debug", "message", "wrote VM IP to file", "path", p.path, "ip", payload.IP)
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+)
+
+func Test_Publisher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-publisher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := DefaultConfig()
+	c.Logger = microloggertest.New()
+	c.Path = filepath.Join(dir, "endpoint.json")
+	c.Target = publisher.Target{Namespace: "default", Pod: "kvm", Service: "master"}
+
+	p, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Publish(context.Background(), net.ParseIP("10.1.2.3"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "ip": "10.1.2.3",
+  "namespace": "default",
+  "pod": "kvm",
+  "ready": true,
+  "service": "master"
+}
+`
+	if string(b) != expected {
+		t.Fatalf("content == %q, want %q", b, expected)
+	}
+	if !p.Status().Equal(net.ParseIP("10.1.2.3"), true) {
+		t.Fatalf("status == %#v, want published", p.Status())
+	}
+
+	// Only the published file is left behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("files == %d, want 1", len(files))
+	}
+
+	for i := 0; i < 2; i++ {
+		err = p.Withdraw(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = os.Stat(c.Path)
+	if !os.IsNotExist(err) {
+		t.Fatalf("error == %#v, want file to be removed", err)
+	}
+	if p.Status().IP != nil {
+		t.Fatalf("status == %#v, want withdrawn", p.Status())
+	}
+}
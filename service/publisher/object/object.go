@@ -0,0 +1,103 @@
+// Package object implements a publisher writing the VM IP into a field of an
+// arbitrary object, e.g. a custom resource consuming it.
+package object
+
+import (
+	"context"
+	"net"
+
+	"github.com/giantswarm/microerror"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+	"github.com/giantswarm/k8s-endpoint-updater/service/updater"
+)
+
+const (
+	Kind = "object"
+)
+
+// Config represents the configuration used to create a new publisher.
+type Config struct {
+	// Dependencies.
+	Updater *updater.Updater
+
+	// Settings.
+
+	// Object is the object and field the VM IP is written to.
+	Object updater.ObjectTarget
+}
+
+// DefaultConfig provides a default configuration to create a new publisher
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Updater: nil,
+
+		// Settings.
+		Object: updater.ObjectTarget{},
+	}
+}
+
+// New creates a new publisher.
+func New(config Config) (*Publisher, error) {
+	// Dependencies.
+	if config.Updater == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Updater must not be empty")
+	}
+
+	// Settings.
+	if config.Object.Name == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Object.Name must not be empty")
+	}
+	if config.Object.Resource.Resource == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Object.Resource must not be empty")
+	}
+	_, err := updater.ParseObjectField(config.Object.Field)
+	if err != nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Object.Field %s", err)
+	}
+
+	newPublisher := &Publisher{
+		// Dependencies.
+		updater: config.Updater,
+
+		// Settings.
+		object: config.Object,
+	}
+
+	return newPublisher, nil
+}
+
+type Publisher struct {
+	publisher.State
+
+	// Dependencies.
+	updater *updater.Updater
+
+	// Settings.
+	object updater.ObjectTarget
+}
+
+// Publish writes the VM IP into the field of the object. The readiness is not
+// published. See updater.PublishObject.
+func (p *Publisher) Publish(ctx context.Context, ip net.IP, ready bool) error {
+	err := p.updater.PublishObject(ctx, p.object, ip)
+	p.Published(ip, ready, err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Withdraw removes the field from the object. See updater.WithdrawObject.
+func (p *Publisher) Withdraw(ctx context.Context) error {
+	err := p.updater.WithdrawObject(ctx, p.object)
+	p.Withdrawn(err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package publisher defines the interface of sinks the VM IP is published to,
+// e.g. the KVM pod annotations, the guest cluster Endpoints, a file or a
+// webhook. The update command fans out a single lookup to all configured
+// publishers.
+package publisher
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+)
+
+// Publisher publishes the VM IP to a single sink.
+type Publisher interface {
+	// Publish publishes the given VM IP and its readiness. It should be a
+	// no-op when the sink is up to date already, since it is called on every
+	// reconciliation.
+	Publish(ctx context.Context, ip net.IP, ready bool) error
+	// Withdraw removes the published VM IP from the sink on termination.
+	Withdraw(ctx context.Context) error
+	// Status returns the outcome of the last Publish or Withdraw call.
+	Status() Status
+}
+
+// Target identifies the KVM pod and the guest cluster service the VM IP is
+// published for.
+type Target struct {
+	Namespace string
+	Pod       string
+	Service   string
+}
+
+// Validate returns an error asserted by IsInvalidTarget when any field of the
+// target is empty.
+func (t Target) Validate() error {
+	if t.Namespace == "" {
+		return microerror.Maskf(invalidTargetError, "Namespace must not be empty")
+	}
+	if t.Pod == "" {
+		return microerror.Maskf(invalidTargetError, "Pod must not be empty")
+	}
+	if t.Service == "" {
+		return microerror.Maskf(invalidTargetError, "Service must not be empty")
+	}
+
+	return nil
+}
+
+// Payload is the document written by publishers serializing the VM IP, e.g.
+// to files or webhooks.
+type Payload struct {
+	// IP is the VM IP. It is empty when withdrawn.
+	IP        string `json:"ip,omitempty"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Ready     bool   `json:"ready"`
+	Service   string `json:"service"`
+}
+
+// NewPayload returns the payload publishing the given VM IP of the target.
+// A nil IP withdraws the target.
+func NewPayload(target Target, ip net.IP, ready bool) Payload {
+	p := Payload{
+		Namespace: target.Namespace,
+		Pod:       target.Pod,
+		Ready:     ready,
+		Service:   target.Service,
+	}
+	if ip != nil {
+		p.IP = ip.String()
+	}
+
+	return p
+}
+
+// Status is the outcome of the last Publish or Withdraw call of a publisher.
+type Status struct {
+	// IP is the published VM IP. It is nil before the first successful
+	// publication and after the VM IP got withdrawn.
+	IP net.IP
+	// LastError is the error message of the last call, if it failed.
+	LastError string
+	// LastUpdateTime is the time of the last call.
+	LastUpdateTime time.Time
+	// Ready is the published readiness of the VM.
+	Ready bool
+}
+
+// Equal returns whether the given VM IP and readiness are published already
+// and the last call succeeded.
+func (s Status) Equal(ip net.IP, ready bool) bool {
+	return s.LastError == "" && s.IP != nil && s.IP.Equal(ip) && s.Ready == ready
+}
+
+// State tracks the status of a publisher. It is meant to be embedded by
+// publisher implementations to provide Status.
+type State struct {
+	mutex  sync.Mutex
+	status Status
+}
+
+// Status returns the tracked status.
+func (s *State) Status() Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.status
+}
+
+// Published tracks the outcome of a Publish call. The published IP and
+// readiness are kept when err is non-nil.
+func (s *State) Published(ip net.IP, ready bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status.LastUpdateTime = time.Now()
+	if err != nil {
+		s.status.LastError = err.Error()
+		return
+	}
+
+	s.status.IP = ip
+	s.status.LastError = ""
+	s.status.Ready = ready
+}
+
+// Withdrawn tracks the outcome of a Withdraw call. The published IP and
+// readiness are kept when err is non-nil.
+func (s *State) Withdrawn(err error) {
+	s.Published(nil, false, err)
+}
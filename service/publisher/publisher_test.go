@@ -0,0 +1,83 @@
+package publisher
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func Test_State(t *testing.T) {
+	var s State
+
+	if s.Status().Equal(nil, false) {
+		t.Fatalf("initial status must not be equal")
+	}
+
+	s.Published(net.ParseIP("10.1.2.3"), true, nil)
+	if !s.Status().Equal(net.ParseIP("10.1.2.3"), true) {
+		t.Fatalf("status == %#v, want 10.1.2.3 ready", s.Status())
+	}
+	if s.Status().Equal(net.ParseIP("10.1.2.3"), false) {
+		t.Fatalf("status must not be equal with changed readiness")
+	}
+
+	// Failures keep the published IP but force the next publication.
+	s.Published(net.ParseIP("10.1.2.4"), true, errors.New("test"))
+	if st := s.Status(); !st.IP.Equal(net.ParseIP("10.1.2.3")) || st.LastError != "test" || st.Equal(net.ParseIP("10.1.2.3"), true) {
+		t.Fatalf("status == %#v, want 10.1.2.3 with error", st)
+	}
+
+	s.Withdrawn(nil)
+	if st := s.Status(); st.IP != nil || st.LastError != "" {
+		t.Fatalf("status == %#v, want withdrawn", st)
+	}
+}
+
+func Test_Target_Validate(t *testing.T) {
+	testCases := []struct {
+		name         string
+		target       Target
+		errorMatcher func(error) bool
+	}{
+		{
+			name:         "case 0: complete target is valid",
+			target:       Target{Namespace: "default", Pod: "kvm", Service: "master"},
+			errorMatcher: nil,
+		},
+		{
+			name:         "case 1: target without namespace is invalid",
+			target:       Target{Pod: "kvm", Service: "master"},
+			errorMatcher: IsInvalidTarget,
+		},
+		{
+			name:         "case 2: target without pod is invalid",
+			target:       Target{Namespace: "default", Service: "master"},
+			errorMatcher: IsInvalidTarget,
+		},
+		{
+			name:         "case 3: target without service is invalid",
+			target:       Target{Namespace: "default", Pod: "kvm"},
+			errorMatcher: IsInvalidTarget,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			err := tc.target.Validate()
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Package webhook implements a publisher sending the VM IP as JSON document to
+// an HTTP endpoint whenever it changes.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+)
+
+const (
+	Kind = "webhook"
+)
+
+const (
+	// DefaultTimeout is the default timeout of a single request.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Actions sent with the request.
+const (
+	ActionPublish  = "publish"
+	ActionWithdraw = "withdraw"
+)
+
+// Request is the JSON document POSTed to the webhook.
+type Request struct {
+	Action string `json:"action"`
+	publisher.Payload
+}
+
+// Config represents the configuration used to create a new publisher.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+
+	// Target identifies the KVM pod and guest cluster service.
+	Target publisher.Target
+	// Timeout bounds every single request. Zero means requests are only
+	// bounded by the given contexts.
+	Timeout time.Duration
+	// Token is sent as bearer token, if any.
+	Token string
+	// URL is the URL requests are POSTed to.
+	URL string
+}
+
+// DefaultConfig provides a default configuration to create a new publisher
+// by best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger: nil,
+
+		// Settings.
+		Target:  publisher.Target{},
+		Timeout: DefaultTimeout,
+		Token:   "",
+		URL:     "",
+	}
+}
+
+// New creates a new publisher.
+func New(config Config) (*Publisher, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Timeout < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Timeout must not be negative")
+	}
+	if config.URL == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.URL must not be empty")
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.URL must be an absolute HTTP or HTTPS URL")
+	}
+
+	newPublisher := &Publisher{
+		// Dependencies.
+		logger: config.Logger,
+
+		// Internals.
+		client: &http.Client{},
+
+		// Settings.
+		target:  config.Target,
+		timeout: config.Timeout,
+		token:   config.Token,
+		url:     config.URL,
+	}
+
+	return newPublisher, nil
+}
+
+type Publisher struct {
+	publisher.State
+
+	// Dependencies.
+	logger micrologger.Logger
+
+	// Internals.
+	client *http.Client
+
+	// Settings.
+	target  publisher.Target
+	timeout time.Duration
+	token   string
+	url     string
+}
+
+// Publish sends the VM IP and its readiness to the webhook. Nothing is sent
+// when they did not change since the last successful request.
+func (p *Publisher) Publish(ctx context.Context, ip net.IP, ready bool) error {
+	if p.Status().Equal(ip, ready) {
+		return nil
+	}
+
+	err := p.send(ctx, Request{Action: ActionPublish, Payload: publisher.NewPayload(p.target, ip, ready)})
+	p.Published(ip, ready, err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// Withdraw notifies the webhook about the VM IP being withdrawn.
+func (p *Publisher) Withdraw(ctx context.Context) error {
+	err := p.send(ctx, Request{Action: ActionWithdraw, Payload: publisher.NewPayload(p.target, nil, false)})
+	p.Withdrawn(err)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (p *Publisher) send(ctx context.Context, r Request) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if p.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(b))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	defer res.Body.Close()
+
+	// The body is drained so that the connection can be reused.
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return microerror.Maskf(requestFailedError, "%s responded %s", p.url, res.Status)
+	}
+
+	_ = p.logger.Log("level", "debug", "message", "sent VM IP to webhook", "url", p.url, "action", r.Action, "ip", r.IP)
+
+	return nil
+}
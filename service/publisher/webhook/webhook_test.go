@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+)
+
+func Test_Publisher(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []Request
+	status := http.StatusOK
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var req Request
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, req)
+
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+
+	target := publisher.Target{Namespace: "default", Pod: "kvm", Service: "master"}
+
+	c := DefaultConfig()
+	c.Logger = microloggertest.New()
+	c.Target = target
+	c.Token = "token"
+	c.URL = s.URL
+
+	p, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Publishing the same IP twice only sends a single request.
+	for i := 0; i < 2; i++ {
+		err = p.Publish(context.Background(), net.ParseIP("10.1.2.3"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Failed requests are retried with the next publication.
+	mutex.Lock()
+	status = http.StatusInternalServerError
+	mutex.Unlock()
+
+	err = p.Publish(context.Background(), net.ParseIP("10.1.2.4"), true)
+	if !IsRequestFailed(err) {
+		t.Fatalf("error == %#v, want request failed", err)
+	}
+	if p.Status().LastError == "" {
+		t.Fatalf("status == %#v, want error", p.Status())
+	}
+
+	mutex.Lock()
+	status = http.StatusNoContent
+	mutex.Unlock()
+
+	err = p.Publish(context.Background(), net.ParseIP("10.1.2.4"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Withdraw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Request{
+		{Action: ActionPublish, Payload: publisher.Payload{IP: "10.1.2.3", Namespace: "default", Pod: "kvm", Ready: true, Service: "master"}},
+		{Action: ActionPublish, Payload: publisher.Payload{IP: "10.1.2.4", Namespace: "default", Pod: "kvm", Ready: true, Service: "master"}},
+		{Action: ActionPublish, Payload: publisher.Payload{IP: "10.1.2.4", Namespace: "default", Pod: "kvm", Ready: true, Service: "master"}},
+		{Action: ActionWithdraw, Payload: publisher.Payload{Namespace: "default", Pod: "kvm", Service: "master"}},
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("requests == %#v, want %#v", requests, expected)
+	}
+}
+
+func Test_New(t *testing.T) {
+	testCases := []string{
+		"",
+		"example.com/hook",
+		"ftp://example.com/hook",
+		"http://",
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			c := DefaultConfig()
+			c.Logger = microloggertest.New()
+			c.URL = tc
+
+			_, err := New(c)
+			if !IsInvalidConfig(err) {
+				t.Fatalf("error == %#v, want invalid config", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package resource
+
+import "github.com/giantswarm/microerror"
+
+var invalidResourceError = microerror.New("invalid resource")
+
+// IsInvalidResource asserts invalidResourceError.
+func IsInvalidResource(err error) bool {
+	return microerror.Cause(err) == invalidResourceError
+}
@@ -0,0 +1,36 @@
+// Package resource parses references to Kubernetes resources as given on the
+// command line.
+package resource
+
+import (
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Parse parses a resource given as <resource>[.<version>[.<group>]], e.g.
+// "pods", "pods.v1" or "virtualmachineinstances.v1.kubevirt.io". Resources
+// without group are part of the core group, resources without version default
+// to v1.
+func Parse(s string) (schema.GroupVersionResource, error) {
+	split := strings.SplitN(s, ".", 3)
+	for _, p := range split {
+		if p == "" {
+			return schema.GroupVersionResource{}, microerror.Maskf(invalidResourceError, "%q must have the format <resource>[.<version>[.<group>]]", s)
+		}
+	}
+
+	gvr := schema.GroupVersionResource{
+		Resource: split[0],
+		Version:  "v1",
+	}
+	if len(split) > 1 {
+		gvr.Version = split[1]
+	}
+	if len(split) > 2 {
+		gvr.Group = split[2]
+	}
+
+	return gvr, nil
+}
@@ -0,0 +1,58 @@
+package resource
+
+import (
+	"strconv"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_Parse(t *testing.T) {
+	testCases := []struct {
+		s            string
+		expected     schema.GroupVersionResource
+		errorMatcher func(error) bool
+	}{
+		{
+			s:        "pods",
+			expected: schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+		},
+		{
+			s:        "configmaps.v1",
+			expected: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+		},
+		{
+			s:        "virtualmachineinstances.v1.kubevirt.io",
+			expected: schema.GroupVersionResource{Group: "kubevirt.io", Version: "v1", Resource: "virtualmachineinstances"},
+		},
+		{
+			s:            "",
+			errorMatcher: IsInvalidResource,
+		},
+		{
+			s:            "pods..",
+			errorMatcher: IsInvalidResource,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			gvr, err := Parse(tc.s)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if gvr != tc.expected {
+				t.Fatalf("gvr == %#v, want %#v", gvr, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package restconfig
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// AbortOnDone returns a copy of the given REST config which requests are
+// aborted once ctx is done. The typed and dynamic clients of the client-go
+// version in use do not take a context, so this is the only way for a shutdown
+// not to wait for calls in flight until they time out. Requests started after
+// ctx is done are sent as usual, so that cleanups can still reach the API.
+func AbortOnDone(ctx context.Context, restConfig *rest.Config) *rest.Config {
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.WrapTransport = transport.Wrappers(restConfig.WrapTransport, func(rt http.RoundTripper) http.RoundTripper {
+		return &aborter{ctx: ctx, rt: rt}
+	})
+
+	return restConfig
+}
+
+type aborter struct {
+	ctx context.Context
+	rt  http.RoundTripper
+}
+
+func (a *aborter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if a.ctx.Err() != nil {
+		return a.rt.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-a.ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+
+	// The request stays abortable until its body got closed, which matters
+	// for watches in particular.
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			cancel()
+		})
+	}
+
+	res, err := a.rt.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	res.Body = &abortableBody{ReadCloser: res.Body, stop: stop}
+
+	return res, nil
+}
+
+type abortableBody struct {
+	io.ReadCloser
+	stop func()
+}
+
+func (b *abortableBody) Close() error {
+	defer b.stop()
+	return b.ReadCloser.Close()
+}
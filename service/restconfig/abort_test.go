@@ -0,0 +1,73 @@
+package restconfig
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func Test_Restconfig_AbortOnDone(t *testing.T) {
+	// Requests for the pod "blocked" are only answered once they got aborted.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/blocked") {
+			<-r.Context().Done()
+			return
+		}
+
+		pod := &corev1.Pod{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{Name: "kvm", Namespace: "default"},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pod)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k8sClient, err := kubernetes.NewForConfig(AbortOnDone(ctx, &rest.Config{Host: server.URL}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests not being aborted have to succeed, including reading the body
+	// after the round trip.
+	_, err = k8sClient.CoreV1().Pods("default").Get("kvm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := k8sClient.CoreV1().Pods("default").Get("blocked", metav1.GetOptions{})
+		errChan <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Fatalf("error == nil, want non-nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("request in flight not aborted within 5 seconds")
+	}
+
+	// Requests started after ctx is done are sent as usual.
+	_, err = k8sClient.CoreV1().Pods("default").Get("kvm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+}
@@ -0,0 +1,17 @@
+package restconfig
+
+import "github.com/giantswarm/microerror"
+
+var invalidConfigError = microerror.New("invalid config")
+
+// IsInvalidConfig asserts invalidConfigError.
+func IsInvalidConfig(err error) bool {
+	return microerror.Cause(err) == invalidConfigError
+}
+
+var insecureError = microerror.New("insecure")
+
+// IsInsecure asserts insecureError.
+func IsInsecure(err error) bool {
+	return microerror.Cause(err) == insecureError
+}
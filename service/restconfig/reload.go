@@ -0,0 +1,305 @@
+package restconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+)
+
+// reloader keeps the CA, certificate and key files of a REST config up to
+// date. client-go reads them only once when creating the transport, which
+// fails all requests as soon as rotated files expire.
+type reloader struct {
+	logger micrologger.Logger
+
+	caData     []byte
+	caFile     string
+	certData   []byte
+	certFile   string
+	keyData    []byte
+	keyFile    string
+	serverName string
+
+	conns  map[*trackedConn]struct{}
+	dialer *net.Dialer
+	mutex  sync.Mutex
+
+	cert *tls.Certificate
+	hash []byte
+	pool *x509.CertPool
+}
+
+// withReloader replaces the TLS settings of the given REST config with a
+// transport reading the CA, certificate and key files again every interval.
+// Established connections are closed when the files changed so that the next
+// request performs a new TLS handshake. The files are watched until ctx is
+// done. The REST config is returned unchanged when it does not use any of
+// these files.
+func withReloader(ctx context.Context, logger micrologger.Logger, restConfig *rest.Config, interval time.Duration) (*rest.Config, error) {
+	tlsConfig := restConfig.TLSClientConfig
+
+	if tlsConfig.CAFile == "" && tlsConfig.CertFile == "" && tlsConfig.KeyFile == "" {
+		return restConfig, nil
+	}
+	if restConfig.Transport != nil {
+		return nil, microerror.Maskf(invalidConfigError, "TLS files must not be reloaded for custom transports")
+	}
+
+	serverName := tlsConfig.ServerName
+	if serverName == "" {
+		u, err := url.Parse(restConfig.Host)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+		serverName = u.Hostname()
+	}
+
+	r := &reloader{
+		logger: logger,
+
+		caData:     tlsConfig.CAData,
+		caFile:     tlsConfig.CAFile,
+		certData:   tlsConfig.CertData,
+		certFile:   tlsConfig.CertFile,
+		keyData:    tlsConfig.KeyData,
+		keyFile:    tlsConfig.KeyFile,
+		serverName: serverName,
+
+		conns: map[*trackedConn]struct{}{},
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+	}
+
+	_, err := r.load()
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	transport := utilnet.SetTransportDefaults(&http.Transport{
+		DialContext:         r.dial,
+		MaxIdleConnsPerHost: 25,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			NextProtos: tlsConfig.NextProtos,
+			ServerName: serverName,
+
+			GetClientCertificate: r.clientCertificate,
+			// The server certificate is verified by verifyServer against the
+			// current CA pool, as the roots of a tls.Config cannot be replaced.
+			InsecureSkipVerify:    true,
+			VerifyPeerCertificate: r.verifyServer,
+		},
+	})
+	if tlsConfig.Insecure {
+		transport.TLSClientConfig.VerifyPeerCertificate = nil
+	}
+
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.TLSClientConfig = rest.TLSClientConfig{}
+	restConfig.Transport = transport
+
+	go r.run(ctx, interval)
+
+	return restConfig, nil
+}
+
+// run reloads the files every interval until ctx is done.
+func (r *reloader) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := r.load()
+		if err != nil {
+			_ = r.logger.Log("level", "error", "message", "failed reloading TLS files", "error", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		_ = r.logger.Log("level", "info", "message", "reloaded TLS files", "caFile", r.caFile, "certFile", r.certFile, "keyFile", r.keyFile)
+
+		r.closeConns()
+	}
+}
+
+func (r *reloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cert == nil {
+		return &tls.Certificate{}, nil
+	}
+
+	return r.cert, nil
+}
+
+func (r *reloader) closeConns() {
+	r.mutex.Lock()
+	conns := r.conns
+	r.conns = map[*trackedConn]struct{}{}
+	r.mutex.Unlock()
+
+	for c := range conns {
+		_ = c.Conn.Close()
+	}
+}
+
+func (r *reloader) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := r.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &trackedConn{Conn: conn, reloader: r}
+
+	r.mutex.Lock()
+	r.conns[c] = struct{}{}
+	r.mutex.Unlock()
+
+	return c, nil
+}
+
+// load reads the files and replaces the certificate and CA pool when their
+// content changed. Invalid files, e.g. written only partially during rotation,
+// are reported as error and the previous certificate and CA pool are kept.
+func (r *reloader) load() (bool, error) {
+	caData, err := readFileOr(r.caFile, r.caData)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	certData, err := readFileOr(r.certFile, r.certData)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+	keyData, err := readFileOr(r.keyFile, r.keyData)
+	if err != nil {
+		return false, microerror.Mask(err)
+	}
+
+	h := sha256.New()
+	for _, b := range [][]byte{caData, certData, keyData} {
+		_, _ = h.Write(b)
+		_, _ = h.Write([]byte{0})
+	}
+	hash := h.Sum(nil)
+
+	r.mutex.Lock()
+	unchanged := bytes.Equal(hash, r.hash)
+	r.mutex.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	var cert *tls.Certificate
+	if len(certData) != 0 || len(keyData) != 0 {
+		c, err := tls.X509KeyPair(certData, keyData)
+		if err != nil {
+			return false, microerror.Maskf(invalidConfigError, "invalid certificate or key: %s", err)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if len(caData) != 0 {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return false, microerror.Maskf(invalidConfigError, "invalid CA: no certificate found")
+		}
+	}
+
+	r.mutex.Lock()
+	r.cert = cert
+	r.hash = hash
+	r.pool = pool
+	r.mutex.Unlock()
+
+	return true, nil
+}
+
+func (r *reloader) verifyServer(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	r.mutex.Lock()
+	pool := r.pool
+	r.mutex.Unlock()
+
+	var certs []*x509.Certificate
+	for _, raw := range rawCerts {
+		c, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return microerror.Maskf(invalidConfigError, "server presented no certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       r.serverName,
+		Intermediates: x509.NewCertPool(),
+		Roots:         pool,
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	_, err := certs[0].Verify(opts)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+type trackedConn struct {
+	net.Conn
+
+	reloader *reloader
+	once     sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.reloader.mutex.Lock()
+		delete(c.reloader.conns, c)
+		c.reloader.mutex.Unlock()
+	})
+
+	return c.Conn.Close()
+}
+
+func readFileOr(path string, data []byte) ([]byte, error) {
+	if path == "" {
+		return data, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return b, nil
+}
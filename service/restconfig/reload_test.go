@@ -0,0 +1,392 @@
+package restconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/giantswarm/micrologger/microloggertest"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	testReloadInterval = 10 * time.Millisecond
+	testWait           = 5 * time.Second
+)
+
+func Test_Restconfig_withReloader(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	otherCA := newTestCA(t, "other-ca")
+
+	testCases := []struct {
+		name         string
+		serverCA     *testCA
+		serverIPs    []net.IP
+		serverNames  []string
+		clientCA     *testCA
+		insecure     bool
+		errorMatcher bool
+	}{
+		{
+			name:         "case 0: server certificate signed by the configured CA is accepted",
+			serverCA:     ca,
+			serverIPs:    []net.IP{net.ParseIP("127.0.0.1")},
+			clientCA:     ca,
+			insecure:     false,
+			errorMatcher: false,
+		},
+		{
+			name:         "case 1: server certificate signed by another CA is rejected",
+			serverCA:     otherCA,
+			serverIPs:    []net.IP{net.ParseIP("127.0.0.1")},
+			clientCA:     ca,
+			insecure:     false,
+			errorMatcher: true,
+		},
+		{
+			name:         "case 2: server certificate for another host is rejected",
+			serverCA:     ca,
+			serverNames:  []string{"kubernetes.example.com"},
+			clientCA:     ca,
+			insecure:     false,
+			errorMatcher: true,
+		},
+		{
+			name:         "case 3: server certificate signed by an unknown CA is accepted when insecure",
+			serverCA:     otherCA,
+			serverNames:  []string{"kubernetes.example.com"},
+			clientCA:     nil,
+			insecure:     true,
+			errorMatcher: false,
+		},
+		{
+			name:         "case 4: server certificate signed by an unknown CA is rejected without CA",
+			serverCA:     otherCA,
+			serverIPs:    []net.IP{net.ParseIP("127.0.0.1")},
+			clientCA:     nil,
+			insecure:     false,
+			errorMatcher: true,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			server := newTestServer(t, tc.serverCA, tc.serverIPs, tc.serverNames)
+			defer server.Close()
+
+			dir := newTestDir(t)
+			defer os.RemoveAll(dir)
+
+			certPEM, keyPEM := ca.issue(t, "client", nil, nil)
+			writeTestFile(t, dir, "crt.pem", certPEM)
+			writeTestFile(t, dir, "key.pem", keyPEM)
+
+			tlsConfig := rest.TLSClientConfig{
+				CertFile: filepath.Join(dir, "crt.pem"),
+				KeyFile:  filepath.Join(dir, "key.pem"),
+				Insecure: tc.insecure,
+			}
+			if tc.clientCA != nil {
+				writeTestFile(t, dir, "ca.pem", tc.clientCA.pem)
+				tlsConfig.CAFile = filepath.Join(dir, "ca.pem")
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			client := newTestClient(t, ctx, server.URL, tlsConfig)
+
+			commonName, err := get(client, server.URL)
+
+			switch {
+			case err == nil && !tc.errorMatcher:
+				// correct; carry on
+			case err != nil && !tc.errorMatcher:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher:
+				t.Fatalf("error == nil, want non-nil")
+			}
+
+			if !tc.errorMatcher && commonName != "client" {
+				t.Fatalf("common name == %q, want %q", commonName, "client")
+			}
+		})
+	}
+}
+
+func Test_Restconfig_withReloader_Rotation(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	otherCA := newTestCA(t, "other-ca")
+
+	server := newTestServer(t, ca, []net.IP{net.ParseIP("127.0.0.1")}, nil)
+	defer server.Close()
+
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	certPEM, keyPEM := ca.issue(t, "client-1", nil, nil)
+	writeTestFile(t, dir, "ca.pem", otherCA.pem)
+	writeTestFile(t, dir, "crt.pem", certPEM)
+	writeTestFile(t, dir, "key.pem", keyPEM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := newTestClient(t, ctx, server.URL, rest.TLSClientConfig{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "crt.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	})
+
+	_, err := get(client, server.URL)
+	if err == nil {
+		t.Fatalf("error == nil, want non-nil")
+	}
+
+	// The rotated CA has to be used without creating a new client.
+	writeTestFile(t, dir, "ca.pem", ca.pem)
+
+	waitFor(t, client, server.URL, "client-1")
+
+	// The rotated client certificate has to be presented although the
+	// previous connection is kept alive.
+	certPEM, keyPEM = ca.issue(t, "client-2", nil, nil)
+	writeTestFile(t, dir, "crt.pem", certPEM)
+	writeTestFile(t, dir, "key.pem", keyPEM)
+
+	waitFor(t, client, server.URL, "client-2")
+}
+
+func Test_Restconfig_withReloader_PartiallyWritten(t *testing.T) {
+	ca := newTestCA(t, "ca")
+
+	server := newTestServer(t, ca, []net.IP{net.ParseIP("127.0.0.1")}, nil)
+	defer server.Close()
+
+	dir := newTestDir(t)
+	defer os.RemoveAll(dir)
+
+	certPEM, keyPEM := ca.issue(t, "client-1", nil, nil)
+	writeTestFile(t, dir, "ca.pem", ca.pem)
+	writeTestFile(t, dir, "crt.pem", certPEM)
+	writeTestFile(t, dir, "key.pem", keyPEM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := newTestClient(t, ctx, server.URL, rest.TLSClientConfig{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "crt.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	})
+
+	waitFor(t, client, server.URL, "client-1")
+
+	// Only the first half of the rotated certificate is written, as seen in the
+	// middle of a rotation.
+	certPEM, _ = ca.issue(t, "client-2", nil, nil)
+	writeTestFile(t, dir, "crt.pem", certPEM[:len(certPEM)/2])
+
+	time.Sleep(10 * testReloadInterval)
+
+	// New connections still have to use the previous certificate.
+	client.Transport.(*http.Transport).CloseIdleConnections()
+
+	commonName, err := get(client, server.URL)
+	if err != nil {
+		t.Fatalf("error == %#v, want nil", err)
+	}
+	if commonName != "client-1" {
+		t.Fatalf("common name == %q, want %q", commonName, "client-1")
+	}
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, commonName string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a certificate and key signed by the CA. Certificates without
+// IPs and DNS names are client certificates.
+func (c *testCA) issue(t *testing.T, commonName string, ips []net.IP, dnsNames []string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extKeyUsage := x509.ExtKeyUsageClientAuth
+	if len(ips) != 0 || len(dnsNames) != 0 {
+		extKeyUsage = x509.ExtKeyUsageServerAuth
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{extKeyUsage},
+		IPAddresses:  ips,
+		DNSNames:     dnsNames,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, c.cert, &key.PublicKey, c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM
+}
+
+// newTestServer starts a TLS server presenting a certificate signed by the
+// given CA. The server responds with the common name of the client
+// certificate.
+func newTestServer(t *testing.T, ca *testCA, ips []net.IP, dnsNames []string) *httptest.Server {
+	certPEM, keyPEM := ca.issue(t, "server", ips, dnsNames)
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequestClientCert,
+	}
+	server.StartTLS()
+
+	return server
+}
+
+func newTestClient(t *testing.T, ctx context.Context, host string, tlsConfig rest.TLSClientConfig) *http.Client {
+	restConfig := &rest.Config{
+		Host:            host,
+		TLSClientConfig: tlsConfig,
+	}
+
+	restConfig, err := withReloader(ctx, microloggertest.New(), restConfig, testReloadInterval)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &http.Client{
+		Transport: restConfig.Transport,
+		Timeout:   testWait,
+	}
+}
+
+func newTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "restconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func writeTestFile(t *testing.T, dir, name string, data []byte) {
+	err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get returns the common name of the client certificate as seen by the test
+// server.
+func get(client *http.Client, url string) (string, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// waitFor polls the test server until it sees the client certificate with the
+// given common name.
+func waitFor(t *testing.T, client *http.Client, url string, commonName string) {
+	deadline := time.Now().Add(testWait)
+
+	for {
+		got, err := get(client, url)
+		if err == nil && got == commonName {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("common name == %q, error == %#v, want %q", got, err, commonName)
+		}
+
+		time.Sleep(testReloadInterval)
+	}
+}
@@ -0,0 +1,378 @@
+// Package restconfig creates the Kubernetes REST config shared by all commands.
+// It supports kubeconfig files and contexts, in-cluster config and plain
+// addresses, each optionally combined with bearer token authentication.
+package restconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// DefaultBurst is the default burst of requests to the Kubernetes API.
+	DefaultBurst = 100
+	// DefaultQPS is the default rate of requests per second to the Kubernetes
+	// API.
+	DefaultQPS = 100
+	// DefaultReloadInterval is the default interval in which TLS files are
+	// checked for changes.
+	DefaultReloadInterval = time.Minute
+	// DefaultTimeout is the default timeout of a single request to the
+	// Kubernetes API.
+	DefaultTimeout = 30 * time.Second
+)
+
+const (
+	// ModeAddress connects to config.Address.
+	ModeAddress = "address"
+	// ModeAuto detects the mode. See New for details.
+	ModeAuto = "auto"
+	// ModeInCluster uses the service account of the pod to authenticate.
+	ModeInCluster = "inCluster"
+	// ModeKubeconfig loads a kubeconfig.
+	ModeKubeconfig = "kubeconfig"
+)
+
+var (
+	// serviceAccountTokenFile is the token file mounted into pods with a
+	// service account, as used by rest.InClusterConfig.
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// ConfigTLS contains settings to enable transport layer security.
+type ConfigTLS struct {
+	// CAFile is the CA certificate for the cluster.
+	CAFile string
+	// CrtFile is the TLS client certificate.
+	CrtFile string
+	// KeyFile is the key for the TLS client certificate.
+	KeyFile string
+	// ReloadInterval is the interval in which the CA, certificate and key
+	// files are checked for changes. Zero disables reloading.
+	ReloadInterval time.Duration
+}
+
+// Config represents the configuration used to create a new REST config.
+type Config struct {
+	// Dependencies.
+	Logger micrologger.Logger
+
+	// Settings.
+
+	// Address of the Kubernetes API. When used together with a kubeconfig it
+	// overrides the server of the selected cluster.
+	Address string
+	// AllowInsecure allows connecting to the Kubernetes API via plain HTTP.
+	AllowInsecure bool
+	// Burst is the maximum burst of requests to the Kubernetes API.
+	Burst int
+	// Context is the kubeconfig context to use. The current context is used
+	// when empty.
+	Context string
+	// Kubeconfig is the path of the kubeconfig file. The KUBECONFIG environment
+	// variable and the default kubeconfig location are honoured when empty.
+	Kubeconfig string
+	// Mode is the way the REST config is created. One of ModeAddress, ModeAuto,
+	// ModeInCluster or ModeKubeconfig.
+	Mode string
+	// QPS is the maximum rate of requests per second to the Kubernetes API.
+	QPS float32
+	// Timeout of a single request to the Kubernetes API. Zero means no timeout.
+	// The timeout also cuts off watches, which therefore have to use a REST
+	// config created by ForWatches.
+	Timeout time.Duration
+	TLS     ConfigTLS
+	// Token is the bearer token used to authenticate.
+	Token string
+	// TokenFile is the path of a file holding the bearer token used to
+	// authenticate. The file is read again when the token expires.
+	TokenFile string
+}
+
+// DefaultConfig provides a default configuration to create a new REST config by
+// best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Logger: nil,
+
+		// Settings.
+		Address:       "",
+		AllowInsecure: false,
+		Burst:         DefaultBurst,
+		Context:       "",
+		Kubeconfig:    "",
+		Mode:          ModeAuto,
+		QPS:           DefaultQPS,
+		Timeout:       DefaultTimeout,
+		TLS: ConfigTLS{
+			ReloadInterval: DefaultReloadInterval,
+		},
+		Token:     "",
+		TokenFile: "",
+	}
+}
+
+// New creates a new REST config. In ModeAuto the mode is detected in the
+// following order.
+//
+//	ModeKubeconfig when config.Kubeconfig or config.Context is set.
+//	ModeInCluster when config.Address is empty and the process runs in a
+//	pod with a service account token.
+//	ModeAddress when config.Address is set.
+//	ModeKubeconfig when a kubeconfig exists at KUBECONFIG or the default
+//	location.
+//
+// TLS files are reloaded until the given context is done, see
+// Config.TLS.ReloadInterval.
+func New(ctx context.Context, config Config) (*rest.Config, error) {
+	// Dependencies.
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Burst < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Burst must not be negative")
+	}
+	if config.QPS < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.QPS must not be negative")
+	}
+	if config.Timeout < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.Timeout must not be negative")
+	}
+	if config.TLS.ReloadInterval < 0 {
+		return nil, microerror.Maskf(invalidConfigError, "config.TLS.ReloadInterval must not be negative")
+	}
+	if config.Token != "" && config.TokenFile != "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Token and config.TokenFile must not be used together")
+	}
+	if !IsMode(config.Mode) {
+		return nil, microerror.Maskf(invalidConfigError, "config.Mode must be one of %s", strings.Join(Modes(), ", "))
+	}
+	if config.Address != "" {
+		u, err := url.Parse(config.Address)
+		if err != nil {
+			return nil, microerror.Maskf(invalidConfigError, "config.Address must be a valid URL: %s", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, microerror.Maskf(invalidConfigError, "config.Address must contain scheme and host")
+		}
+	}
+
+	mode, err := detectMode(config)
+	if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	var restConfig *rest.Config
+	switch mode {
+	case ModeAddress:
+		if config.Address == "" {
+			return nil, microerror.Maskf(invalidConfigError, "config.Address must not be empty in mode %s", mode)
+		}
+
+		restConfig = &rest.Config{
+			Host: config.Address,
+		}
+	case ModeInCluster:
+		if config.Kubeconfig != "" || config.Context != "" {
+			return nil, microerror.Maskf(invalidConfigError, "config.Kubeconfig and config.Context must be empty in mode %s", mode)
+		}
+
+		restConfig, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	case ModeKubeconfig:
+		restConfig, err = fromKubeconfig(config)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	// Explicitly configured settings take precedence over the ones of the
+	// kubeconfig or the in-cluster config.
+	if config.TLS.CAFile != "" {
+		restConfig.TLSClientConfig.CAData = nil
+		restConfig.TLSClientConfig.CAFile = config.TLS.CAFile
+	}
+	if config.TLS.CrtFile != "" {
+		restConfig.TLSClientConfig.CertData = nil
+		restConfig.TLSClientConfig.CertFile = config.TLS.CrtFile
+	}
+	if config.TLS.KeyFile != "" {
+		restConfig.TLSClientConfig.KeyData = nil
+		restConfig.TLSClientConfig.KeyFile = config.TLS.KeyFile
+	}
+	if config.Token != "" {
+		restConfig.BearerToken = config.Token
+		restConfig.BearerTokenFile = ""
+	}
+	if config.TokenFile != "" {
+		token, err := readTokenFile(config.TokenFile)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+
+		restConfig.BearerToken = token
+		restConfig.BearerTokenFile = config.TokenFile
+	}
+
+	restConfig.Burst = config.Burst
+	restConfig.QPS = config.QPS
+	restConfig.Timeout = config.Timeout
+
+	if !config.AllowInsecure && !rest.IsConfigTransportTLS(*restConfig) {
+		return nil, microerror.Maskf(insecureError, "refusing to connect to '%s' via plain HTTP", restConfig.Host)
+	}
+
+	method := authMethod(restConfig)
+
+	if config.TLS.ReloadInterval != 0 {
+		restConfig, err = withReloader(ctx, config.Logger, restConfig, config.TLS.ReloadInterval)
+		if err != nil {
+			return nil, microerror.Mask(err)
+		}
+	}
+
+	_ = config.Logger.Log("level", "info", "message", "created REST config", "mode", mode, "host", restConfig.Host, "auth", method)
+
+	return restConfig, nil
+}
+
+// ForWatches returns a copy of the given REST config without timeout, to be
+// used for long running watches, e.g. by informers.
+func ForWatches(restConfig *rest.Config) *rest.Config {
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.Timeout = 0
+
+	return restConfig
+}
+
+// IsMode returns whether the given mode is supported.
+func IsMode(mode string) bool {
+	for _, m := range Modes() {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Modes returns all supported modes.
+func Modes() []string {
+	return []string{
+		ModeAddress,
+		ModeAuto,
+		ModeInCluster,
+		ModeKubeconfig,
+	}
+}
+
+func authMethod(restConfig *rest.Config) string {
+	switch {
+	case restConfig.BearerTokenFile != "":
+		return "tokenFile"
+	case restConfig.BearerToken != "":
+		return "token"
+	case restConfig.CertFile != "" || len(restConfig.CertData) != 0:
+		return "clientCertificate"
+	case restConfig.ExecProvider != nil:
+		return "exec"
+	case restConfig.AuthProvider != nil:
+		return "authProvider"
+	case restConfig.Username != "":
+		return "basic"
+	default:
+		return "none"
+	}
+}
+
+func detectMode(config Config) (string, error) {
+	if config.Mode != ModeAuto {
+		return config.Mode, nil
+	}
+
+	if config.Kubeconfig != "" || config.Context != "" {
+		return ModeKubeconfig, nil
+	}
+	if config.Address == "" && inCluster() {
+		return ModeInCluster, nil
+	}
+	if config.Address != "" {
+		return ModeAddress, nil
+	}
+	if kubeconfigExists() {
+		return ModeKubeconfig, nil
+	}
+
+	return "", microerror.Maskf(invalidConfigError, "neither in-cluster config nor kubeconfig found and config.Address is empty")
+}
+
+func fromKubeconfig(config Config) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = config.Kubeconfig
+
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: config.Context,
+	}
+	if config.Address != "" {
+		overrides.ClusterInfo.Server = config.Address
+	}
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if clientcmd.IsEmptyConfig(err) {
+		return nil, microerror.Maskf(invalidConfigError, "kubeconfig must not be empty")
+	} else if err != nil {
+		return nil, microerror.Mask(err)
+	}
+
+	return restConfig, nil
+}
+
+// inCluster returns whether the process runs in a pod with a service account
+// token, using the same conditions as rest.InClusterConfig.
+func inCluster() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" || os.Getenv("KUBERNETES_SERVICE_PORT") == "" {
+		return false
+	}
+
+	_, err := os.Stat(serviceAccountTokenFile)
+	return err == nil
+}
+
+func kubeconfigExists() bool {
+	for _, p := range clientcmd.NewDefaultClientConfigLoadingRules().GetLoadingPrecedence() {
+		_, err := os.Stat(p)
+		if err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readTokenFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", microerror.Mask(err)
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", microerror.Maskf(invalidConfigError, "token file '%s' must not be empty", path)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,140 @@
+// Package server implements the HTTP server exposing runtime information of
+// the command line tool, i.e. Prometheus metrics as well as liveness and
+// readiness probes.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/health"
+)
+
+const (
+	healthzPath = "/healthz"
+	metricsPath = "/metrics"
+	readyzPath  = "/readyz"
+)
+
+// Config represents the configuration used to create a new server.
+type Config struct {
+	// Dependencies.
+	Health *health.Health
+	Logger micrologger.Logger
+
+	// Settings.
+
+	// Address is the address the server listens on, e.g. ":8000".
+	Address string
+}
+
+// DefaultConfig provides a default configuration to create a new server by
+// best effort.
+func DefaultConfig() Config {
+	return Config{
+		// Dependencies.
+		Health: nil,
+		Logger: nil,
+
+		// Settings.
+		Address: "",
+	}
+}
+
+// New creates a new server.
+func New(config Config) (*Server, error) {
+	// Dependencies.
+	if config.Health == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Health must not be empty")
+	}
+	if config.Logger == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
+	}
+
+	// Settings.
+	if config.Address == "" {
+		return nil, microerror.Maskf(invalidConfigError, "config.Address must not be empty")
+	}
+
+	newServer := &Server{
+		// Dependencies.
+		health: config.Health,
+		logger: config.Logger,
+
+		// Internals.
+		httpServer: nil,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthzPath, newServer.healthz)
+	mux.Handle(metricsPath, promhttp.Handler())
+	mux.HandleFunc(readyzPath, newServer.readyz)
+
+	newServer.httpServer = &http.Server{
+		Addr:    config.Address,
+		Handler: mux,
+	}
+
+	return newServer, nil
+}
+
+type Server struct {
+	// Dependencies.
+	health *health.Health
+	logger micrologger.Logger
+
+	// Internals.
+	httpServer *http.Server
+}
+
+// Boot starts the server in the background. Errors other than the server
+// being shut down are logged.
+func (s *Server) Boot() {
+	go func() {
+		_ = s.logger.Log("level", "debug", "message", "start serving HTTP", "address", s.httpServer.Addr)
+
+		err := s.httpServer.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			_ = s.logger.Log("level", "error", "message", "failed serving HTTP", "address", s.httpServer.Addr, "error", err)
+		}
+	}()
+}
+
+// Shutdown gracefully stops the server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// healthz reports the process as alive as long as it is able to serve HTTP.
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+// readyz reports the process as ready once the endpoint got published and as
+// long as the last successful reconciliation is fresh.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	err := s.health.Ready()
+	if health.IsNotReady(err) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintln(w, err.Error())
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintln(w, "ok")
+}
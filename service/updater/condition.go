@@ -0,0 +1,79 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionPublished is the pod condition owned by the updater. KVM pods
+	// can declare it as readiness gate to only become ready once the endpoint
+	// is published.
+	ConditionPublished corev1.PodConditionType = "endpoint.kvm.giantswarm.io/published"
+)
+
+// Reasons of the published pod condition.
+const (
+	ReasonNotReady  = "VMNotReady"
+	ReasonPublished = "Published"
+	ReasonWithdrawn = "Withdrawn"
+)
+
+// UpdatePodCondition sets the published condition on the status of the KVM
+// pod. The pod status is only patched when the condition actually changed. It
+// is a no-op when the pod does not exist anymore.
+func (p *Updater) UpdatePodCondition(ctx context.Context, namespace, podName string, status corev1.ConditionStatus, reason, message string) error {
+	kvmPod, err := p.getPod(ctx, namespace, podName)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	for _, c := range kvmPod.Status.Conditions {
+		if c.Type == ConditionPublished && c.Status == status && c.Reason == reason && c.Message == message {
+			return nil
+		}
+	}
+
+	condition := corev1.PodCondition{
+		Type:               ConditionPublished,
+		Status:             status,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Reason:             reason,
+		Message:            message,
+	}
+
+	// The transition time must only change when the status does.
+	for _, c := range kvmPod.Status.Conditions {
+		if c.Type == ConditionPublished && c.Status == status {
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+
+	// Pod conditions are merged by type, so the patch leaves conditions owned by
+	// others alone.
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.PodCondition{condition},
+		},
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = p.patchPod(ctx, namespace, podName, string(patch), "status")
+	if errors.IsNotFound(microerror.Cause(err)) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_Updater_UpdatePodCondition(t *testing.T) {
+	u, k8sClient, _, _ := newTestUpdater(t, newTestPod(nil))
+
+	// Setting the same condition repeatedly must only patch the pod status
+	// once.
+	for i := 0; i < 2; i++ {
+		err := u.UpdatePodCondition(context.Background(), "default", "kvm", corev1.ConditionTrue, ReasonPublished, "Published VM IP 10.0.0.2.")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if p := patches(k8sClient); len(p) != 1 {
+		t.Fatalf("patches == %q, want 1", p)
+	}
+
+	pod, err := k8sClient.CoreV1().Pods("default").Get("kvm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Status.Conditions) != 1 {
+		t.Fatalf("conditions == %v, want 1", pod.Status.Conditions)
+	}
+	c := pod.Status.Conditions[0]
+	if c.Type != ConditionPublished || c.Status != corev1.ConditionTrue || c.Reason != ReasonPublished {
+		t.Fatalf("condition == %v, want published", c)
+	}
+
+	err = u.UpdatePodCondition(context.Background(), "default", "kvm", corev1.ConditionFalse, ReasonNotReady, "Published VM IP 10.0.0.2 is not ready.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod, err = k8sClient.CoreV1().Pods("default").Get("kvm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Status.Conditions) != 1 || pod.Status.Conditions[0].Status != corev1.ConditionFalse {
+		t.Fatalf("conditions == %v, want single not ready condition", pod.Status.Conditions)
+	}
+}
+
+func Test_Updater_UpdatePodCondition_PodNotFound(t *testing.T) {
+	testCases := []struct {
+		name string
+		pod  *corev1.Pod
+	}{
+		{
+			name: "case 0: pod not found on get",
+			pod:  nil,
+		},
+		{
+			name: "case 1: pod deleted before patch",
+			pod:  newTestPod(nil),
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var objects []runtime.Object
+			if tc.pod != nil {
+				objects = append(objects, tc.pod)
+			}
+			u, k8sClient, _, _ := newTestUpdater(t, objects...)
+
+			k8sClient.PrependReactor("patch", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.NewNotFound(corev1.Resource("pods"), "kvm")
+			})
+
+			err := u.UpdatePodCondition(context.Background(), "default", "kvm", corev1.ConditionFalse, ReasonWithdrawn, "Endpoint got withdrawn on termination.")
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
+)
+
+// Target is a single KVM pod together with the IP of its VM.
+type Target struct {
+	IP  net.IP
+	Pod *corev1.Pod
+	// Ready tells whether the VM is ready to receive traffic. VMs not being
+	// ready are published as not ready addresses.
+	Ready bool
+}
+
+// UpdateEndpoints reconciles the Endpoints of the given guest cluster service
+// so that they contain exactly the VM IPs of the given targets. The ports are
+// derived from the service spec per target. Empty targets result in Endpoints
+// without subsets, given they were created by the updater. Endpoints not
+// created by the updater are left alone in this case. Empty targets of a
+// service not existing anymore, e.g. of a deleted guest cluster, are no error.
+func (p *Updater) UpdateEndpoints(ctx context.Context, namespace, service string, targets []Target) error {
+	svc, err := p.getService(ctx, namespace, service)
+	if errors.IsNotFound(err) && len(targets) == 0 {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	subsets := p.endpointSubsets(svc, targets)
+
+	var endpoints *corev1.Endpoints
+	err = call.Do(ctx, func() error {
+		var err error
+		endpoints, err = p.k8sClient.CoreV1().Endpoints(namespace).Get(service, metav1.GetOptions{})
+		return err
+	})
+	if errors.IsNotFound(err) && len(targets) == 0 {
+		return nil
+	} else if errors.IsNotFound(err) {
+		endpoints = &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            service,
+				Namespace:       namespace,
+				Labels:          managedLabels(""),
+				OwnerReferences: []metav1.OwnerReference{serviceOwnerReference(svc)},
+			},
+			Subsets: subsets,
+		}
+
+		err = call.Do(ctx, func() error {
+			_, err := p.k8sClient.CoreV1().Endpoints(namespace).Create(endpoints)
+			return err
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if len(targets) == 0 && endpoints.Labels[LabelManagedBy] != ManagedBy {
+		return nil
+	}
+	if reflect.DeepEqual(endpoints.Subsets, subsets) {
+		return nil
+	}
+
+	endpoints.Subsets = subsets
+
+	err = call.Do(ctx, func() error {
+		_, err := p.k8sClient.CoreV1().Endpoints(namespace).Update(endpoints)
+		return err
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// UpdatePodEndpoints adds the given VM IP of the KVM pod to the Endpoints of
+// the given guest cluster service, replacing the address previously published
+// for the pod. VMs not being ready end up in the not ready addresses, like
+// UpdateEndpoints does. Addresses of other pods are left alone, so that the
+// updaters of several KVM pods can publish to the same Endpoints. See
+// modifyEndpoints.
+func (p *Updater) UpdatePodEndpoints(ctx context.Context, namespace, service string, podName string, podIP net.IP, ready bool) error {
+	kvmPod, err := p.getPod(ctx, namespace, podName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	svc, err := p.getService(ctx, namespace, service)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	ports := p.endpointPorts(svc, kvmPod)
+	address := endpointAddress(Target{IP: podIP, Pod: kvmPod, Ready: ready})
+
+	err = p.modifyEndpoints(ctx, namespace, service, svc, func(subsets []corev1.EndpointSubset) []corev1.EndpointSubset {
+		subsets = withoutPod(subsets, namespace, podName)
+		if !ready {
+			return withNotReadyAddress(subsets, ports, address)
+		}
+
+		return withAddress(subsets, ports, address)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// RemovePodEndpoints removes the address of the KVM pod from the Endpoints of
+// the given guest cluster service. Addresses of other pods are left alone. It
+// is a no-op when the Endpoints do not exist. See modifyEndpoints.
+func (p *Updater) RemovePodEndpoints(ctx context.Context, namespace, service string, podName string) error {
+	err := p.modifyEndpoints(ctx, namespace, service, nil, func(subsets []corev1.EndpointSubset) []corev1.EndpointSubset {
+		return withoutPod(subsets, namespace, podName)
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// modifyEndpoints reads the Endpoints of the given guest cluster service,
+// applies modify to their subsets and writes them back when they changed. The
+// update is based on the resource version read, so that concurrent changes
+// result in an error asserted by the IsConflict helper of the Kubernetes API
+// errors package and the caller retries with the current Endpoints. Missing
+// Endpoints are created owned by the given service, unless it is nil.
+func (p *Updater) modifyEndpoints(ctx context.Context, namespace, service string, svc *corev1.Service, modify func([]corev1.EndpointSubset) []corev1.EndpointSubset) error {
+	var endpoints *corev1.Endpoints
+	err := call.Do(ctx, func() error {
+		var err error
+		endpoints, err = p.k8sClient.CoreV1().Endpoints(namespace).Get(service, metav1.GetOptions{})
+		return err
+	})
+	if errors.IsNotFound(err) {
+		subsets := modify(nil)
+		if len(subsets) == 0 || svc == nil {
+			return nil
+		}
+
+		endpoints = &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            service,
+				Namespace:       namespace,
+				Labels:          managedLabels(""),
+				OwnerReferences: []metav1.OwnerReference{serviceOwnerReference(svc)},
+			},
+			Subsets: subsets,
+		}
+
+		err = call.Do(ctx, func() error {
+			_, err := p.k8sClient.CoreV1().Endpoints(namespace).Create(endpoints)
+			return err
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	subsets := modify(endpoints.Subsets)
+	if reflect.DeepEqual(endpoints.Subsets, subsets) {
+		return nil
+	}
+
+	endpoints.Subsets = subsets
+
+	err = call.Do(ctx, func() error {
+		_, err := p.k8sClient.CoreV1().Endpoints(namespace).Update(endpoints)
+		return err
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// endpointSubsets groups the addresses of the given targets by their ports.
+// Targets only end up in different subsets when named target ports resolve to
+// different container ports. Targets not being ready end up in the not ready
+// addresses.
+func (p *Updater) endpointSubsets(svc *corev1.Service, targets []Target) []corev1.EndpointSubset {
+	var subsets []corev1.EndpointSubset
+
+	for _, t := range targets {
+		ports := p.endpointPorts(svc, t.Pod)
+		address := endpointAddress(t)
+
+		i := 0
+		for ; i < len(subsets); i++ {
+			if reflect.DeepEqual(subsets[i].Ports, ports) {
+				break
+			}
+		}
+		if i == len(subsets) {
+			subsets = append(subsets, corev1.EndpointSubset{
+				Ports: ports,
+			})
+		}
+
+		if t.Ready {
+			subsets[i].Addresses = append(subsets[i].Addresses, address)
+		} else {
+			subsets[i].NotReadyAddresses = append(subsets[i].NotReadyAddresses, address)
+		}
+	}
+
+	sortSubsets(subsets)
+
+	return subsets
+}
+
+// withAddress returns a copy of the given subsets with the given ready address
+// added to the subset of the given ports.
+func withAddress(subsets []corev1.EndpointSubset, ports []corev1.EndpointPort, address corev1.EndpointAddress) []corev1.EndpointSubset {
+	return withSubsetAddress(subsets, ports, func(subset *corev1.EndpointSubset) {
+		subset.Addresses = append(subset.Addresses, address)
+	})
+}
+
+// withNotReadyAddress returns a copy of the given subsets with the given not
+// ready address added to the subset of the given ports.
+func withNotReadyAddress(subsets []corev1.EndpointSubset, ports []corev1.EndpointPort, address corev1.EndpointAddress) []corev1.EndpointSubset {
+	return withSubsetAddress(subsets, ports, func(subset *corev1.EndpointSubset) {
+		subset.NotReadyAddresses = append(subset.NotReadyAddresses, address)
+	})
+}
+
+// withSubsetAddress returns a copy of the given subsets with add applied to the
+// subset of the given ports, which is created if missing.
+func withSubsetAddress(subsets []corev1.EndpointSubset, ports []corev1.EndpointPort, add func(*corev1.EndpointSubset)) []corev1.EndpointSubset {
+	var result []corev1.EndpointSubset
+	var added bool
+	for _, subset := range subsets {
+		subset = *subset.DeepCopy()
+		if !added && reflect.DeepEqual(subset.Ports, ports) {
+			add(&subset)
+			added = true
+		}
+
+		result = append(result, subset)
+	}
+	if !added {
+		subset := corev1.EndpointSubset{
+			Ports: ports,
+		}
+		add(&subset)
+		result = append(result, subset)
+	}
+
+	sortSubsets(result)
+
+	return result
+}
+
+// withoutPod returns a copy of the given subsets without the addresses of the
+// given KVM pod. Subsets left without any address are dropped.
+func withoutPod(subsets []corev1.EndpointSubset, namespace, podName string) []corev1.EndpointSubset {
+	keep := func(addresses []corev1.EndpointAddress) []corev1.EndpointAddress {
+		var result []corev1.EndpointAddress
+		for _, a := range addresses {
+			ref := a.TargetRef
+			if ref != nil && ref.Kind == "Pod" && ref.Namespace == namespace && ref.Name == podName {
+				continue
+			}
+
+			result = append(result, *a.DeepCopy())
+		}
+
+		return result
+	}
+
+	var result []corev1.EndpointSubset
+	for _, subset := range subsets {
+		subset = *subset.DeepCopy()
+		subset.Addresses = keep(subset.Addresses)
+		subset.NotReadyAddresses = keep(subset.NotReadyAddresses)
+		if len(subset.Addresses) == 0 && len(subset.NotReadyAddresses) == 0 {
+			continue
+		}
+
+		result = append(result, subset)
+	}
+
+	return result
+}
+
+// sortSubsets sorts the addresses of every subset and the subsets themselves,
+// so that equal Endpoints are deeply equal.
+func sortSubsets(subsets []corev1.EndpointSubset) {
+	for i := range subsets {
+		sortAddresses(subsets[i].Addresses)
+		sortAddresses(subsets[i].NotReadyAddresses)
+	}
+	sort.Slice(subsets, func(i, j int) bool { return firstIP(subsets[i]) < firstIP(subsets[j]) })
+}
+
+func sortAddresses(a []corev1.EndpointAddress) {
+	sort.Slice(a, func(i, j int) bool { return a[i].IP < a[j].IP })
+}
+
+// firstIP returns the lowest IP of the given subset, preferring ready
+// addresses. Subsets always have at least one address of either kind.
+func firstIP(subset corev1.EndpointSubset) string {
+	if len(subset.Addresses) != 0 {
+		return subset.Addresses[0].IP
+	}
+
+	return subset.NotReadyAddresses[0].IP
+}
+
+func endpointAddress(t Target) corev1.EndpointAddress {
+	address := corev1.EndpointAddress{
+		IP: t.IP.String(),
+		TargetRef: &corev1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: t.Pod.Namespace,
+			Name:      t.Pod.Name,
+			UID:       t.Pod.UID,
+		},
+	}
+	if t.Pod.Spec.NodeName != "" {
+		nodeName := t.Pod.Spec.NodeName
+		address.NodeName = &nodeName
+	}
+
+	return address
+}
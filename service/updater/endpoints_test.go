@@ -0,0 +1,336 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "master",
+			Namespace: "default",
+			UID:       "master-uid",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "https",
+					Port:       443,
+					TargetPort: intstr.FromInt(6443),
+				},
+			},
+		},
+	}
+}
+
+// writes filters the given "<verb>/<resource>" actions for creates and
+// updates of the given resource.
+func writes(actions []string, resource string) []string {
+	var w []string
+	for _, a := range actions {
+		if a == "create/"+resource || a == "update/"+resource {
+			w = append(w, a)
+		}
+	}
+
+	return w
+}
+
+func Test_Updater_UpdateEndpoints(t *testing.T) {
+	pod := newTestPod(nil)
+	pod.Spec.NodeName = "node-1"
+
+	u, k8sClient, _, _ := newTestUpdater(t, pod, newTestService())
+
+	targets := []Target{{IP: net.ParseIP("10.0.0.2"), Pod: pod, Ready: true}}
+
+	// Reconciling the same targets repeatedly must only create the Endpoints
+	// once.
+	for i := 0; i < 2; i++ {
+		err := u.UpdateEndpoints(context.Background(), "default", "master", targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var actions []string
+	for _, a := range k8sClient.Actions() {
+		actions = append(actions, a.GetVerb()+"/"+a.GetResource().Resource)
+	}
+	w := writes(actions, "endpoints")
+	if len(w) != 1 || w[0] != "create/endpoints" {
+		t.Fatalf("writes == %v, want [create/endpoints]", w)
+	}
+
+	endpoints, err := k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoints.Labels[LabelManagedBy] != ManagedBy {
+		t.Fatalf("label %s == %q, want %q", LabelManagedBy, endpoints.Labels[LabelManagedBy], ManagedBy)
+	}
+	if len(endpoints.OwnerReferences) != 1 || endpoints.OwnerReferences[0].UID != "master-uid" {
+		t.Fatalf("owner references == %v, want service", endpoints.OwnerReferences)
+	}
+	if len(endpoints.Subsets) != 1 {
+		t.Fatalf("subsets == %v, want 1", endpoints.Subsets)
+	}
+	subset := endpoints.Subsets[0]
+	if len(subset.Addresses) != 1 || subset.Addresses[0].IP != "10.0.0.2" || *subset.Addresses[0].NodeName != "node-1" {
+		t.Fatalf("addresses == %v, want 10.0.0.2 on node-1", subset.Addresses)
+	}
+	if len(subset.Ports) != 1 || subset.Ports[0].Port != 6443 || subset.Ports[0].Protocol != corev1.ProtocolTCP {
+		t.Fatalf("ports == %v, want 6443/TCP", subset.Ports)
+	}
+
+	// Withdrawing all targets empties the managed Endpoints.
+	err = u.UpdateEndpoints(context.Background(), "default", "master", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints, err = k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints.Subsets) != 0 {
+		t.Fatalf("subsets == %v, want none", endpoints.Subsets)
+	}
+}
+
+func Test_Updater_UpdateEndpoints_NotReady(t *testing.T) {
+	ready := newTestPod(nil)
+	ready.Name = "kvm-ready"
+	notReady := newTestPod(nil)
+	notReady.Name = "kvm-not-ready"
+
+	u, k8sClient, _, _ := newTestUpdater(t, ready, notReady, newTestService())
+
+	targets := []Target{
+		{IP: net.ParseIP("10.0.0.3"), Pod: notReady, Ready: false},
+		{IP: net.ParseIP("10.0.0.2"), Pod: ready, Ready: true},
+	}
+
+	err := u.UpdateEndpoints(context.Background(), "default", "master", targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints, err := k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints.Subsets) != 1 {
+		t.Fatalf("subsets == %v, want 1", endpoints.Subsets)
+	}
+	subset := endpoints.Subsets[0]
+	if len(subset.Addresses) != 1 || subset.Addresses[0].IP != "10.0.0.2" {
+		t.Fatalf("addresses == %v, want 10.0.0.2", subset.Addresses)
+	}
+	if len(subset.NotReadyAddresses) != 1 || subset.NotReadyAddresses[0].IP != "10.0.0.3" {
+		t.Fatalf("not ready addresses == %v, want 10.0.0.3", subset.NotReadyAddresses)
+	}
+
+	// A subset with not ready addresses only must be published as well.
+	err = u.UpdateEndpoints(context.Background(), "default", "master", targets[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints, err = k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints.Subsets) != 1 || len(endpoints.Subsets[0].Addresses) != 0 || len(endpoints.Subsets[0].NotReadyAddresses) != 1 {
+		t.Fatalf("subsets == %v, want 10.0.0.3 not ready", endpoints.Subsets)
+	}
+}
+
+func Test_Updater_UpdateEndpoints_Unmanaged(t *testing.T) {
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "master",
+			Namespace: "default",
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.0.9"}},
+			},
+		},
+	}
+
+	u, k8sClient, _, _ := newTestUpdater(t, newTestService(), endpoints)
+
+	err := u.UpdateEndpoints(context.Background(), "default", "master", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints, err = k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints.Subsets) != 1 {
+		t.Fatalf("subsets == %v, want unmanaged Endpoints to be left alone", endpoints.Subsets)
+	}
+}
+
+func Test_Updater_UpdatePodEndpoints(t *testing.T) {
+	u, k8sClient, _, _ := newTestUpdater(t, newTestPod(nil), newTestService())
+
+	err := u.UpdatePodEndpoints(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints, err := k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints.Subsets) != 1 || endpoints.Subsets[0].Addresses[0].IP != "10.0.0.2" || endpoints.Subsets[0].Addresses[0].TargetRef.Name != "kvm" {
+		t.Fatalf("subsets == %v, want 10.0.0.2 of kvm", endpoints.Subsets)
+	}
+
+	// VMs not being ready are published as not ready addresses.
+	err = u.UpdatePodEndpoints(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints, err = k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints.Subsets) != 1 || len(endpoints.Subsets[0].Addresses) != 0 || len(endpoints.Subsets[0].NotReadyAddresses) != 1 || endpoints.Subsets[0].NotReadyAddresses[0].IP != "10.0.0.2" {
+		t.Fatalf("subsets == %v, want 10.0.0.2 not ready", endpoints.Subsets)
+	}
+}
+
+func Test_Updater_UpdatePodEndpoints_OtherPods(t *testing.T) {
+	testCases := []struct {
+		name                      string
+		ip                        string
+		ready                     bool
+		expectedAddresses         []string
+		expectedNotReadyAddresses []string
+	}{
+		{
+			name:                      "case 0: address is added next to the one of the other pod",
+			ip:                        "10.0.0.2",
+			ready:                     true,
+			expectedAddresses:         []string{"10.0.0.2/kvm", "10.0.0.9/other"},
+			expectedNotReadyAddresses: nil,
+		},
+		{
+			name:                      "case 1: previous address of the pod is replaced",
+			ip:                        "10.0.0.3",
+			ready:                     true,
+			expectedAddresses:         []string{"10.0.0.3/kvm", "10.0.0.9/other"},
+			expectedNotReadyAddresses: nil,
+		},
+		{
+			name:                      "case 2: address of the pod is moved to the not ready addresses",
+			ip:                        "10.0.0.2",
+			ready:                     false,
+			expectedAddresses:         []string{"10.0.0.9/other"},
+			expectedNotReadyAddresses: []string{"10.0.0.2/kvm"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "master",
+					Namespace: "default",
+				},
+				Subsets: []corev1.EndpointSubset{
+					{
+						Addresses: []corev1.EndpointAddress{
+							{IP: "10.0.0.1", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "kvm"}},
+							{IP: "10.0.0.9", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "other"}},
+						},
+						Ports: []corev1.EndpointPort{{Name: "https", Port: 6443, Protocol: corev1.ProtocolTCP}},
+					},
+				},
+			}
+
+			u, k8sClient, _, _ := newTestUpdater(t, newTestPod(nil), newTestService(), endpoints)
+
+			err := u.UpdatePodEndpoints(context.Background(), "default", "master", "kvm", net.ParseIP(tc.ip), tc.ready)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			endpoints, err = k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var addresses []string
+			var notReadyAddresses []string
+			for _, s := range endpoints.Subsets {
+				for _, a := range s.Addresses {
+					addresses = append(addresses, a.IP+"/"+a.TargetRef.Name)
+				}
+				for _, a := range s.NotReadyAddresses {
+					notReadyAddresses = append(notReadyAddresses, a.IP+"/"+a.TargetRef.Name)
+				}
+			}
+			if !reflect.DeepEqual(addresses, tc.expectedAddresses) {
+				t.Fatalf("addresses == %v, want %v", addresses, tc.expectedAddresses)
+			}
+			if !reflect.DeepEqual(notReadyAddresses, tc.expectedNotReadyAddresses) {
+				t.Fatalf("not ready addresses == %v, want %v", notReadyAddresses, tc.expectedNotReadyAddresses)
+			}
+
+			// Withdrawing the pod leaves the address of the other pod alone.
+			err = u.RemovePodEndpoints(context.Background(), "default", "master", "kvm")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			endpoints, err = k8sClient.CoreV1().Endpoints("default").Get("master", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(endpoints.Subsets) != 1 || len(endpoints.Subsets[0].Addresses) != 1 || endpoints.Subsets[0].Addresses[0].IP != "10.0.0.9" {
+				t.Fatalf("subsets == %v, want 10.0.0.9 of other", endpoints.Subsets)
+			}
+		})
+	}
+}
+
+func Test_Updater_UpdatePodEndpoints_Conflict(t *testing.T) {
+	u, k8sClient, _, _ := newTestUpdater(t, newTestPod(nil), newTestService())
+
+	err := u.UpdatePodEndpoints(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else updated the Endpoints after they were read.
+	k8sClient.PrependReactor("update", "endpoints", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewConflict(corev1.Resource("endpoints"), "master", fmt.Errorf("modified"))
+	})
+
+	err = u.UpdatePodEndpoints(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.3"), true)
+	if !errors.IsConflict(microerror.Cause(err)) {
+		t.Fatalf("error == %#v, want conflict", err)
+	}
+}
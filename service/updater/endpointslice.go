@@ -0,0 +1,213 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"reflect"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
+)
+
+const (
+	// LabelEndpointSliceManagedBy is the well known label identifying the
+	// controller managing an EndpointSlice.
+	LabelEndpointSliceManagedBy = "endpointslice.kubernetes.io/managed-by"
+	// LabelServiceName is the well known label linking an EndpointSlice to its
+	// service.
+	LabelServiceName = "kubernetes.io/service-name"
+
+	endpointSliceManagedBy = "k8s-endpoint-updater.giantswarm.io"
+
+	labelZone       = "topology.kubernetes.io/zone"
+	labelZoneLegacy = "failure-domain.beta.kubernetes.io/zone"
+)
+
+var (
+	endpointSliceResource = schema.GroupVersionResource{
+		Group:    "discovery.k8s.io",
+		Version:  "v1",
+		Resource: "endpointslices",
+	}
+)
+
+// UpdateEndpointSlice reconciles the dedicated EndpointSlice of the target
+// identified by the KVM pod. The slice contains the given VM IP and its
+// readiness, the node name and zone of the KVM pod and the ports of the guest
+// cluster service.
+func (p *Updater) UpdateEndpointSlice(ctx context.Context, namespace, service string, podName string, podIP net.IP, ready bool) error {
+	kvmPod, err := p.getPod(ctx, namespace, podName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	svc, err := p.getService(ctx, namespace, service)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var zone string
+	if kvmPod.Spec.NodeName != "" {
+		var node *corev1.Node
+		err := call.Do(ctx, func() error {
+			var err error
+			node, err = p.k8sClient.CoreV1().Nodes().Get(kvmPod.Spec.NodeName, metav1.GetOptions{})
+			return err
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		zone = node.Labels[labelZone]
+		if zone == "" {
+			zone = node.Labels[labelZoneLegacy]
+		}
+	}
+
+	desired := newEndpointSlice(namespace, endpointSliceName(service, podName), service, kvmPod, podIP, ready, zone, p.endpointPorts(svc, kvmPod))
+
+	client := p.dynamicClient.Resource(endpointSliceResource).Namespace(namespace)
+
+	var current *unstructured.Unstructured
+	err = call.Do(ctx, func() error {
+		var err error
+		current, err = client.Get(desired.GetName(), metav1.GetOptions{})
+		return err
+	})
+	if errors.IsNotFound(err) {
+		err = call.Do(ctx, func() error {
+			_, err := client.Create(desired, metav1.CreateOptions{})
+			return err
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	if endpointSliceEqual(current, desired) {
+		return nil
+	}
+
+	desired.SetResourceVersion(current.GetResourceVersion())
+
+	err = call.Do(ctx, func() error {
+		_, err := client.Update(desired, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// RemoveEndpointSlice deletes the dedicated EndpointSlice of the target
+// identified by the KVM pod. It is a no-op when the slice does not exist.
+func (p *Updater) RemoveEndpointSlice(ctx context.Context, namespace, service string, podName string) error {
+	err := call.Do(ctx, func() error {
+		return p.dynamicClient.Resource(endpointSliceResource).Namespace(namespace).Delete(endpointSliceName(service, podName), &metav1.DeleteOptions{})
+	})
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// endpointSliceName returns the name of the dedicated EndpointSlice of the
+// given service and KVM pod. Names exceeding the maximum length of object names
+// are shortened, see shorten.
+func endpointSliceName(service, podName string) string {
+	return shorten(service+"-"+podName, maxNameLength)
+}
+
+func newEndpointSlice(namespace, name, service string, kvmPod *corev1.Pod, podIP net.IP, ready bool, zone string, ports []corev1.EndpointPort) *unstructured.Unstructured {
+	addressType := "IPv4"
+	if podIP.To4() == nil {
+		addressType = "IPv6"
+	}
+
+	terminating := kvmPod.DeletionTimestamp != nil
+
+	endpoint := map[string]interface{}{
+		"addresses": []interface{}{podIP.String()},
+		"conditions": map[string]interface{}{
+			"ready":       ready && !terminating,
+			"serving":     ready,
+			"terminating": terminating,
+		},
+		"targetRef": map[string]interface{}{
+			"kind":      "Pod",
+			"namespace": kvmPod.Namespace,
+			"name":      kvmPod.Name,
+			"uid":       string(kvmPod.UID),
+		},
+	}
+	if kvmPod.Spec.NodeName != "" {
+		endpoint["nodeName"] = kvmPod.Spec.NodeName
+	}
+	if zone != "" {
+		endpoint["zone"] = zone
+		endpoint["hints"] = map[string]interface{}{
+			"forZones": []interface{}{
+				map[string]interface{}{"name": zone},
+			},
+		}
+	}
+
+	var slicePorts []interface{}
+	for _, p := range ports {
+		port := map[string]interface{}{
+			"name": p.Name,
+			"port": int64(p.Port),
+		}
+		if p.Protocol != "" {
+			port["protocol"] = string(p.Protocol)
+		}
+
+		slicePorts = append(slicePorts, port)
+	}
+
+	labels := managedLabels(kvmPod.Name)
+	labels[LabelEndpointSliceManagedBy] = endpointSliceManagedBy
+	labels[LabelServiceName] = service
+
+	slice := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"addressType": addressType,
+			"endpoints":   []interface{}{endpoint},
+			"ports":       slicePorts,
+		},
+	}
+	slice.SetAPIVersion(endpointSliceResource.GroupVersion().String())
+	slice.SetKind("EndpointSlice")
+	slice.SetName(name)
+	slice.SetNamespace(namespace)
+	slice.SetAnnotations(managedAnnotations(kvmPod.Name))
+	slice.SetLabels(labels)
+	slice.SetOwnerReferences([]metav1.OwnerReference{podOwnerReference(kvmPod)})
+
+	return slice
+}
+
+func endpointSliceEqual(a, b *unstructured.Unstructured) bool {
+	for _, k := range []string{"addressType", "endpoints", "ports"} {
+		if !reflect.DeepEqual(a.Object[k], b.Object[k]) {
+			return false
+		}
+	}
+
+	return reflect.DeepEqual(a.GetAnnotations(), b.GetAnnotations()) && reflect.DeepEqual(a.GetLabels(), b.GetLabels()) && reflect.DeepEqual(a.GetOwnerReferences(), b.GetOwnerReferences())
+}
@@ -0,0 +1,289 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newTestNode(labels map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-1",
+			Labels: labels,
+		},
+	}
+}
+
+// dynamicWrites returns the creates, updates and deletes of the given resource
+// sent to the given client as "<verb>/<resource>".
+func dynamicWrites(c *dynamicfake.FakeDynamicClient, resource string) []string {
+	var w []string
+	for _, a := range c.Actions() {
+		if a.GetResource().Resource != resource {
+			continue
+		}
+		switch a.GetVerb() {
+		case "create", "update", "delete":
+			w = append(w, a.GetVerb()+"/"+resource)
+		}
+	}
+
+	return w
+}
+
+func Test_Updater_UpdateEndpointSlice(t *testing.T) {
+	testCases := []struct {
+		name                string
+		ip                  string
+		ready               bool
+		terminating         bool
+		nodeLabels          map[string]string
+		expectedAddressType string
+		expectedConditions  map[string]interface{}
+		expectedZone        string
+	}{
+		{
+			name:                "case 0: ready VM is ready and serving with zone and hints",
+			ip:                  "10.0.0.2",
+			ready:               true,
+			terminating:         false,
+			nodeLabels:          map[string]string{labelZone: "zone-a"},
+			expectedAddressType: "IPv4",
+			expectedConditions:  map[string]interface{}{"ready": true, "serving": true, "terminating": false},
+			expectedZone:        "zone-a",
+		},
+		{
+			name:                "case 1: not ready VM is neither ready nor serving",
+			ip:                  "10.0.0.2",
+			ready:               false,
+			terminating:         false,
+			nodeLabels:          map[string]string{labelZone: "zone-a"},
+			expectedAddressType: "IPv4",
+			expectedConditions:  map[string]interface{}{"ready": false, "serving": false, "terminating": false},
+			expectedZone:        "zone-a",
+		},
+		{
+			name:                "case 2: ready VM of terminating pod is serving but not ready",
+			ip:                  "10.0.0.2",
+			ready:               true,
+			terminating:         true,
+			nodeLabels:          map[string]string{labelZone: "zone-a"},
+			expectedAddressType: "IPv4",
+			expectedConditions:  map[string]interface{}{"ready": false, "serving": true, "terminating": true},
+			expectedZone:        "zone-a",
+		},
+		{
+			name:                "case 3: legacy zone label is used as fallback",
+			ip:                  "10.0.0.2",
+			ready:               true,
+			terminating:         false,
+			nodeLabels:          map[string]string{labelZoneLegacy: "zone-b"},
+			expectedAddressType: "IPv4",
+			expectedConditions:  map[string]interface{}{"ready": true, "serving": true, "terminating": false},
+			expectedZone:        "zone-b",
+		},
+		{
+			name:                "case 4: node without zone results in neither zone nor hints",
+			ip:                  "fd00::2",
+			ready:               true,
+			terminating:         false,
+			nodeLabels:          nil,
+			expectedAddressType: "IPv6",
+			expectedConditions:  map[string]interface{}{"ready": true, "serving": true, "terminating": false},
+			expectedZone:        "",
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			pod := newTestPod(nil)
+			pod.Spec.NodeName = "node-1"
+			if tc.terminating {
+				now := metav1.Now()
+				pod.DeletionTimestamp = &now
+			}
+
+			u, _, dynamicClient, _ := newTestUpdater(t, pod, newTestService(), newTestNode(tc.nodeLabels))
+
+			err := u.UpdateEndpointSlice(context.Background(), "default", "master", "kvm", net.ParseIP(tc.ip), tc.ready)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			slice, err := dynamicClient.Resource(endpointSliceResource).Namespace("default").Get(endpointSliceName("master", "kvm"), metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if slice.Object["addressType"] != tc.expectedAddressType {
+				t.Fatalf("address type == %v, want %q", slice.Object["addressType"], tc.expectedAddressType)
+			}
+			if slice.GetLabels()[LabelServiceName] != "master" || slice.GetLabels()[LabelEndpointSliceManagedBy] != endpointSliceManagedBy || slice.GetLabels()[LabelManagedBy] != ManagedBy {
+				t.Fatalf("labels == %v, want service name and managed by", slice.GetLabels())
+			}
+			if len(slice.GetOwnerReferences()) != 1 || slice.GetOwnerReferences()[0].UID != "kvm-uid" {
+				t.Fatalf("owner references == %v, want pod", slice.GetOwnerReferences())
+			}
+
+			endpoints, _, _ := unstructured.NestedSlice(slice.Object, "endpoints")
+			if len(endpoints) != 1 {
+				t.Fatalf("endpoints == %v, want 1", endpoints)
+			}
+			endpoint := endpoints[0].(map[string]interface{})
+
+			addresses, _, _ := unstructured.NestedStringSlice(endpoint, "addresses")
+			if !reflect.DeepEqual(addresses, []string{tc.ip}) {
+				t.Fatalf("addresses == %v, want [%s]", addresses, tc.ip)
+			}
+			conditions, _, _ := unstructured.NestedMap(endpoint, "conditions")
+			if !reflect.DeepEqual(conditions, tc.expectedConditions) {
+				t.Fatalf("conditions == %v, want %v", conditions, tc.expectedConditions)
+			}
+			nodeName, _, _ := unstructured.NestedString(endpoint, "nodeName")
+			if nodeName != "node-1" {
+				t.Fatalf("node name == %q, want %q", nodeName, "node-1")
+			}
+			zone, _, _ := unstructured.NestedString(endpoint, "zone")
+			if zone != tc.expectedZone {
+				t.Fatalf("zone == %q, want %q", zone, tc.expectedZone)
+			}
+
+			hints, found, _ := unstructured.NestedSlice(endpoint, "hints", "forZones")
+			if tc.expectedZone == "" && found {
+				t.Fatalf("hints == %v, want none", hints)
+			}
+			if tc.expectedZone != "" && (len(hints) != 1 || hints[0].(map[string]interface{})["name"] != tc.expectedZone) {
+				t.Fatalf("hints == %v, want zone %q", hints, tc.expectedZone)
+			}
+
+			ports, _, _ := unstructured.NestedSlice(slice.Object, "ports")
+			if len(ports) != 1 || ports[0].(map[string]interface{})["port"] != int64(6443) || ports[0].(map[string]interface{})["name"] != "https" {
+				t.Fatalf("ports == %v, want https 6443", ports)
+			}
+		})
+	}
+}
+
+func Test_Updater_UpdateEndpointSlice_Idempotent(t *testing.T) {
+	pod := newTestPod(nil)
+	pod.Spec.NodeName = "node-1"
+
+	u, _, dynamicClient, _ := newTestUpdater(t, pod, newTestService(), newTestNode(nil))
+
+	// Reconciling the same VM IP repeatedly must only create the slice once.
+	for i := 0; i < 2; i++ {
+		err := u.UpdateEndpointSlice(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := dynamicWrites(dynamicClient, "endpointslices")
+	if !reflect.DeepEqual(w, []string{"create/endpointslices"}) {
+		t.Fatalf("writes == %v, want [create/endpointslices]", w)
+	}
+
+	// A changed readiness updates the slice.
+	err := u.UpdateEndpointSlice(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = dynamicWrites(dynamicClient, "endpointslices")
+	if !reflect.DeepEqual(w, []string{"create/endpointslices", "update/endpointslices"}) {
+		t.Fatalf("writes == %v, want [create/endpointslices update/endpointslices]", w)
+	}
+}
+
+func Test_Updater_RemoveEndpointSlice(t *testing.T) {
+	pod := newTestPod(nil)
+	pod.Spec.NodeName = "node-1"
+
+	u, _, dynamicClient, _ := newTestUpdater(t, pod, newTestService(), newTestNode(nil))
+
+	err := u.UpdateEndpointSlice(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the slice twice must succeed, as a missing slice is a no-op.
+	for i := 0; i < 2; i++ {
+		err = u.RemoveEndpointSlice(context.Background(), "default", "master", "kvm")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = dynamicClient.Resource(endpointSliceResource).Namespace("default").Get(endpointSliceName("master", "kvm"), metav1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("error == %#v, want not found", err)
+	}
+}
+
+func Test_Updater_endpointSliceName(t *testing.T) {
+	testCases := []struct {
+		name           string
+		service        string
+		podName        string
+		expectedPrefix string
+		expectedLength int
+	}{
+		{
+			name:           "case 0: short name is used as is",
+			service:        "master",
+			podName:        "kvm",
+			expectedPrefix: "master-kvm",
+			expectedLength: 10,
+		},
+		{
+			name:           "case 1: long name is truncated and hashed",
+			service:        "master",
+			podName:        strings.Repeat("a", 300),
+			expectedPrefix: "master-" + strings.Repeat("a", 235) + "-",
+			expectedLength: 253,
+		},
+		{
+			name:           "case 2: trailing dashes and dots of the truncated name are removed",
+			service:        "master",
+			podName:        strings.Repeat("a", 233) + ".-" + strings.Repeat("b", 100),
+			expectedPrefix: "master-" + strings.Repeat("a", 233) + "-",
+			expectedLength: 251,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			name := endpointSliceName(tc.service, tc.podName)
+			if !strings.HasPrefix(name, tc.expectedPrefix) {
+				t.Fatalf("name == %q, want prefix %q", name, tc.expectedPrefix)
+			}
+			if len(name) != tc.expectedLength {
+				t.Fatalf("name has %d characters, want %d", len(name), tc.expectedLength)
+			}
+		})
+	}
+}
+
+func Test_Updater_endpointSliceName_Unique(t *testing.T) {
+	prefix := strings.Repeat("a", 300)
+
+	a := endpointSliceName("master", prefix+"-1")
+	b := endpointSliceName("master", prefix+"-2")
+	if a == b {
+		t.Fatalf("names of different pods must differ, got %q", a)
+	}
+}
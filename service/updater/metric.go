@@ -0,0 +1,36 @@
+package updater
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	prometheusNamespace = "k8s_endpoint_updater"
+	prometheusSubsystem = "updater"
+)
+
+var (
+	patchTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "patch_total",
+			Help:      "Number of Kubernetes patch attempts partitioned by result.",
+		},
+		[]string{"result"},
+	)
+	patchDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: prometheusNamespace,
+			Subsystem: prometheusSubsystem,
+			Name:      "patch_duration_seconds",
+			Help:      "Latency of Kubernetes patch attempts in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(patchTotal)
+	prometheus.MustRegister(patchDuration)
+}
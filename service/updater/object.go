@@ -0,0 +1,193 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
+)
+
+const (
+	fieldPrefixAnnotation = "metadata.annotations."
+	fieldPrefixLabel      = "metadata.labels."
+	fieldPrefixSpec       = "spec."
+	fieldPrefixStatus     = "status."
+)
+
+// ObjectTarget is an arbitrary object the VM IP is published to by
+// PublishObject, e.g. a custom resource consuming the IP.
+type ObjectTarget struct {
+	// Field is the field of the object holding the IP. See ParseObjectField.
+	Field string
+	// Name is the name of the object.
+	Name string
+	// Namespace is the namespace of the object. It is empty for cluster scoped
+	// objects.
+	Namespace string
+	// Resource is the resource of the object.
+	Resource schema.GroupVersionResource
+}
+
+// ParseObjectField parses the field of an object target and returns its path.
+// Fields are given as one of
+//
+//	metadata.annotations.<key>
+//	metadata.labels.<key>
+//	spec.<field>[.<field>...]
+//	status.<field>[.<field>...]
+//
+// Annotation and label keys are taken as is, so that they may contain dots.
+// Status fields are written via the status subresource.
+func ParseObjectField(s string) ([]string, error) {
+	switch {
+	case strings.HasPrefix(s, fieldPrefixAnnotation) && len(s) > len(fieldPrefixAnnotation):
+		return []string{"metadata", "annotations", strings.TrimPrefix(s, fieldPrefixAnnotation)}, nil
+	case strings.HasPrefix(s, fieldPrefixLabel) && len(s) > len(fieldPrefixLabel):
+		return []string{"metadata", "labels", strings.TrimPrefix(s, fieldPrefixLabel)}, nil
+	case strings.HasPrefix(s, fieldPrefixSpec), strings.HasPrefix(s, fieldPrefixStatus):
+		path := strings.Split(s, ".")
+		for _, p := range path {
+			if p == "" {
+				return nil, microerror.Maskf(invalidConfigError, "field %q must not contain empty segments", s)
+			}
+		}
+
+		return path, nil
+	}
+
+	return nil, microerror.Maskf(invalidConfigError, "field %q must start with %q, %q, %q or %q", s, fieldPrefixAnnotation, fieldPrefixLabel, fieldPrefixSpec, fieldPrefixStatus)
+}
+
+// PublishObject writes the given VM IP into the field of the target object.
+// The object has to exist already. It is a no-op when the field holds the IP
+// already.
+func (p *Updater) PublishObject(ctx context.Context, target ObjectTarget, podIP net.IP) error {
+	path, err := ParseObjectField(target.Field)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	value := podIP.String()
+	if path[1] == "labels" {
+		// IPv6 addresses contain colons, which are not allowed in label
+		// values.
+		errs := validation.IsValidLabelValue(value)
+		if len(errs) != 0 {
+			return microerror.Maskf(executionFailedError, "IP %s must be a valid label value: %s", value, strings.Join(errs, ", "))
+		}
+	}
+
+	obj, err := p.getObject(ctx, target)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	current, _, err := unstructured.NestedFieldNoCopy(obj.Object, path...)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if current == value {
+		return nil
+	}
+
+	err = p.patchObject(ctx, target, path, value)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_ = p.logger.Log("level", "debug", "message", "published IP to object", "resource", target.Resource.GroupResource().String(), "namespace", target.Namespace, "name", target.Name, "field", target.Field, "ip", value)
+
+	return nil
+}
+
+// WithdrawObject removes the field written by PublishObject from the target
+// object. It is a no-op when the object or the field does not exist.
+func (p *Updater) WithdrawObject(ctx context.Context, target ObjectTarget) error {
+	path, err := ParseObjectField(target.Field)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	obj, err := p.getObject(ctx, target)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	_, found, err := unstructured.NestedFieldNoCopy(obj.Object, path...)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+	if !found {
+		return nil
+	}
+
+	err = p.patchObject(ctx, target, path, nil)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// getObject returns the error of the API call unmasked, so that it can be
+// asserted by the caller.
+func (p *Updater) getObject(ctx context.Context, target ObjectTarget) (*unstructured.Unstructured, error) {
+	var obj *unstructured.Unstructured
+	err := call.Do(ctx, func() error {
+		var err error
+		obj, err = p.dynamicClient.Resource(target.Resource).Namespace(target.Namespace).Get(target.Name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// patchObject sets the field at path to value using a JSON merge patch. A nil
+// value removes the field.
+func (p *Updater) patchObject(ctx context.Context, target ObjectTarget, path []string, value interface{}) error {
+	patch := map[string]interface{}{}
+	err := unstructured.SetNestedField(patch, value, path...)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	var subresources []string
+	if path[0] == "status" {
+		subresources = append(subresources, "status")
+	}
+
+	start := time.Now()
+	err = call.Do(ctx, func() error {
+		_, err := p.dynamicClient.Resource(target.Resource).Namespace(target.Namespace).Patch(target.Name, types.MergePatchType, data, metav1.PatchOptions{}, subresources...)
+		return err
+	})
+	patchDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		patchTotal.WithLabelValues("failure").Inc()
+		return microerror.Mask(err)
+	}
+	patchTotal.WithLabelValues("success").Inc()
+
+	return nil
+}
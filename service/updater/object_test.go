@@ -0,0 +1,237 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var testResource = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "vms"}
+
+func newTestObject() *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "VM",
+			"metadata": map[string]interface{}{
+				"name":      "vm0",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"cpus": int64(2),
+			},
+		},
+	}
+}
+
+// objectPatches returns the patches sent to the given client together with
+// their subresource.
+func objectPatches(c *dynamicfake.FakeDynamicClient) []string {
+	var p []string
+	for _, a := range c.Actions() {
+		if patch, ok := a.(k8stesting.PatchAction); ok {
+			p = append(p, patch.GetSubresource()+string(patch.GetPatch()))
+		}
+	}
+
+	return p
+}
+
+func Test_ParseObjectField(t *testing.T) {
+	testCases := []struct {
+		field        string
+		expectedPath []string
+		errorMatcher func(error) bool
+	}{
+		{
+			field:        "metadata.annotations.example.com/ip",
+			expectedPath: []string{"metadata", "annotations", "example.com/ip"},
+		},
+		{
+			field:        "metadata.labels.ip",
+			expectedPath: []string{"metadata", "labels", "ip"},
+		},
+		{
+			field:        "spec.network.ip",
+			expectedPath: []string{"spec", "network", "ip"},
+		},
+		{
+			field:        "status.ip",
+			expectedPath: []string{"status", "ip"},
+		},
+		{
+			field:        "metadata.annotations.",
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			field:        "spec..ip",
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			field:        "metadata.name",
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			field:        "",
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			path, err := ParseObjectField(tc.field)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if !reflect.DeepEqual(path, tc.expectedPath) {
+				t.Fatalf("path == %#v, want %#v", path, tc.expectedPath)
+			}
+		})
+	}
+}
+
+func Test_Updater_PublishObject(t *testing.T) {
+	testCases := []struct {
+		name            string
+		field           string
+		ip              net.IP
+		expectedPatches []string
+		errorMatcher    func(error) bool
+	}{
+		{
+			name:            "case 0: annotation",
+			field:           "metadata.annotations.example.com/ip",
+			ip:              net.ParseIP("10.1.2.3"),
+			expectedPatches: []string{`{"metadata":{"annotations":{"example.com/ip":"10.1.2.3"}}}`},
+		},
+		{
+			name:            "case 1: label",
+			field:           "metadata.labels.ip",
+			ip:              net.ParseIP("10.1.2.3"),
+			expectedPatches: []string{`{"metadata":{"labels":{"ip":"10.1.2.3"}}}`},
+		},
+		{
+			name:         "case 2: IPv6 label",
+			field:        "metadata.labels.ip",
+			ip:           net.ParseIP("fd00::3"),
+			errorMatcher: IsExecutionFailed,
+		},
+		{
+			name:            "case 3: spec field",
+			field:           "spec.network.ip",
+			ip:              net.ParseIP("fd00::3"),
+			expectedPatches: []string{`{"spec":{"network":{"ip":"fd00::3"}}}`},
+		},
+		{
+			name:            "case 4: status field",
+			field:           "status.ip",
+			ip:              net.ParseIP("10.1.2.3"),
+			expectedPatches: []string{`status{"status":{"ip":"10.1.2.3"}}`},
+		},
+		{
+			name:         "case 5: invalid field",
+			field:        "metadata.name",
+			ip:           net.ParseIP("10.1.2.3"),
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			u, _, c, _ := newTestUpdater(t, newTestObject())
+
+			target := ObjectTarget{Field: tc.field, Name: "vm0", Namespace: "default", Resource: testResource}
+
+			err := u.PublishObject(context.Background(), target, tc.ip)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if p := objectPatches(c); !reflect.DeepEqual(p, tc.expectedPatches) {
+				t.Fatalf("patches == %q, want %q", p, tc.expectedPatches)
+			}
+		})
+	}
+}
+
+func Test_Updater_PublishObject_Idempotent(t *testing.T) {
+	u, _, c, _ := newTestUpdater(t, newTestObject())
+
+	target := ObjectTarget{Field: "spec.network.ip", Name: "vm0", Namespace: "default", Resource: testResource}
+
+	for i := 0; i < 2; i++ {
+		err := u.PublishObject(context.Background(), target, net.ParseIP("10.1.2.3"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if p := objectPatches(c); len(p) != 1 {
+		t.Fatalf("patches == %q, want exactly one", p)
+	}
+
+	obj, err := c.Resource(testResource).Namespace("default").Get("vm0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip, _, _ := unstructured.NestedString(obj.Object, "spec", "network", "ip")
+	cpus, _, _ := unstructured.NestedInt64(obj.Object, "spec", "cpus")
+	if ip != "10.1.2.3" || cpus != 2 {
+		t.Fatalf("spec == %#v, want ip and untouched cpus", obj.Object["spec"])
+	}
+}
+
+func Test_Updater_WithdrawObject(t *testing.T) {
+	obj := newTestObject()
+	obj.SetAnnotations(map[string]string{"example.com/ip": "10.1.2.3", "other": "value"})
+
+	u, _, c, _ := newTestUpdater(t, obj)
+
+	target := ObjectTarget{Field: "metadata.annotations.example.com/ip", Name: "vm0", Namespace: "default", Resource: testResource}
+
+	for i := 0; i < 2; i++ {
+		err := u.WithdrawObject(context.Background(), target)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectedPatches := []string{`{"metadata":{"annotations":{"example.com/ip":null}}}`}
+	if p := objectPatches(c); !reflect.DeepEqual(p, expectedPatches) {
+		t.Fatalf("patches == %q, want %q", p, expectedPatches)
+	}
+
+	// Missing objects are fine.
+	target.Name = "vm1"
+	err := u.WithdrawObject(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
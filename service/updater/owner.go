@@ -0,0 +1,28 @@
+package updater
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podOwnerReference makes objects belonging to a single KVM pod garbage
+// collected together with the pod.
+func podOwnerReference(pod *corev1.Pod) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       pod.Name,
+		UID:        pod.UID,
+	}
+}
+
+// serviceOwnerReference makes objects belonging to a guest cluster service
+// garbage collected together with the service.
+func serviceOwnerReference(svc *corev1.Service) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Name:       svc.Name,
+		UID:        svc.UID,
+	}
+}
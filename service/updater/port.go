@@ -0,0 +1,102 @@
+package updater
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ParsePortOverrides parses port overrides given as "<service port>=<VM port>"
+// pairs, where the service port is identified by its name or number, e.g.
+// "https=6443" or "443=6443".
+func ParsePortOverrides(pairs []string) (map[string]int32, error) {
+	overrides := map[string]int32{}
+
+	for _, pair := range pairs {
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return nil, microerror.Maskf(invalidConfigError, "port override %q must have the format <service port>=<VM port>", pair)
+		}
+
+		port, err := strconv.ParseInt(split[1], 10, 32)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, microerror.Maskf(invalidConfigError, "port override %q must map to a port between 1 and 65535", pair)
+		}
+
+		overrides[split[0]] = int32(port)
+	}
+
+	return overrides, nil
+}
+
+// endpointPorts maps the ports of the given service onto the VM of the given
+// KVM pod. The VM port of each service port is determined as follows.
+//
+//   - A configured override for the service port name or number wins.
+//   - Numeric target ports are used as they are.
+//   - Named target ports are resolved against the container ports of the
+//     KVM pod.
+//   - Everything else falls back to the service port.
+func (p *Updater) endpointPorts(svc *corev1.Service, kvmPod *corev1.Pod) []corev1.EndpointPort {
+	var ports []corev1.EndpointPort
+
+	for _, sp := range svc.Spec.Ports {
+		port := sp.Port
+
+		if o, ok := p.portOverride(sp); ok {
+			port = o
+		} else if sp.TargetPort.IntVal != 0 {
+			port = sp.TargetPort.IntVal
+		} else if sp.TargetPort.StrVal != "" {
+			if cp, ok := containerPort(kvmPod, sp.TargetPort.StrVal); ok {
+				port = cp
+			}
+		}
+
+		protocol := sp.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+
+		ports = append(ports, corev1.EndpointPort{
+			Name:     sp.Name,
+			Port:     port,
+			Protocol: protocol,
+		})
+	}
+
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Name < ports[j].Name })
+
+	return ports
+}
+
+func (p *Updater) portOverride(sp corev1.ServicePort) (int32, bool) {
+	if sp.Name != "" {
+		if o, ok := p.portOverrides[sp.Name]; ok {
+			return o, true
+		}
+	}
+
+	o, ok := p.portOverrides[strconv.Itoa(int(sp.Port))]
+
+	return o, ok
+}
+
+func containerPort(pod *corev1.Pod, name string) (int32, bool) {
+	if pod == nil {
+		return 0, false
+	}
+
+	for _, c := range pod.Spec.Containers {
+		for _, cp := range c.Ports {
+			if cp.Name == name {
+				return cp.ContainerPort, true
+			}
+		}
+	}
+
+	return 0, false
+}
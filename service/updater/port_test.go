@@ -0,0 +1,61 @@
+package updater
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_ParsePortOverrides(t *testing.T) {
+	testCases := []struct {
+		pairs        []string
+		expected     map[string]int32
+		errorMatcher func(error) bool
+	}{
+		{
+			pairs:    nil,
+			expected: map[string]int32{},
+		},
+		{
+			pairs:    []string{"https=6443", "80=8080"},
+			expected: map[string]int32{"https": 6443, "80": 8080},
+		},
+		{
+			pairs:        []string{"https"},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			pairs:        []string{"=6443"},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			pairs:        []string{"https=0"},
+			errorMatcher: IsInvalidConfig,
+		},
+		{
+			pairs:        []string{"https=65536"},
+			errorMatcher: IsInvalidConfig,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			overrides, err := ParsePortOverrides(tc.pairs)
+
+			switch {
+			case err == nil && tc.errorMatcher == nil:
+				// correct; carry on
+			case err != nil && tc.errorMatcher == nil:
+				t.Fatalf("error == %#v, want nil", err)
+			case err == nil && tc.errorMatcher != nil:
+				t.Fatalf("error == nil, want non-nil")
+			case !tc.errorMatcher(err):
+				t.Fatalf("error == %#v, want matching", err)
+			}
+
+			if tc.errorMatcher == nil && !reflect.DeepEqual(overrides, tc.expected) {
+				t.Fatalf("overrides == %v, want %v", overrides, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/giantswarm/microerror"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+)
+
+const (
+	// StatusKindConfigMap writes the status into a ConfigMap named after the
+	// KVM pod.
+	StatusKindConfigMap = "configmap"
+	// StatusKindEndpointBinding writes the status into the status subresource
+	// of an EndpointBinding custom resource named after the KVM pod.
+	StatusKindEndpointBinding = "endpointbinding"
+)
+
+const (
+	statusConfigMapSuffix = "-endpoint-status"
+)
+
+var (
+	endpointBindingResource = schema.GroupVersionResource{
+		Group:    "endpoint.kvm.giantswarm.io",
+		Version:  "v1alpha1",
+		Resource: "endpointbindings",
+	}
+)
+
+// Status is the structured status of a single target written by
+// UpdateStatus.
+type Status struct {
+	// Generation is incremented with every reconciliation.
+	Generation int64
+	// IP is the currently published IP. It is nil as long as nothing was
+	// published yet.
+	IP net.IP
+	// LastLookupError is the error message of the last failed provider lookup,
+	// if any.
+	LastLookupError string
+	// Provider is the kind of the provider the IP was looked up with.
+	Provider string
+	// Publishers are the statuses of the publishers the IP is published with,
+	// by publisher kind.
+	Publishers map[string]publisher.Status
+}
+
+// UpdateStatus writes the given status of the target identified by the KVM pod
+// into the configured status object. The status object is owned by the KVM
+// pod. It is a no-op when no status kind is configured.
+func (p *Updater) UpdateStatus(ctx context.Context, namespace, service string, podName string, status Status) error {
+	if p.statusKind == "" {
+		return nil
+	}
+
+	kvmPod, err := p.getPod(ctx, namespace, podName)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	switch p.statusKind {
+	case StatusKindConfigMap:
+		err := p.updateStatusConfigMap(ctx, service, kvmPod, status)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	case StatusKindEndpointBinding:
+		err := p.updateStatusEndpointBinding(ctx, service, kvmPod, status)
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Updater) updateStatusConfigMap(ctx context.Context, service string, kvmPod *corev1.Pod, status Status) error {
+	namespace := kvmPod.Namespace
+
+	publishers, err := json.Marshal(publisherStatuses(status))
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	data := map[string]string{
+		"generation":      strconv.FormatInt(status.Generation, 10),
+		"ip":              ipString(status.IP),
+		"lastLookupError": status.LastLookupError,
+		"lastUpdateTime":  time.Now().UTC().Format(time.RFC3339),
+		"provider":        status.Provider,
+		"publishers":      string(publishers),
+		"service":         service,
+	}
+
+	name := statusConfigMapName(kvmPod.Name)
+
+	var configMap *corev1.ConfigMap
+	err = call.Do(ctx, func() error {
+		var err error
+		configMap, err = p.k8sClient.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+		return err
+	})
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       namespace,
+				Annotations:     managedAnnotations(kvmPod.Name),
+				Labels:          managedLabels(kvmPod.Name),
+				OwnerReferences: []metav1.OwnerReference{podOwnerReference(kvmPod)},
+			},
+			Data: data,
+		}
+
+		err = call.Do(ctx, func() error {
+			_, err := p.k8sClient.CoreV1().ConfigMaps(namespace).Create(configMap)
+			return err
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		return nil
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	configMap.Data = data
+	configMap.OwnerReferences = []metav1.OwnerReference{podOwnerReference(kvmPod)}
+
+	err = call.Do(ctx, func() error {
+		_, err := p.k8sClient.CoreV1().ConfigMaps(namespace).Update(configMap)
+		return err
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+func (p *Updater) updateStatusEndpointBinding(ctx context.Context, service string, kvmPod *corev1.Pod, status Status) error {
+	namespace := kvmPod.Namespace
+	podName := kvmPod.Name
+
+	client := p.dynamicClient.Resource(endpointBindingResource).Namespace(namespace)
+
+	var binding *unstructured.Unstructured
+	err := call.Do(ctx, func() error {
+		var err error
+		binding, err = client.Get(podName, metav1.GetOptions{})
+		return err
+	})
+	if errors.IsNotFound(err) {
+		binding = &unstructured.Unstructured{}
+		binding.SetAPIVersion(endpointBindingResource.GroupVersion().String())
+		binding.SetKind("EndpointBinding")
+		binding.SetName(podName)
+		binding.SetNamespace(namespace)
+		binding.SetAnnotations(managedAnnotations(podName))
+		binding.SetLabels(managedLabels(podName))
+		binding.SetOwnerReferences([]metav1.OwnerReference{podOwnerReference(kvmPod)})
+
+		err = unstructured.SetNestedStringMap(binding.Object, map[string]string{"pod": podName, "service": service}, "spec")
+		if err != nil {
+			return microerror.Mask(err)
+		}
+
+		err = call.Do(ctx, func() error {
+			var err error
+			binding, err = client.Create(binding, metav1.CreateOptions{})
+			return err
+		})
+		if err != nil {
+			return microerror.Mask(err)
+		}
+	} else if err != nil {
+		return microerror.Mask(err)
+	}
+
+	s := map[string]interface{}{
+		"generation":      status.Generation,
+		"ip":              ipString(status.IP),
+		"lastLookupError": status.LastLookupError,
+		"lastUpdateTime":  time.Now().UTC().Format(time.RFC3339),
+		"provider":        status.Provider,
+		"publishers":      publisherStatuses(status),
+	}
+
+	err = unstructured.SetNestedField(binding.Object, s, "status")
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	err = call.Do(ctx, func() error {
+		_, err := client.UpdateStatus(binding, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	return nil
+}
+
+// statusConfigMapName returns the name of the status ConfigMap of the given
+// KVM pod. Names exceeding the maximum length of object names are shortened,
+// see shorten.
+func statusConfigMapName(podName string) string {
+	return shorten(podName+statusConfigMapSuffix, maxNameLength)
+}
+
+// publisherStatuses returns the publisher statuses in a form suitable for both
+// JSON and unstructured objects.
+func publisherStatuses(status Status) map[string]interface{} {
+	statuses := map[string]interface{}{}
+	for kind, s := range status.Publishers {
+		m := map[string]interface{}{
+			"ip":        ipString(s.IP),
+			"lastError": s.LastError,
+			"ready":     s.Ready,
+		}
+		// The zero time is left out as it is not a valid date-time.
+		if !s.LastUpdateTime.IsZero() {
+			m["lastUpdateTime"] = s.LastUpdateTime.UTC().Format(time.RFC3339)
+		}
+		statuses[kind] = m
+	}
+
+	return statuses
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	return ip.String()
+}
@@ -0,0 +1,272 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/publisher"
+)
+
+func newTestStatus(generation int64) Status {
+	return Status{
+		Generation:      generation,
+		IP:              net.ParseIP("10.0.0.2"),
+		LastLookupError: "",
+		Provider:        "bridge",
+		Publishers: map[string]publisher.Status{
+			"annotation": {
+				IP:             net.ParseIP("10.0.0.2"),
+				LastUpdateTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+				Ready:          true,
+			},
+			"dns": {
+				LastError: "timeout",
+			},
+		},
+	}
+}
+
+func Test_Updater_UpdateStatus(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusKind string
+		generation int64
+	}{
+		{
+			name:       "case 0: status ConfigMap is created",
+			statusKind: StatusKindConfigMap,
+			generation: 1,
+		},
+		{
+			name:       "case 1: status ConfigMap is updated",
+			statusKind: StatusKindConfigMap,
+			generation: 2,
+		},
+		{
+			name:       "case 2: EndpointBinding status is created",
+			statusKind: StatusKindEndpointBinding,
+			generation: 1,
+		},
+		{
+			name:       "case 3: EndpointBinding status is updated",
+			statusKind: StatusKindEndpointBinding,
+			generation: 2,
+		},
+	}
+
+	expectedPublishers := map[string]interface{}{
+		"annotation": map[string]interface{}{"ip": "10.0.0.2", "lastError": "", "lastUpdateTime": "2020-01-02T03:04:05Z", "ready": true},
+		"dns":        map[string]interface{}{"ip": "", "lastError": "timeout", "ready": false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			u, k8sClient, dynamicClient, _ := newTestUpdater(t, newTestPod(nil))
+			u.statusKind = tc.statusKind
+
+			// Every generation up to the one of the test case is written, so
+			// that the last one updates an existing object.
+			for g := int64(1); g <= tc.generation; g++ {
+				err := u.UpdateStatus(context.Background(), "default", "master", "kvm", newTestStatus(g))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var meta metav1.Object
+			var status map[string]interface{}
+			switch tc.statusKind {
+			case StatusKindConfigMap:
+				configMap, err := k8sClient.CoreV1().ConfigMaps("default").Get(statusConfigMapName("kvm"), metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				meta = configMap
+
+				var publishers map[string]interface{}
+				err = json.Unmarshal([]byte(configMap.Data["publishers"]), &publishers)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				status = map[string]interface{}{
+					"generation": configMap.Data["generation"],
+					"ip":         configMap.Data["ip"],
+					"provider":   configMap.Data["provider"],
+					"publishers": publishers,
+				}
+				if configMap.Data["service"] != "master" {
+					t.Fatalf("service == %q, want %q", configMap.Data["service"], "master")
+				}
+			case StatusKindEndpointBinding:
+				binding, err := dynamicClient.Resource(endpointBindingResource).Namespace("default").Get("kvm", metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				meta = binding
+
+				status, _, _ = unstructured.NestedMap(binding.Object, "status")
+				status["generation"] = strconv.FormatInt(status["generation"].(int64), 10)
+
+				spec, _, _ := unstructured.NestedStringMap(binding.Object, "spec")
+				if !reflect.DeepEqual(spec, map[string]string{"pod": "kvm", "service": "master"}) {
+					t.Fatalf("spec == %v, want pod and service", spec)
+				}
+			}
+
+			if status["generation"] != strconv.FormatInt(tc.generation, 10) {
+				t.Fatalf("generation == %v, want %d", status["generation"], tc.generation)
+			}
+			if status["ip"] != "10.0.0.2" {
+				t.Fatalf("ip == %v, want %q", status["ip"], "10.0.0.2")
+			}
+			if status["provider"] != "bridge" {
+				t.Fatalf("provider == %v, want %q", status["provider"], "bridge")
+			}
+			if !reflect.DeepEqual(status["publishers"], expectedPublishers) {
+				t.Fatalf("publishers == %v, want %v", status["publishers"], expectedPublishers)
+			}
+
+			if meta.GetLabels()[LabelManagedBy] != ManagedBy || meta.GetAnnotations()[AnnotationPod] != "kvm" {
+				t.Fatalf("labels == %v, annotations == %v, want managed by and pod", meta.GetLabels(), meta.GetAnnotations())
+			}
+			if len(meta.GetOwnerReferences()) != 1 || meta.GetOwnerReferences()[0].UID != "kvm-uid" {
+				t.Fatalf("owner references == %v, want pod", meta.GetOwnerReferences())
+			}
+		})
+	}
+}
+
+func Test_Updater_UpdateStatus_Disabled(t *testing.T) {
+	u, k8sClient, dynamicClient, _ := newTestUpdater(t, newTestPod(nil))
+
+	err := u.UpdateStatus(context.Background(), "default", "master", "kvm", newTestStatus(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(k8sClient.Actions()) != 0 || len(dynamicClient.Actions()) != 0 {
+		t.Fatalf("actions == %v %v, want none", k8sClient.Actions(), dynamicClient.Actions())
+	}
+}
+
+func Test_Updater_UpdateStatus_LongName(t *testing.T) {
+	pod := newTestPod(nil)
+	pod.Name = strings.Repeat("a", 250)
+
+	u, k8sClient, _, _ := newTestUpdater(t, pod)
+	u.statusKind = StatusKindConfigMap
+
+	// The status is written twice, so that the second write finds the
+	// ConfigMap created by the first one.
+	for g := int64(1); g <= 2; g++ {
+		err := u.UpdateStatus(context.Background(), "default", "master", pod.Name, newTestStatus(g))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	configMaps, err := k8sClient.CoreV1().ConfigMaps("default").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configMaps.Items) != 1 {
+		t.Fatalf("config maps == %d, want 1", len(configMaps.Items))
+	}
+
+	name := configMaps.Items[0].Name
+	if len(name) > maxNameLength {
+		t.Fatalf("name has %d characters, want at most %d", len(name), maxNameLength)
+	}
+	if !strings.HasPrefix(name, strings.Repeat("a", 200)) {
+		t.Fatalf("name == %q, want prefix of pod name", name)
+	}
+	if configMaps.Items[0].Data["generation"] != "2" {
+		t.Fatalf("generation == %q, want %q", configMaps.Items[0].Data["generation"], "2")
+	}
+}
+
+// Test_Updater_UpdateStatus_Schema ensures the EndpointBinding status written
+// by UpdateStatus survives the pruning and validation of the API server
+// against the schema of the EndpointBinding CRD.
+func Test_Updater_UpdateStatus_Schema(t *testing.T) {
+	b, err := ioutil.ReadFile("../../docs/crd/endpoint.kvm.giantswarm.io_endpointbindings.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var crd apiextensionsv1.CustomResourceDefinition
+	err = yaml.Unmarshal(b, &crd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var props apiextensions.JSONSchemaProps
+	err = apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(crd.Spec.Versions[0].Schema.OpenAPIV3Schema, &props, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	structural, err := structuralschema.NewStructural(&props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator, _, err := validation.NewSchemaValidator(&apiextensions.CustomResourceValidation{OpenAPIV3Schema: &props})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _, dynamicClient, _ := newTestUpdater(t, newTestPod(nil))
+	u.statusKind = StatusKindEndpointBinding
+
+	err = u.UpdateStatus(context.Background(), "default", "master", "kvm", newTestStatus(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binding, err := dynamicClient.Resource(endpointBindingResource).Namespace("default").Get("kvm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The API server validates the decoded request body.
+	b, err = binding.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]interface{}
+	err = json.Unmarshal(b, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := validation.ValidateCustomResource(nil, body, validator)
+	if len(errs) != 0 {
+		t.Fatalf("validation errors == %v, want none", errs)
+	}
+
+	pruned := binding.DeepCopy()
+	pruning.Prune(pruned.Object, structural, true)
+
+	if !reflect.DeepEqual(pruned.Object, binding.Object) {
+		t.Fatalf("pruned == %v, want %v", pruned.Object, binding.Object)
+	}
+}
@@ -1,26 +1,87 @@
 package updater
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
 	"github.com/giantswarm/microerror"
 	"github.com/giantswarm/micrologger"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 
-	"fmt"
-	"net"
-
-	"k8s.io/apimachinery/pkg/types"
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
 )
 
 const (
 	annotationIp = "endpoint.kvm.giantswarm.io/ip"
 )
 
+const (
+	// AnnotationReady is published on the KVM pod together with the VM IP and
+	// tells whether the VM is ready to receive traffic.
+	AnnotationReady = "endpoint.kvm.giantswarm.io/ready"
+)
+
+const (
+	// LabelManagedBy is set on every object created by the updater.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// LabelPod is set on every object created by the updater and holds the
+	// name of the KVM pod the object belongs to. Names exceeding the maximum
+	// length of label values are shortened, see AnnotationPod.
+	LabelPod = "endpoint.kvm.giantswarm.io/pod"
+	// AnnotationPod is set on every object created by the updater and holds
+	// the full name of the KVM pod the object belongs to.
+	AnnotationPod = "endpoint.kvm.giantswarm.io/pod"
+
+	// ManagedBy is the value of LabelManagedBy.
+	ManagedBy = "k8s-endpoint-updater"
+)
+
+const (
+	// maxLabelValueLength is the maximum length of label values.
+	maxLabelValueLength = 63
+	// maxNameLength is the maximum length of object names.
+	maxNameLength = 253
+	// hashLength is the length of the hash suffix of shortened names.
+	hashLength = 10
+)
+
+// Reasons of the events recorded on the KVM pod.
+const (
+	ReasonCleanupDone   = "CleanupDone"
+	ReasonIPChanged     = "IPChanged"
+	ReasonIPDiscovered  = "IPDiscovered"
+	ReasonPublishFailed = "PublishFailed"
+)
+
 // Config represents the configuration used to create a new updater.
 type Config struct {
 	// Dependencies.
-	K8sClient kubernetes.Interface
-	Logger    micrologger.Logger
+	DynamicClient dynamic.Interface
+	EventRecorder record.EventRecorder
+	K8sClient     kubernetes.Interface
+	Logger        micrologger.Logger
+
+	// Settings.
+
+	// PortOverrides maps service port names or numbers onto VM ports, taking
+	// precedence over the target ports of the service spec. Also see
+	// ParsePortOverrides.
+	PortOverrides map[string]int32
+	// StatusKind is the kind of object the status is written to by
+	// UpdateStatus. It is either StatusKindConfigMap, StatusKindEndpointBinding
+	// or empty to disable status reporting.
+	StatusKind string
 }
 
 // DefaultConfig provides a default configuration to create a new updater
@@ -28,14 +89,26 @@ type Config struct {
 func DefaultConfig() Config {
 	return Config{
 		// Dependencies.
-		K8sClient: nil,
-		Logger:    nil,
+		DynamicClient: nil,
+		EventRecorder: nil,
+		K8sClient:     nil,
+		Logger:        nil,
+
+		// Settings.
+		PortOverrides: nil,
+		StatusKind:    "",
 	}
 }
 
 // New creates a new updater.
 func New(config Config) (*Updater, error) {
 	// Dependencies.
+	if config.DynamicClient == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.DynamicClient must not be empty")
+	}
+	if config.EventRecorder == nil {
+		return nil, microerror.Maskf(invalidConfigError, "config.EventRecorder must not be empty")
+	}
 	if config.K8sClient == nil {
 		return nil, microerror.Maskf(invalidConfigError, "config.K8sClient must not be empty")
 	}
@@ -43,10 +116,23 @@ func New(config Config) (*Updater, error) {
 		return nil, microerror.Maskf(invalidConfigError, "config.Logger must not be empty")
 	}
 
+	// Settings.
+	switch config.StatusKind {
+	case "", StatusKindConfigMap, StatusKindEndpointBinding:
+	default:
+		return nil, microerror.Maskf(invalidConfigError, "config.StatusKind must be one of %q, %q or empty", StatusKindConfigMap, StatusKindEndpointBinding)
+	}
+
 	newUpdater := &Updater{
 		// Dependencies.
-		k8sClient: config.K8sClient,
-		logger:    config.Logger,
+		dynamicClient: config.DynamicClient,
+		eventRecorder: config.EventRecorder,
+		k8sClient:     config.K8sClient,
+		logger:        config.Logger,
+
+		// Settings.
+		portOverrides: config.PortOverrides,
+		statusKind:    config.StatusKind,
 	}
 
 	return newUpdater, nil
@@ -54,24 +140,166 @@ func New(config Config) (*Updater, error) {
 
 type Updater struct {
 	// Dependencies.
-	k8sClient kubernetes.Interface
-	logger    micrologger.Logger
+	dynamicClient dynamic.Interface
+	eventRecorder record.EventRecorder
+	k8sClient     kubernetes.Interface
+	logger        micrologger.Logger
+
+	// Settings.
+	portOverrides map[string]int32
+	statusKind    string
 }
 
-func (p *Updater) AddAnnotations(namespace, service string, podName string, podIP net.IP) error {
-	kvmPod, err := p.k8sClient.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+// AddAnnotations publishes the given VM IP and its readiness as annotations of
+// the KVM pod. Failures are recorded as events and left to the caller to log.
+func (p *Updater) AddAnnotations(ctx context.Context, namespace, service string, podName string, podIP net.IP, ready bool) error {
+	kvmPod, err := p.getPod(ctx, namespace, podName)
+	if err != nil {
+		p.eventRecorder.Eventf(podReference(namespace, podName), corev1.EventTypeWarning, ReasonPublishFailed, "Fetching pod to publish IP %s for service %s failed: %s", podIP, service, err)
+		return microerror.Mask(err)
+	}
+	patch := fmt.Sprintf("{\"metadata\":{\"annotations\":{\"%s\":\"%s\",\"%s\":\"%t\"}}}\n", annotationIp, podIP.String(), AnnotationReady, ready)
 
+	err = p.patchPod(ctx, namespace, kvmPod.Name, patch)
 	if err != nil {
-		_ = p.logger.Log("error", fmt.Sprintf("Fetching kvm pod failed: %#v.", err))
+		p.eventRecorder.Eventf(kvmPod, corev1.EventTypeWarning, ReasonPublishFailed, "Publishing IP %s for service %s failed: %s", podIP, service, err)
+		return microerror.Mask(err)
+	}
+
+	// Events are only recorded when the published IP actually changed. The
+	// periodic reconciliation would flood the pod with events otherwise.
+	currentIP := kvmPod.Annotations[annotationIp]
+	if currentIP == "" {
+		p.eventRecorder.Eventf(kvmPod, corev1.EventTypeNormal, ReasonIPDiscovered, "Published discovered IP %s for service %s", podIP, service)
+	} else if currentIP != podIP.String() {
+		p.eventRecorder.Eventf(kvmPod, corev1.EventTypeNormal, ReasonIPChanged, "Published changed IP %s for service %s, previously %s", podIP, service, currentIP)
+	}
+
+	return nil
+}
+
+// RemoveAnnotations removes the annotations added by AddAnnotations from the
+// KVM pod. It is a no-op when the pod does not exist anymore.
+func (p *Updater) RemoveAnnotations(ctx context.Context, namespace, service string, podName string) error {
+	kvmPod, err := p.getPod(ctx, namespace, podName)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
 		return microerror.Mask(err)
 	}
-	patch := fmt.Sprintf("{\"metadata\":{\"annotations\":{\"%s\":\"%s\"}}}\n", annotationIp, podIP.String())
 
-	_, err = p.k8sClient.CoreV1().Pods(namespace).Patch(kvmPod.Name, types.StrategicMergePatchType, []byte(patch))
+	if _, ok := kvmPod.Annotations[annotationIp]; !ok {
+		return nil
+	}
+
+	patch := fmt.Sprintf("{\"metadata\":{\"annotations\":{\"%s\":null,\"%s\":null}}}\n", annotationIp, AnnotationReady)
+
+	err = p.patchPod(ctx, namespace, kvmPod.Name, patch)
+	if err != nil {
+		return microerror.Mask(err)
+	}
+
+	p.eventRecorder.Eventf(kvmPod, corev1.EventTypeNormal, ReasonCleanupDone, "Removed published IP %s for service %s", kvmPod.Annotations[annotationIp], service)
+
+	return nil
+}
+
+// getPod returns the error of the API call unmasked, so that it can be
+// asserted by the caller.
+func (p *Updater) getPod(ctx context.Context, namespace, podName string) (*corev1.Pod, error) {
+	var pod *corev1.Pod
+	err := call.Do(ctx, func() error {
+		var err error
+		pod, err = p.k8sClient.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pod, nil
+}
+
+// getService returns the error of the API call unmasked, so that it can be
+// asserted by the caller.
+func (p *Updater) getService(ctx context.Context, namespace, service string) (*corev1.Service, error) {
+	var svc *corev1.Service
+	err := call.Do(ctx, func() error {
+		var err error
+		svc, err = p.k8sClient.CoreV1().Services(namespace).Get(service, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return svc, nil
+}
+
+func (p *Updater) patchPod(ctx context.Context, namespace, podName string, patch string, subresources ...string) error {
+	start := time.Now()
+	err := call.Do(ctx, func() error {
+		_, err := p.k8sClient.CoreV1().Pods(namespace).Patch(podName, types.StrategicMergePatchType, []byte(patch), subresources...)
+		return err
+	})
+	patchDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
-		_ = p.logger.Log("error", fmt.Sprintf("Updating pod annotation failed: %#v.", err))
+		patchTotal.WithLabelValues("failure").Inc()
 		return microerror.Mask(err)
 	}
+	patchTotal.WithLabelValues("success").Inc()
 
 	return nil
 }
+
+// podReference is used to record events for pods which could not be fetched.
+func podReference(namespace, podName string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Namespace:  namespace,
+		Name:       podName,
+	}
+}
+
+// managedLabels returns the labels of objects created by the updater. The pod
+// label is omitted for objects not belonging to a single KVM pod.
+func managedLabels(podName string) map[string]string {
+	labels := map[string]string{
+		LabelManagedBy: ManagedBy,
+	}
+	if podName != "" {
+		labels[LabelPod] = shorten(podName, maxLabelValueLength)
+	}
+
+	return labels
+}
+
+// managedAnnotations returns the annotations of objects created by the
+// updater. It is nil for objects not belonging to a single KVM pod.
+func managedAnnotations(podName string) map[string]string {
+	if podName == "" {
+		return nil
+	}
+
+	return map[string]string{
+		AnnotationPod: podName,
+	}
+}
+
+// shorten returns name when it does not exceed max characters. Otherwise name
+// is truncated and suffixed with a hash of the full name, so that shortened
+// names stay unique. Trailing dashes and dots are removed from the truncated
+// name, because names and label values have to end alphanumeric.
+func shorten(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+
+	prefix := strings.TrimRight(name[:max-hashLength-1], "-.")
+
+	return prefix + "-" + hash
+}
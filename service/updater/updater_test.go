@@ -0,0 +1,385 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/giantswarm/microerror"
+	"github.com/giantswarm/micrologger/microloggertest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/giantswarm/k8s-endpoint-updater/service/call"
+)
+
+// newTestUpdater returns an updater using fake clients. Unstructured objects
+// are served by the dynamic client, all others by the Kubernetes client.
+func newTestUpdater(t *testing.T, objects ...runtime.Object) (*Updater, *fake.Clientset, *dynamicfake.FakeDynamicClient, *record.FakeRecorder) {
+	t.Helper()
+
+	var k8sObjects []runtime.Object
+	var dynamicObjects []runtime.Object
+	for _, o := range objects {
+		if _, ok := o.(*unstructured.Unstructured); ok {
+			dynamicObjects = append(dynamicObjects, o)
+		} else {
+			k8sObjects = append(k8sObjects, o)
+		}
+	}
+
+	k8sClient := fake.NewSimpleClientset(k8sObjects...)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjects...)
+	eventRecorder := record.NewFakeRecorder(10)
+
+	c := DefaultConfig()
+	c.DynamicClient = dynamicClient
+	c.EventRecorder = eventRecorder
+	c.K8sClient = k8sClient
+	c.Logger = microloggertest.New()
+
+	u, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u, k8sClient, dynamicClient, eventRecorder
+}
+
+func newTestPod(annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "kvm",
+			Namespace:   "default",
+			Annotations: annotations,
+			UID:         "kvm-uid",
+		},
+	}
+}
+
+// events drains the events recorded so far and returns their reasons.
+func events(r *record.FakeRecorder) []string {
+	var reasons []string
+	for {
+		select {
+		case e := <-r.Events:
+			reasons = append(reasons, strings.Fields(e)[1])
+		default:
+			return reasons
+		}
+	}
+}
+
+// patches returns the patches sent to the given client.
+func patches(c *fake.Clientset) []string {
+	var p []string
+	for _, a := range c.Actions() {
+		if patch, ok := a.(k8stesting.PatchAction); ok {
+			p = append(p, string(patch.GetPatch()))
+		}
+	}
+
+	return p
+}
+
+func Test_Updater_AddAnnotations(t *testing.T) {
+	testCases := []struct {
+		name            string
+		annotations     map[string]string
+		ip              string
+		ready           bool
+		expectedPatches []string
+		expectedEvents  []string
+	}{
+		{
+			name:        "case 0: discovered IP",
+			annotations: nil,
+			ip:          "10.0.0.2",
+			ready:       true,
+			expectedPatches: []string{
+				"{\"metadata\":{\"annotations\":{\"endpoint.kvm.giantswarm.io/ip\":\"10.0.0.2\",\"endpoint.kvm.giantswarm.io/ready\":\"true\"}}}\n",
+			},
+			expectedEvents: []string{ReasonIPDiscovered},
+		},
+		{
+			name: "case 1: changed IP",
+			annotations: map[string]string{
+				annotationIp:    "10.0.0.2",
+				AnnotationReady: "true",
+			},
+			ip:    "10.0.0.3",
+			ready: false,
+			expectedPatches: []string{
+				"{\"metadata\":{\"annotations\":{\"endpoint.kvm.giantswarm.io/ip\":\"10.0.0.3\",\"endpoint.kvm.giantswarm.io/ready\":\"false\"}}}\n",
+			},
+			expectedEvents: []string{ReasonIPChanged},
+		},
+		{
+			name: "case 2: unchanged IP",
+			annotations: map[string]string{
+				annotationIp:    "10.0.0.2",
+				AnnotationReady: "true",
+			},
+			ip:    "10.0.0.2",
+			ready: true,
+			expectedPatches: []string{
+				"{\"metadata\":{\"annotations\":{\"endpoint.kvm.giantswarm.io/ip\":\"10.0.0.2\",\"endpoint.kvm.giantswarm.io/ready\":\"true\"}}}\n",
+			},
+			expectedEvents: nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			u, k8sClient, _, eventRecorder := newTestUpdater(t, newTestPod(tc.annotations))
+
+			err := u.AddAnnotations(context.Background(), "default", "master", "kvm", net.ParseIP(tc.ip), tc.ready)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := patches(k8sClient)
+			if fmt.Sprint(p) != fmt.Sprint(tc.expectedPatches) {
+				t.Fatalf("patches == %q, want %q", p, tc.expectedPatches)
+			}
+			e := events(eventRecorder)
+			if fmt.Sprint(e) != fmt.Sprint(tc.expectedEvents) {
+				t.Fatalf("events == %v, want %v", e, tc.expectedEvents)
+			}
+
+			pod, err := k8sClient.CoreV1().Pods("default").Get("kvm", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pod.Annotations[annotationIp] != tc.ip {
+				t.Fatalf("annotation %s == %q, want %q", annotationIp, pod.Annotations[annotationIp], tc.ip)
+			}
+			if pod.Annotations[AnnotationReady] != strconv.FormatBool(tc.ready) {
+				t.Fatalf("annotation %s == %q, want %t", AnnotationReady, pod.Annotations[AnnotationReady], tc.ready)
+			}
+		})
+	}
+}
+
+func Test_Updater_AddAnnotations_Idempotent(t *testing.T) {
+	u, k8sClient, _, eventRecorder := newTestUpdater(t, newTestPod(nil))
+
+	for i := 0; i < 3; i++ {
+		err := u.AddAnnotations(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := events(eventRecorder)
+	if len(e) != 1 || e[0] != ReasonIPDiscovered {
+		t.Fatalf("events == %v, want [%s]", e, ReasonIPDiscovered)
+	}
+
+	pod, err := k8sClient.CoreV1().Pods("default").Get("kvm", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Annotations) != 2 {
+		t.Fatalf("annotations == %v, want 2", pod.Annotations)
+	}
+}
+
+func Test_Updater_AddAnnotations_PodNotFound(t *testing.T) {
+	u, k8sClient, _, eventRecorder := newTestUpdater(t)
+
+	err := u.AddAnnotations(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), true)
+	if !errors.IsNotFound(microerror.Cause(err)) {
+		t.Fatalf("error == %#v, want not found", err)
+	}
+
+	if p := patches(k8sClient); len(p) != 0 {
+		t.Fatalf("patches == %q, want none", p)
+	}
+	e := events(eventRecorder)
+	if len(e) != 1 || e[0] != ReasonPublishFailed {
+		t.Fatalf("events == %v, want [%s]", e, ReasonPublishFailed)
+	}
+}
+
+func Test_Updater_AddAnnotations_PatchFailed(t *testing.T) {
+	u, k8sClient, _, eventRecorder := newTestUpdater(t, newTestPod(nil))
+
+	k8sClient.PrependReactor("patch", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(corev1.Resource("pods"), "kvm", fmt.Errorf("denied"))
+	})
+
+	err := u.AddAnnotations(context.Background(), "default", "master", "kvm", net.ParseIP("10.0.0.2"), true)
+	if !errors.IsForbidden(microerror.Cause(err)) {
+		t.Fatalf("error == %#v, want forbidden", err)
+	}
+
+	e := events(eventRecorder)
+	if len(e) != 1 || e[0] != ReasonPublishFailed {
+		t.Fatalf("events == %v, want [%s]", e, ReasonPublishFailed)
+	}
+}
+
+func Test_Updater_RemoveAnnotations(t *testing.T) {
+	testCases := []struct {
+		name            string
+		pod             *corev1.Pod
+		expectedPatches []string
+		expectedEvents  []string
+	}{
+		{
+			name: "case 0: published IP is removed",
+			pod: newTestPod(map[string]string{
+				annotationIp:    "10.0.0.2",
+				AnnotationReady: "true",
+				"other":         "value",
+			}),
+			expectedPatches: []string{
+				"{\"metadata\":{\"annotations\":{\"endpoint.kvm.giantswarm.io/ip\":null,\"endpoint.kvm.giantswarm.io/ready\":null}}}\n",
+			},
+			expectedEvents: []string{ReasonCleanupDone},
+		},
+		{
+			name:            "case 1: nothing published",
+			pod:             newTestPod(map[string]string{"other": "value"}),
+			expectedPatches: nil,
+			expectedEvents:  nil,
+		},
+		{
+			name:            "case 2: pod not found",
+			pod:             nil,
+			expectedPatches: nil,
+			expectedEvents:  nil,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			var objects []runtime.Object
+			if tc.pod != nil {
+				objects = append(objects, tc.pod)
+			}
+			u, k8sClient, _, eventRecorder := newTestUpdater(t, objects...)
+
+			err := u.RemoveAnnotations(context.Background(), "default", "master", "kvm")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := patches(k8sClient)
+			if fmt.Sprint(p) != fmt.Sprint(tc.expectedPatches) {
+				t.Fatalf("patches == %q, want %q", p, tc.expectedPatches)
+			}
+			e := events(eventRecorder)
+			if fmt.Sprint(e) != fmt.Sprint(tc.expectedEvents) {
+				t.Fatalf("events == %v, want %v", e, tc.expectedEvents)
+			}
+
+			if tc.pod == nil {
+				return
+			}
+			pod, err := k8sClient.CoreV1().Pods("default").Get("kvm", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := pod.Annotations[annotationIp]; ok {
+				t.Fatalf("annotation %s must be removed", annotationIp)
+			}
+			if pod.Annotations["other"] != "value" {
+				t.Fatalf("annotation other must be kept")
+			}
+		})
+	}
+}
+
+func Test_Updater_Cancelled(t *testing.T) {
+	u, k8sClient, _, _ := newTestUpdater(t, newTestPod(nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := u.AddAnnotations(ctx, "default", "master", "kvm", net.ParseIP("10.0.0.2"), true)
+	if !call.IsCancelled(err) {
+		t.Fatalf("error == %#v, want cancelled", err)
+	}
+	if p := patches(k8sClient); len(p) != 0 {
+		t.Fatalf("patches == %q, want none", p)
+	}
+}
+
+func Test_Updater_managedLabels(t *testing.T) {
+	testCases := []struct {
+		name           string
+		podName        string
+		expectedPrefix string
+		expectedLength int
+	}{
+		{
+			name:           "case 0: short pod name is used as is",
+			podName:        "kvm",
+			expectedPrefix: "kvm",
+			expectedLength: 3,
+		},
+		{
+			name:           "case 1: pod name of maximum label value length is used as is",
+			podName:        strings.Repeat("a", 63),
+			expectedPrefix: strings.Repeat("a", 63),
+			expectedLength: 63,
+		},
+		{
+			name:           "case 2: long pod name is truncated and hashed",
+			podName:        strings.Repeat("a", 100),
+			expectedPrefix: strings.Repeat("a", 52) + "-",
+			expectedLength: 63,
+		},
+		{
+			name:           "case 3: trailing dashes and dots of the truncated name are removed",
+			podName:        strings.Repeat("a", 50) + "-.-" + strings.Repeat("b", 50),
+			expectedPrefix: strings.Repeat("a", 50) + "-",
+			expectedLength: 61,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Log(tc.name)
+
+			labels := managedLabels(tc.podName)
+			if !strings.HasPrefix(labels[LabelPod], tc.expectedPrefix) {
+				t.Fatalf("label %s == %q, want prefix %q", LabelPod, labels[LabelPod], tc.expectedPrefix)
+			}
+			if len(labels[LabelPod]) != tc.expectedLength {
+				t.Fatalf("label %s has %d characters, want %d", LabelPod, len(labels[LabelPod]), tc.expectedLength)
+			}
+
+			annotations := managedAnnotations(tc.podName)
+			if annotations[AnnotationPod] != tc.podName {
+				t.Fatalf("annotation %s == %q, want %q", AnnotationPod, annotations[AnnotationPod], tc.podName)
+			}
+		})
+	}
+}
+
+func Test_Updater_shorten_Unique(t *testing.T) {
+	prefix := strings.Repeat("a", 100)
+
+	a := shorten(prefix+"-1", maxLabelValueLength)
+	b := shorten(prefix+"-2", maxLabelValueLength)
+	if a == b {
+		t.Fatalf("shortened names of different names must differ, got %q", a)
+	}
+}